@@ -0,0 +1,256 @@
+// Package cidr computes with sets of IPv4 and IPv6 prefixes, turning
+// exclusions into the list of included prefixes expected by libtelio's
+// allowed IPs.
+package cidr
+
+import (
+	"fmt"
+	"net/netip"
+	"sort"
+
+	telio "github.com/NordSecurity/libtelio-go/v8"
+)
+
+// ipRange is an inclusive range of addresses of the same family.
+type ipRange struct {
+	from, to netip.Addr
+}
+
+// Set is a set of IPv4 and IPv6 addresses. The zero value is an empty set.
+// Addresses are kept as sorted disjoint ranges, so a Set may hold large
+// complements cheaply.
+type Set struct {
+	ranges []ipRange
+}
+
+// Universe returns the set of every IPv4 and IPv6 address.
+func Universe() *Set {
+	s := &Set{}
+	s.AddPrefix(netip.MustParsePrefix("0.0.0.0/0"))
+	s.AddPrefix(netip.MustParsePrefix("::/0"))
+	return s
+}
+
+// Parse creates a set from the union of nets.
+func Parse(nets []telio.IpNet) (*Set, error) {
+	s := &Set{}
+	for _, n := range nets {
+		if err := s.Add(n); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// MustParse is like Parse but panics on invalid prefixes.
+func MustParse(nets ...telio.IpNet) *Set {
+	s, err := Parse(nets)
+	if err != nil {
+		panic(err)
+	}
+	return s
+}
+
+// Add adds a prefix, e.g. "10.0.0.0/8", or a single address to s.
+func (s *Set) Add(n telio.IpNet) error {
+	p, err := parse(n)
+	if err != nil {
+		return err
+	}
+	s.AddPrefix(p)
+	return nil
+}
+
+// Remove removes a prefix or a single address from s.
+func (s *Set) Remove(n telio.IpNet) error {
+	p, err := parse(n)
+	if err != nil {
+		return err
+	}
+	s.RemovePrefix(p)
+	return nil
+}
+
+// AddPrefix adds p to s.
+func (s *Set) AddPrefix(p netip.Prefix) {
+	s.addRange(prefixRange(p))
+}
+
+// RemovePrefix removes p from s.
+func (s *Set) RemovePrefix(p netip.Prefix) {
+	s.removeRange(prefixRange(p))
+}
+
+// Union adds every address of o to s.
+func (s *Set) Union(o *Set) {
+	for _, r := range o.ranges {
+		s.addRange(r)
+	}
+}
+
+// Subtract removes every address of o from s.
+func (s *Set) Subtract(o *Set) {
+	for _, r := range o.ranges {
+		s.removeRange(r)
+	}
+}
+
+// Intersect removes every address not in o from s.
+func (s *Set) Intersect(o *Set) {
+	s.Subtract(o.Complement())
+}
+
+// Complement returns the set of addresses not in s.
+func (s *Set) Complement() *Set {
+	c := Universe()
+	c.Subtract(s)
+	return c
+}
+
+// Clone returns a copy of s.
+func (s *Set) Clone() *Set {
+	return &Set{ranges: append([]ipRange(nil), s.ranges...)}
+}
+
+// Contains reports whether addr is in s.
+func (s *Set) Contains(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	i := sort.Search(len(s.ranges), func(i int) bool { return s.ranges[i].to.Compare(addr) >= 0 })
+	return i < len(s.ranges) && s.ranges[i].from.Compare(addr) <= 0
+}
+
+// Empty reports whether s contains no address.
+func (s *Set) Empty() bool {
+	return len(s.ranges) == 0
+}
+
+// Prefixes returns the smallest list of prefixes covering exactly s, IPv4
+// first, in ascending order.
+func (s *Set) Prefixes() []netip.Prefix {
+	var out []netip.Prefix
+	for _, r := range s.ranges {
+		out = appendRangePrefixes(out, r)
+	}
+	return out
+}
+
+// IpNets returns Prefixes formatted for libtelio.
+func (s *Set) IpNets() []telio.IpNet {
+	prefixes := s.Prefixes()
+	out := make([]telio.IpNet, len(prefixes))
+	for i, p := range prefixes {
+		out[i] = p.String()
+	}
+	return out
+}
+
+// Filter returns the subset of s in the IPv4 or the IPv6 address space.
+func (s *Set) Filter(ipv6 bool) *Set {
+	out := &Set{}
+	for _, r := range s.ranges {
+		if r.from.Is6() == ipv6 {
+			out.ranges = append(out.ranges, r)
+		}
+	}
+	return out
+}
+
+func (s *Set) String() string {
+	return fmt.Sprint(s.IpNets())
+}
+
+func (s *Set) addRange(r ipRange) {
+	// Find the ranges overlapping or adjacent to r and merge them into it
+	i := sort.Search(len(s.ranges), func(i int) bool { return adjacentOrAfter(s.ranges[i].to, r.from) })
+	j := i
+	for j < len(s.ranges) && adjacentOrAfter(r.to, s.ranges[j].from) {
+		if s.ranges[j].from.Less(r.from) {
+			r.from = s.ranges[j].from
+		}
+		if r.to.Less(s.ranges[j].to) {
+			r.to = s.ranges[j].to
+		}
+		j++
+	}
+	s.ranges = append(s.ranges[:i], append([]ipRange{r}, s.ranges[j:]...)...)
+}
+
+func (s *Set) removeRange(r ipRange) {
+	out := s.ranges[:0:0]
+	for _, cur := range s.ranges {
+		if cur.to.Less(r.from) || r.to.Less(cur.from) {
+			out = append(out, cur)
+			continue
+		}
+		if cur.from.Less(r.from) {
+			out = append(out, ipRange{cur.from, r.from.Prev()})
+		}
+		if r.to.Less(cur.to) {
+			out = append(out, ipRange{r.to.Next(), cur.to})
+		}
+	}
+	s.ranges = out
+}
+
+// adjacentOrAfter reports whether b directly follows a or comes before it,
+// i.e. whether ranges ending at a and starting at b can be merged.
+func adjacentOrAfter(a, b netip.Addr) bool {
+	if a.BitLen() != b.BitLen() {
+		return a.BitLen() > b.BitLen()
+	}
+	next := a.Next()
+	return !next.IsValid() || next.Compare(b) >= 0
+}
+
+func parse(n telio.IpNet) (netip.Prefix, error) {
+	if p, err := netip.ParsePrefix(n); err == nil {
+		return unmapPrefix(p), nil
+	}
+	addr, err := netip.ParseAddr(n)
+	if err != nil {
+		return netip.Prefix{}, fmt.Errorf("invalid prefix %q", n)
+	}
+	addr = addr.Unmap()
+	return netip.PrefixFrom(addr, addr.BitLen()), nil
+}
+
+func unmapPrefix(p netip.Prefix) netip.Prefix {
+	if p.Addr().Is4In6() && p.Bits() >= 96 {
+		return netip.PrefixFrom(p.Addr().Unmap(), p.Bits()-96).Masked()
+	}
+	return p.Masked()
+}
+
+func prefixRange(p netip.Prefix) ipRange {
+	p = unmapPrefix(p)
+	return ipRange{from: p.Addr(), to: lastAddr(p)}
+}
+
+func lastAddr(p netip.Prefix) netip.Addr {
+	b := p.Addr().AsSlice()
+	for i := p.Bits(); i < len(b)*8; i++ {
+		b[i/8] |= 0x80 >> (i % 8)
+	}
+	addr, _ := netip.AddrFromSlice(b)
+	return addr
+}
+
+// appendRangePrefixes appends the largest aligned prefixes covering r.
+func appendRangePrefixes(out []netip.Prefix, r ipRange) []netip.Prefix {
+	from := r.from
+	for {
+		var p netip.Prefix
+		for bits := 0; bits <= from.BitLen(); bits++ {
+			p = netip.PrefixFrom(from, bits)
+			if p.Masked().Addr() == from && lastAddr(p).Compare(r.to) <= 0 {
+				break
+			}
+		}
+		out = append(out, p)
+		last := lastAddr(p)
+		if last == r.to {
+			return out
+		}
+		from = last.Next()
+	}
+}
@@ -0,0 +1,108 @@
+package cidr
+
+import (
+	"fmt"
+
+	telio "github.com/NordSecurity/libtelio-go/v8"
+	"github.com/NordSecurity/libtelio-go/v8/exitnode"
+)
+
+// PrivateRanges are the RFC 1918 networks. libtelio's firewall lets through
+// traffic to the one configured in FeatureFirewall.ExcludePrivateIpRange.
+var PrivateRanges = []telio.IpNet{"10.0.0.0/8", "172.16.0.0/12", "192.168.0.0/16"}
+
+// SplitTunnel describes which traffic an exit node connection carries.
+type SplitTunnel struct {
+	// Networks routed through the exit node, everything when nil
+	Include []telio.IpNet
+	// Networks kept outside the tunnel, e.g. the LAN or the address of the
+	// application's own API server
+	Exclude []telio.IpNet
+	// Route IPv6 traffic, set when Features.Ipv6 is enabled
+	IPv6 bool
+}
+
+// Set returns the addresses routed through the exit node.
+func (st SplitTunnel) Set() (*Set, error) {
+	var set *Set
+	if st.Include == nil {
+		set = Universe()
+	} else {
+		var err error
+		if set, err = Parse(st.Include); err != nil {
+			return nil, fmt.Errorf("included networks: %w", err)
+		}
+	}
+	exclude, err := Parse(st.Exclude)
+	if err != nil {
+		return nil, fmt.Errorf("excluded networks: %w", err)
+	}
+	set.Subtract(exclude)
+	if !st.IPv6 {
+		set = set.Filter(false)
+	}
+	return set, nil
+}
+
+// AllowedIps returns the minimal list of prefixes to pass as the allowed IPs
+// of an exit node.
+func (st SplitTunnel) AllowedIps() ([]telio.IpNet, error) {
+	set, err := st.Set()
+	if err != nil {
+		return nil, err
+	}
+	if set.Empty() {
+		return nil, fmt.Errorf("split tunnel excludes every address")
+	}
+	return set.IpNets(), nil
+}
+
+// PrivateRange returns the part of the RFC 1918 networks excluded from the
+// tunnel, as expected by FeatureFirewall.ExcludePrivateIpRange. It is nil when
+// no private network is excluded, and an error when the excluded private
+// networks cannot be described by a single prefix.
+func (st SplitTunnel) PrivateRange() (*telio.Ipv4Net, error) {
+	exclude, err := Parse(st.Exclude)
+	if err != nil {
+		return nil, fmt.Errorf("excluded networks: %w", err)
+	}
+	exclude.Intersect(MustParse(PrivateRanges...))
+	nets := exclude.IpNets()
+	switch len(nets) {
+	case 0:
+		return nil, nil
+	case 1:
+		return &nets[0], nil
+	}
+	return nil, fmt.Errorf("libtelio excludes a single private range from its firewall, split tunnel excludes %v", nets)
+}
+
+// ConfigureFirewall sets FeatureFirewall.ExcludePrivateIpRange so libtelio's
+// firewall does not block the private networks kept outside the tunnel.
+func (st SplitTunnel) ConfigureFirewall(fw *telio.FeatureFirewall) error {
+	r, err := st.PrivateRange()
+	if err != nil {
+		return err
+	}
+	fw.ExcludePrivateIpRange = r
+	return nil
+}
+
+// Apply returns cand routing the split tunnel's traffic.
+func (st SplitTunnel) Apply(cand exitnode.Candidate) (exitnode.Candidate, error) {
+	allowed, err := st.AllowedIps()
+	if err != nil {
+		return cand, err
+	}
+	cand.AllowedIps = allowed
+	return cand, nil
+}
+
+// Connect connects c to cand, routing only the split tunnel's traffic.
+func (st SplitTunnel) Connect(c exitnode.Connector, cand exitnode.Candidate) error {
+	cand, err := st.Apply(cand)
+	if err != nil {
+		return err
+	}
+	return cand.Connect(c)
+}
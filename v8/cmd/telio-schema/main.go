@@ -0,0 +1,65 @@
+// Command telio-schema prints the JSON Schema of the features or meshnet
+// config JSON, or validates documents against the schemas embedded in package
+// schema:
+//
+//	telio-schema features > features.schema.json
+//	telio-schema config > meshnet.schema.json
+//	telio-schema -validate features.json features
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/NordSecurity/libtelio-go/v8/schema"
+	"github.com/NordSecurity/libtelio-go/v8/schema/schemagen"
+)
+
+func main() {
+	validate := flag.String("validate", "", "validate this JSON file instead of printing the schema")
+	flag.Usage = func() {
+		fmt.Fprintln(flag.CommandLine.Output(), "usage: telio-schema [-validate file] features|config")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	if err := run(flag.Arg(0), *validate); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func run(kind, validate string) error {
+	var s func() *schema.Schema
+	var check func([]byte) error
+	switch kind {
+	case "features":
+		s, check = schemagen.Features, schema.ValidateFeatures
+	case "config":
+		s, check = schemagen.Config, schema.ValidateConfig
+	default:
+		return fmt.Errorf("unknown schema %q", kind)
+	}
+
+	if validate != "" {
+		data, err := os.ReadFile(validate)
+		if err != nil {
+			return err
+		}
+		if err := check(data); err != nil {
+			return fmt.Errorf("%s is invalid:\n%w", validate, err)
+		}
+		return nil
+	}
+
+	data, err := s().MarshalIndent()
+	if err != nil {
+		return err
+	}
+	_, err = os.Stdout.Write(append(data, '\n'))
+	return err
+}
@@ -0,0 +1,575 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	telio "github.com/NordSecurity/libtelio-go/v8"
+)
+
+type ctl struct {
+	dev    telio.TelioInterface
+	out    *printer
+	events <-chan telio.Event
+	// Whether commands are read by repl
+	inREPL bool
+}
+
+type command struct {
+	usage string
+	help  string
+	// nil for commands handled by repl itself, which are hidden in
+	// one-shot mode
+	run func(c *ctl, args []string) error
+}
+
+var commands map[string]command
+
+func init() {
+	commands = map[string]command{
+		"help":           {"help", "show this help", (*ctl).help},
+		"output":         {"output table|json", "switch the output format", (*ctl).output},
+		"version":        {"version", "show the libtelio version", (*ctl).version},
+		"gen-key":        {"gen-key", "generate a new key pair", (*ctl).genKey},
+		"start":          {"start [-adapter A] [-name N] [-tun FD] [-ext-if-filter IF,...] SECRET-KEY", "start the device", (*ctl).start},
+		"stop":           {"stop", "stop the device", noArgs(telio.TelioInterface.Stop)},
+		"shutdown":       {"shutdown [-hard]", "stop and uninitialize libtelio", (*ctl).shutdownCmd},
+		"running":        {"running", "report whether the device is running", (*ctl).running},
+		"set-key":        {"set-key SECRET-KEY", "replace the secret key of the device", (*ctl).setKey},
+		"key":            {"key [-secret]", "show the key of the device", (*ctl).key},
+		"meshnet":        {"meshnet on MAP.json | off", "turn meshnet on with a meshnet map, or off", (*ctl).meshnet},
+		"connect":        {"connect [-id ID] [-endpoint IP:PORT] [-allowed-ips NET,...] [-pq] PUBLIC-KEY", "connect to an exit node", (*ctl).connect},
+		"disconnect":     {"disconnect [PUBLIC-KEY]", "disconnect from one or all exit nodes", (*ctl).disconnect},
+		"dns":            {"dns on [FORWARD-SERVER...] | off", "turn magic DNS on or off", (*ctl).dns},
+		"status":         {"status", "show the status of all nodes", (*ctl).status},
+		"events":         {"events [-follow]", "show the buffered events, or follow them until interrupted", (*ctl).eventsCmd},
+		"fwmark":         {"fwmark MARK", "set the fwmark of libtelio sockets", (*ctl).fwmark},
+		"ext-if-filter":  {"ext-if-filter [IF...]", "set the filtered external interfaces", (*ctl).extIfFilter},
+		"tun":            {"tun FD", "set the tunnel file descriptor", (*ctl).tun},
+		"tunnel-src-ip":  {"tunnel-src-ip [IP...]", "set the tunnel source addresses enforced by the firewall", (*ctl).tunnelSrcIP},
+		"network-change": {"network-change [INFO]", "notify libtelio of a network change", (*ctl).networkChange},
+		"sleep":          {"sleep", "notify libtelio that the system goes to sleep", noArgs(telio.TelioInterface.NotifySleep)},
+		"wakeup":         {"wakeup", "notify libtelio that the system woke up", noArgs(telio.TelioInterface.NotifyWakeup)},
+		"tplite":         {"tplite whitelist FILE|- [-redirect BLOCKING=STANDARD]... | tplite stats on DNS-IP... | tplite stats off", "configure TP-Lite", (*ctl).tplite},
+		"luid":           {"luid", "show the adapter LUID", (*ctl).luid},
+		"last-error":     {"last-error", "show the last libtelio error", (*ctl).lastError},
+		"ping":           {"ping", "receive a ping from libtelio", (*ctl).ping},
+		"analytics":      {"analytics", "trigger an analytics event", noArgs(telio.TelioInterface.TriggerAnalyticsEvent)},
+		"qos":            {"qos", "trigger QoS collection", noArgs(telio.TelioInterface.TriggerQosCollection)},
+		"panic":          {"panic stack|thread", "make libtelio panic, for testing only", (*ctl).panicCmd},
+		"exit":           {"exit", "leave the REPL", nil},
+	}
+}
+
+func printCommands(w io.Writer, inREPL bool) {
+	names := make([]string, 0, len(commands))
+	for name, cmd := range commands {
+		if cmd.run == nil && !inREPL {
+			continue
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	for _, name := range names {
+		fmt.Fprintf(tw, "  %s\t%s\n", commands[name].usage, commands[name].help)
+	}
+	tw.Flush()
+}
+
+func (c *ctl) exec(args []string) error {
+	cmd, ok := commands[args[0]]
+	if !ok || cmd.run == nil {
+		return fmt.Errorf("unknown command %q, try \"help\"", args[0])
+	}
+	return cmd.run(c, args[1:])
+}
+
+func (c *ctl) shutdown() {
+	if running, err := c.dev.IsRunning(); err == nil && running {
+		_ = c.dev.Stop()
+	}
+	_ = c.dev.Shutdown()
+}
+
+func noArgs(fn func(telio.TelioInterface) error) func(*ctl, []string) error {
+	return func(c *ctl, args []string) error {
+		if len(args) != 0 {
+			return errors.New("unexpected arguments")
+		}
+		if err := fn(c.dev); err != nil {
+			return err
+		}
+		return c.out.done()
+	}
+}
+
+func parseFlags(name string, fs *flag.FlagSet, args []string, nargs ...int) ([]string, error) {
+	fs.SetOutput(io.Discard)
+	if err := fs.Parse(args); err != nil {
+		return nil, fmt.Errorf("%s: %w, usage: %s", name, err, commands[name].usage)
+	}
+	rest := fs.Args()
+	if len(nargs) > 0 && (len(rest) < nargs[0] || (len(nargs) > 1 && len(rest) > nargs[1])) {
+		return nil, fmt.Errorf("usage: %s", commands[name].usage)
+	}
+	return rest, nil
+}
+
+func splitList(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(s, ",")
+}
+
+func (c *ctl) help(args []string) error {
+	c.out.write(func(w io.Writer) { printCommands(w, c.inREPL) })
+	return nil
+}
+
+func (c *ctl) output(args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("usage: %s", commands["output"].usage)
+	}
+	return c.out.setFormat(args[0])
+}
+
+func (c *ctl) version(args []string) error {
+	v := map[string]string{"version": telio.GetVersionTag(), "commit": telio.GetCommitSha()}
+	return c.out.print(v, func(tw *tabwriter.Writer) {
+		fmt.Fprintf(tw, "version\t%s\ncommit\t%s\n", v["version"], v["commit"])
+	})
+}
+
+func (c *ctl) genKey(args []string) error {
+	secret := telio.GenerateSecretKey()
+	keys := map[string]string{"secret_key": secret, "public_key": telio.GeneratePublicKey(secret)}
+	return c.out.print(keys, func(tw *tabwriter.Writer) {
+		fmt.Fprintf(tw, "secret key\t%s\npublic key\t%s\n", keys["secret_key"], keys["public_key"])
+	})
+}
+
+func (c *ctl) start(args []string) error {
+	fs := flag.NewFlagSet("start", flag.ContinueOnError)
+	adapterName := fs.String("adapter", "default", "")
+	name := fs.String("name", "", "")
+	tun := fs.Int("tun", -1, "")
+	extIfFilter := fs.String("ext-if-filter", "", "")
+	rest, err := parseFlags("start", fs, args, 1, 1)
+	if err != nil {
+		return err
+	}
+	adapter, err := parseAdapter(*adapterName)
+	if err != nil {
+		return err
+	}
+
+	secret := rest[0]
+	switch {
+	case *tun >= 0:
+		err = c.dev.StartWithTun(secret, adapter, int32(*tun))
+	case *extIfFilter != "":
+		err = c.dev.StartNamedExtIfFilter(secret, adapter, *name, splitList(*extIfFilter))
+	case *name != "":
+		err = c.dev.StartNamed(secret, adapter, *name)
+	default:
+		err = c.dev.Start(secret, adapter)
+	}
+	if err != nil {
+		return err
+	}
+	return c.out.done()
+}
+
+func (c *ctl) shutdownCmd(args []string) error {
+	fs := flag.NewFlagSet("shutdown", flag.ContinueOnError)
+	hard := fs.Bool("hard", false, "")
+	if _, err := parseFlags("shutdown", fs, args, 0, 0); err != nil {
+		return err
+	}
+	shutdown := c.dev.Shutdown
+	if *hard {
+		shutdown = c.dev.ShutdownHard
+	}
+	if err := shutdown(); err != nil {
+		return err
+	}
+	return c.out.done()
+}
+
+func (c *ctl) running(args []string) error {
+	running, err := c.dev.IsRunning()
+	if err != nil {
+		return err
+	}
+	return c.out.print(map[string]bool{"running": running}, func(tw *tabwriter.Writer) {
+		fmt.Fprintln(tw, running)
+	})
+}
+
+func (c *ctl) setKey(args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("usage: %s", commands["set-key"].usage)
+	}
+	if err := c.dev.SetSecretKey(args[0]); err != nil {
+		return err
+	}
+	return c.out.done()
+}
+
+func (c *ctl) key(args []string) error {
+	fs := flag.NewFlagSet("key", flag.ContinueOnError)
+	showSecret := fs.Bool("secret", false, "")
+	if _, err := parseFlags("key", fs, args, 0, 0); err != nil {
+		return err
+	}
+
+	secret := c.dev.GetSecretKey()
+	keys := map[string]string{"public_key": telio.GeneratePublicKey(secret)}
+	if *showSecret {
+		keys["secret_key"] = secret
+	}
+	return c.out.print(keys, func(tw *tabwriter.Writer) {
+		if *showSecret {
+			fmt.Fprintf(tw, "secret key\t%s\n", secret)
+		}
+		fmt.Fprintf(tw, "public key\t%s\n", keys["public_key"])
+	})
+}
+
+func (c *ctl) meshnet(args []string) error {
+	switch {
+	case len(args) == 2 && args[0] == "on":
+		data, err := os.ReadFile(args[1])
+		if err != nil {
+			return err
+		}
+		cfg, err := telio.DeserializeMeshnetConfig(string(data))
+		if err != nil {
+			return err
+		}
+		if err := c.dev.SetMeshnet(cfg); err != nil {
+			return err
+		}
+	case len(args) == 1 && args[0] == "off":
+		if err := c.dev.SetMeshnetOff(); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("usage: %s", commands["meshnet"].usage)
+	}
+	return c.out.done()
+}
+
+func (c *ctl) connect(args []string) error {
+	fs := flag.NewFlagSet("connect", flag.ContinueOnError)
+	id := fs.String("id", "", "")
+	endpoint := fs.String("endpoint", "", "")
+	allowedIps := fs.String("allowed-ips", "", "")
+	pq := fs.Bool("pq", false, "")
+	rest, err := parseFlags("connect", fs, args, 1, 1)
+	if err != nil {
+		return err
+	}
+
+	var (
+		identifier *string
+		allowed    *[]telio.IpNet
+		ep         *telio.SocketAddr
+	)
+	if *id != "" {
+		identifier = id
+	}
+	if *allowedIps != "" {
+		ips := splitList(*allowedIps)
+		allowed = &ips
+	}
+	if *endpoint != "" {
+		ep = endpoint
+	}
+
+	if *pq {
+		if ep == nil {
+			return errors.New("connect: -pq requires -endpoint")
+		}
+		err = c.dev.ConnectToExitNodePostquantum(identifier, rest[0], allowed, *ep)
+	} else {
+		err = c.dev.ConnectToExitNodeWithId(identifier, rest[0], allowed, ep)
+	}
+	if err != nil {
+		return err
+	}
+	return c.out.done()
+}
+
+func (c *ctl) disconnect(args []string) error {
+	var err error
+	switch len(args) {
+	case 0:
+		err = c.dev.DisconnectFromExitNodes()
+	case 1:
+		err = c.dev.DisconnectFromExitNode(args[0])
+	default:
+		return fmt.Errorf("usage: %s", commands["disconnect"].usage)
+	}
+	if err != nil {
+		return err
+	}
+	return c.out.done()
+}
+
+func (c *ctl) dns(args []string) error {
+	var err error
+	switch {
+	case len(args) >= 1 && args[0] == "on":
+		err = c.dev.EnableMagicDns(args[1:])
+	case len(args) == 1 && args[0] == "off":
+		err = c.dev.DisableMagicDns()
+	default:
+		return fmt.Errorf("usage: %s", commands["dns"].usage)
+	}
+	if err != nil {
+		return err
+	}
+	return c.out.done()
+}
+
+func (c *ctl) status(args []string) error {
+	return c.out.nodes(c.dev.GetStatusMap())
+}
+
+func (c *ctl) eventsCmd(args []string) error {
+	fs := flag.NewFlagSet("events", flag.ContinueOnError)
+	follow := fs.Bool("follow", false, "")
+	if _, err := parseFlags("events", fs, args, 0, 0); err != nil {
+		return err
+	}
+
+	for drained := false; !drained; {
+		select {
+		case e, ok := <-c.events:
+			if !ok {
+				return nil
+			}
+			if err := c.out.event(e); err != nil {
+				return err
+			}
+		default:
+			drained = true
+		}
+	}
+	if !*follow {
+		return nil
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case e, ok := <-c.events:
+			if !ok {
+				return nil
+			}
+			if err := c.out.event(e); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func (c *ctl) fwmark(args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("usage: %s", commands["fwmark"].usage)
+	}
+	mark, err := strconv.ParseUint(args[0], 0, 32)
+	if err != nil {
+		return fmt.Errorf("invalid fwmark: %w", err)
+	}
+	if err := c.dev.SetFwmark(uint32(mark)); err != nil {
+		return err
+	}
+	return c.out.done()
+}
+
+func (c *ctl) extIfFilter(args []string) error {
+	if err := c.dev.SetExtIfFilter(args); err != nil {
+		return err
+	}
+	return c.out.done()
+}
+
+func (c *ctl) tun(args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("usage: %s", commands["tun"].usage)
+	}
+	fd, err := strconv.ParseInt(args[0], 10, 32)
+	if err != nil {
+		return fmt.Errorf("invalid file descriptor: %w", err)
+	}
+	if err := c.dev.SetTun(int32(fd)); err != nil {
+		return err
+	}
+	return c.out.done()
+}
+
+func (c *ctl) tunnelSrcIP(args []string) error {
+	if err := c.dev.SetTunnelSrcIp(args); err != nil {
+		return err
+	}
+	return c.out.done()
+}
+
+func (c *ctl) networkChange(args []string) error {
+	if len(args) > 1 {
+		return fmt.Errorf("usage: %s", commands["network-change"].usage)
+	}
+	info := ""
+	if len(args) == 1 {
+		info = args[0]
+	}
+	if err := c.dev.NotifyNetworkChange(info); err != nil {
+		return err
+	}
+	return c.out.done()
+}
+
+type redirectFlags []telio.DnsRedirect
+
+func (r *redirectFlags) String() string {
+	return fmt.Sprint(*r)
+}
+
+func (r *redirectFlags) Set(s string) error {
+	blocking, standard, ok := strings.Cut(s, "=")
+	if !ok {
+		return fmt.Errorf("redirect %q is not BLOCKING=STANDARD", s)
+	}
+	*r = append(*r, telio.DnsRedirect{Blocking: blocking, Standard: standard})
+	return nil
+}
+
+func (c *ctl) tplite(args []string) error {
+	usage := fmt.Errorf("usage: %s", commands["tplite"].usage)
+	if len(args) == 0 {
+		return usage
+	}
+
+	var err error
+	switch args[0] {
+	case "whitelist":
+		fs := flag.NewFlagSet("tplite", flag.ContinueOnError)
+		var redirects redirectFlags
+		fs.Var(&redirects, "redirect", "")
+		rest, perr := parseFlags("tplite", fs, args[1:], 1, 1)
+		if perr != nil {
+			return perr
+		}
+		domains, rerr := readDomains(rest[0])
+		if rerr != nil {
+			return rerr
+		}
+		err = c.dev.SetTpLiteDomainWhitelist(domains, redirects)
+	case "stats":
+		switch {
+		case len(args) >= 3 && args[1] == "on":
+			err = c.dev.EnableTpLiteStatsCollection(telio.TpLiteStatsOptions{DnsServerIps: args[2:]}, statsPrinter{c.out})
+		case len(args) == 2 && args[1] == "off":
+			err = c.dev.DisableTpLiteStatsCollection()
+		default:
+			return usage
+		}
+	default:
+		return usage
+	}
+	if err != nil {
+		return err
+	}
+	return c.out.done()
+}
+
+// readDomains reads one domain per line from path, or from stdin for "-".
+func readDomains(path string) ([]string, error) {
+	r := io.Reader(os.Stdin)
+	if path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		r = f
+	}
+
+	var domains []string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		if d := strings.TrimSpace(scanner.Text()); d != "" && !strings.HasPrefix(d, "#") {
+			domains = append(domains, d)
+		}
+	}
+	return domains, scanner.Err()
+}
+
+type statsPrinter struct {
+	out *printer
+}
+
+func (s statsPrinter) Collect(domains []telio.BlockedDomain, metrics telio.DnsMetrics) {
+	stats := struct {
+		Domains []telio.BlockedDomain `json:"domains"`
+		Metrics telio.DnsMetrics      `json:"metrics"`
+	}{domains, metrics}
+	_ = s.out.print(stats, func(tw *tabwriter.Writer) {
+		fmt.Fprintf(tw, "tplite\trequests=%d\tresponses=%d\tcache_hits=%d\n", metrics.NumRequests, metrics.NumResponses, metrics.NumCacheHits)
+		for _, d := range domains {
+			fmt.Fprintf(tw, "blocked\t%s\t%s\t%d\n", d.DomainName, d.Category, d.Timestamp)
+		}
+	})
+}
+
+func (c *ctl) luid(args []string) error {
+	luid := c.dev.GetAdapterLuid()
+	return c.out.print(map[string]uint64{"luid": luid}, func(tw *tabwriter.Writer) {
+		fmt.Fprintln(tw, luid)
+	})
+}
+
+func (c *ctl) lastError(args []string) error {
+	msg := c.dev.GetLastError()
+	return c.out.print(map[string]string{"last_error": msg}, func(tw *tabwriter.Writer) {
+		fmt.Fprintln(tw, msg)
+	})
+}
+
+func (c *ctl) ping(args []string) error {
+	msg, err := c.dev.ReceivePing()
+	if err != nil {
+		return err
+	}
+	return c.out.print(map[string]string{"ping": msg}, func(tw *tabwriter.Writer) {
+		fmt.Fprintln(tw, msg)
+	})
+}
+
+func (c *ctl) panicCmd(args []string) error {
+	switch {
+	case len(args) == 1 && args[0] == "stack":
+		return c.dev.GenerateStackPanic()
+	case len(args) == 1 && args[0] == "thread":
+		return c.dev.GenerateThreadPanic()
+	}
+	return fmt.Errorf("usage: %s", commands["panic"].usage)
+}
@@ -0,0 +1,231 @@
+// Command telioctl drives libtelio interactively.
+//
+// Without a command telioctl reads commands from standard input, one per
+// line, so it can be used both as a REPL and with a piped script. With a
+// command it runs that command and exits:
+//
+//	telioctl -features features.json
+//	telio> start -adapter linux-native <secret-key>
+//	telio> meshnet on map.json
+//	telio> status
+//
+//	telioctl -o json gen-key
+//
+// Single features can be overridden with -telio.<path> flags or
+// TELIO_FEATURES_<PATH> environment variables, e.g.
+// -telio.direct.endpoint-interval-secs=5.
+//
+// Run "help" for the list of commands. StartCustom has none, it needs a
+// telio.TelioCustomAdapter implemented in Go, which a command line cannot
+// provide.
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	telio "github.com/NordSecurity/libtelio-go/v8"
+	"github.com/NordSecurity/libtelio-go/v8/events"
+	"github.com/NordSecurity/libtelio-go/v8/overlay"
+)
+
+const eventBufferSize = 1024
+
+func main() {
+	var (
+		featuresPath = flag.String("features", "", "path to the features JSON, defaults are used when empty")
+		output       = flag.String("o", "table", "output format: table or json")
+		logLevel     = flag.String("log-level", "", "forward libtelio logs of this level to stderr: error, warning, info, debug or trace")
+	)
+	ov := &overlay.Overlay{}
+	overrides := ov.RegisterFlags(flag.CommandLine)
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: telioctl [flags] [command [args...]]\n\nflags:\n")
+		flag.PrintDefaults()
+		fmt.Fprintf(flag.CommandLine.Output(), "\ncommands:\n")
+		printCommands(flag.CommandLine.Output(), false)
+	}
+	flag.Parse()
+
+	if err := run(*featuresPath, ov, overrides, *output, *logLevel, flag.Args()); err != nil {
+		fmt.Fprintln(os.Stderr, "telioctl:", err)
+		os.Exit(1)
+	}
+}
+
+func run(featuresPath string, ov *overlay.Overlay, overrides *overlay.Flags, output, logLevel string, args []string) error {
+	out, err := newPrinter(os.Stdout, output)
+	if err != nil {
+		return err
+	}
+
+	if logLevel != "" {
+		level, err := parseLogLevel(logLevel)
+		if err != nil {
+			return err
+		}
+		telio.SetGlobalLogger(level, stderrLogger{})
+		defer telio.UnsetGlobalLogger()
+	}
+
+	features := telio.GetDefaultFeatureConfig()
+	if featuresPath != "" {
+		data, err := os.ReadFile(featuresPath)
+		if err != nil {
+			return fmt.Errorf("reading features: %w", err)
+		}
+		if features, err = telio.DeserializeFeatureConfig(string(data)); err != nil {
+			return fmt.Errorf("parsing features: %w", err)
+		}
+	}
+	if err := overrideFeatures(&features, ov, overrides); err != nil {
+		return err
+	}
+
+	dispatcher := events.NewDispatcher()
+	evs, stopEvents := dispatcher.Channel(eventBufferSize)
+	defer stopEvents()
+
+	dev, err := telio.NewTelio(features, dispatcher)
+	if err != nil {
+		return fmt.Errorf("creating telio: %w", err)
+	}
+	ctl := &ctl{dev: dev, out: out, events: evs}
+	defer ctl.shutdown()
+
+	if len(args) > 0 {
+		return ctl.exec(args)
+	}
+	return ctl.repl(os.Stdin, isTerminal(os.Stdin))
+}
+
+// overrideFeatures applies the environment, then the flags, and reports
+// every overridden feature on stderr.
+func overrideFeatures(features *telio.Features, ov *overlay.Overlay, overrides *overlay.Flags) error {
+	enabled := enabledFeatures()
+	ov.Enabled = &enabled
+	fromEnv, err := ov.ApplyEnv(features, os.Environ())
+	if err != nil {
+		return fmt.Errorf("overriding features: %w", err)
+	}
+	fromFlags, err := overrides.Apply(features)
+	if err != nil {
+		return fmt.Errorf("overriding features: %w", err)
+	}
+	for _, o := range append(fromEnv, fromFlags...) {
+		fmt.Fprintln(os.Stderr, "telioctl: override", o)
+	}
+	return nil
+}
+
+// enabledFeatures returns the defaults of the optional sections an override
+// can turn on.
+func enabledFeatures() telio.Features {
+	b := telio.NewFeaturesDefaultsBuilder()
+	for _, enable := range []func(*telio.FeaturesDefaultsBuilder) *telio.FeaturesDefaultsBuilder{
+		(*telio.FeaturesDefaultsBuilder).EnableNurse,
+		(*telio.FeaturesDefaultsBuilder).EnableDirect,
+		(*telio.FeaturesDefaultsBuilder).EnableLinkDetection,
+	} {
+		next := enable(b)
+		b.Destroy()
+		b = next
+	}
+	defer b.Destroy()
+	return b.Build()
+}
+
+// repl executes the commands read from r until EOF or "exit".
+func (c *ctl) repl(r io.Reader, interactive bool) error {
+	c.inREPL = true
+	scanner := bufio.NewScanner(r)
+	for {
+		if interactive {
+			fmt.Fprint(os.Stderr, "telio> ")
+		}
+		if !scanner.Scan() {
+			return scanner.Err()
+		}
+
+		args, err := splitArgs(scanner.Text())
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "error:", err)
+			continue
+		}
+		if len(args) == 0 || strings.HasPrefix(args[0], "#") {
+			continue
+		}
+		if args[0] == "exit" || args[0] == "quit" {
+			return nil
+		}
+
+		if err := c.exec(args); err != nil {
+			if !interactive {
+				return err
+			}
+			fmt.Fprintln(os.Stderr, "error:", err)
+		}
+	}
+}
+
+// splitArgs splits a line into arguments, honoring single and double quotes
+// and backslash escapes.
+func splitArgs(line string) ([]string, error) {
+	var (
+		args    []string
+		cur     strings.Builder
+		inArg   bool
+		quote   rune
+		escaped bool
+	)
+	for _, r := range line {
+		switch {
+		case escaped:
+			cur.WriteRune(r)
+			escaped = false
+		case r == '\\' && quote != '\'':
+			escaped, inArg = true, true
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			} else {
+				cur.WriteRune(r)
+			}
+		case r == '\'' || r == '"':
+			quote, inArg = r, true
+		case r == ' ' || r == '\t':
+			if inArg {
+				args = append(args, cur.String())
+				cur.Reset()
+				inArg = false
+			}
+		default:
+			cur.WriteRune(r)
+			inArg = true
+		}
+	}
+	if quote != 0 || escaped {
+		return nil, errors.New("unterminated quote or escape")
+	}
+	if inArg {
+		args = append(args, cur.String())
+	}
+	return args, nil
+}
+
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+type stderrLogger struct{}
+
+func (stderrLogger) Log(level telio.TelioLogLevel, payload string) error {
+	fmt.Fprintf(os.Stderr, "[%s] %s\n", level, payload)
+	return nil
+}
@@ -0,0 +1,26 @@
+package main
+
+import (
+	"fmt"
+
+	telio "github.com/NordSecurity/libtelio-go/v8"
+)
+
+func parseAdapter(name string) (telio.TelioAdapterType, error) {
+	if name == "" || name == "default" {
+		return telio.GetDefaultAdapter(), nil
+	}
+	var a telio.TelioAdapterType
+	if err := a.UnmarshalText([]byte(name)); err != nil {
+		return 0, fmt.Errorf("unknown adapter %q", name)
+	}
+	return a, nil
+}
+
+func parseLogLevel(name string) (telio.TelioLogLevel, error) {
+	var level telio.TelioLogLevel
+	if err := level.UnmarshalText([]byte(name)); err != nil {
+		return 0, fmt.Errorf("unknown log level %q", name)
+	}
+	return level, nil
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"text/tabwriter"
+
+	telio "github.com/NordSecurity/libtelio-go/v8"
+)
+
+// printer writes the output of commands. It is safe for concurrent use:
+// libtelio callbacks such as statsPrinter print from their own goroutines
+// while the REPL runs commands.
+type printer struct {
+	mu   sync.Mutex
+	w    io.Writer
+	json bool
+}
+
+func newPrinter(w io.Writer, format string) (*printer, error) {
+	p := &printer{w: w}
+	return p, p.setFormat(format)
+}
+
+func (p *printer) setFormat(format string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	switch format {
+	case "table":
+		p.json = false
+	case "json":
+		p.json = true
+	default:
+		return fmt.Errorf("unknown output format %q", format)
+	}
+	return nil
+}
+
+// print writes v as JSON, or renders it with table in table mode.
+func (p *printer) print(v any, table func(tw *tabwriter.Writer)) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.json {
+		enc := json.NewEncoder(p.w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(v)
+	}
+	tw := tabwriter.NewWriter(p.w, 0, 4, 2, ' ', 0)
+	table(tw)
+	return tw.Flush()
+}
+
+// write runs f with the underlying writer, for output which is the same in
+// both formats.
+func (p *printer) write(f func(w io.Writer)) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	f(p.w)
+}
+
+// done reports a successful command without output.
+func (p *printer) done() error {
+	return p.print(map[string]bool{"ok": true}, func(tw *tabwriter.Writer) {
+		fmt.Fprintln(tw, "ok")
+	})
+}
+
+func (p *printer) nodes(nodes []telio.TelioNode) error {
+	return p.print(nodes, func(tw *tabwriter.Writer) {
+		fmt.Fprintln(tw, "PUBLIC KEY\tHOSTNAME\tSTATE\tPATH\tEXIT\tVPN\tIP ADDRESSES\tENDPOINT\tERROR")
+		for _, n := range nodes {
+			vpnErr := ""
+			if n.VpnConnectionError != nil {
+				vpnErr = n.VpnConnectionError.String()
+			}
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%t\t%t\t%s\t%s\t%s\n",
+				n.PublicKey,
+				deref(n.Hostname),
+				n.State,
+				n.Path,
+				n.IsExit,
+				n.IsVpn,
+				strings.Join(n.IpAddresses, ","),
+				deref(n.Endpoint),
+				vpnErr,
+			)
+		}
+	})
+}
+
+type eventJSON struct {
+	Type string `json:"type"`
+	Body any    `json:"body"`
+}
+
+func (p *printer) event(e telio.Event) error {
+	switch e := e.(type) {
+	case telio.EventNode:
+		return p.print(eventJSON{"node", e.Body}, func(tw *tabwriter.Writer) {
+			fmt.Fprintf(tw, "node\t%s\t%s\t%s\n", e.Body.PublicKey, e.Body.State, e.Body.Path)
+		})
+	case telio.EventRelay:
+		return p.print(eventJSON{"relay", e.Body}, func(tw *tabwriter.Writer) {
+			fmt.Fprintf(tw, "relay\t%s\t%s\t%s\n", e.Body.Hostname, e.Body.Ipv4, e.Body.ConnState)
+		})
+	case telio.EventError:
+		return p.print(eventJSON{"error", e.Body}, func(tw *tabwriter.Writer) {
+			fmt.Fprintf(tw, "error\t%s\t%s\n", e.Body.Level, e.Body.Msg)
+		})
+	}
+	return fmt.Errorf("unknown event %T", e)
+}
+
+func deref[T any](v *T) T {
+	var zero T
+	if v == nil {
+		return zero
+	}
+	return *v
+}
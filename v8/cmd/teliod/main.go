@@ -0,0 +1,128 @@
+// Command teliod owns a single telio instance and exposes it through the
+// control API on a UNIX socket.
+//
+//	teliod -socket /run/teliod/teliod.sock -allow-gid 1001
+//
+// See package control for the API. Privileged endpoints are served to root,
+// the user running teliod, and the users and groups passed with -allow-uid
+// and -allow-gid.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+
+	telio "github.com/NordSecurity/libtelio-go/v8"
+	"github.com/NordSecurity/libtelio-go/v8/control"
+	"github.com/NordSecurity/libtelio-go/v8/events"
+)
+
+func main() {
+	var (
+		socketPath   = flag.String("socket", "/run/teliod/teliod.sock", "path of the control socket")
+		socketMode   = flag.String("socket-mode", "0660", "permissions of the control socket")
+		featuresPath = flag.String("features", "", "path to the features JSON, defaults are used when empty")
+		allowUIDs    = flag.String("allow-uid", "", "comma separated users allowed to change state")
+		allowGIDs    = flag.String("allow-gid", "", "comma separated groups allowed to change state")
+		logLevel     = flag.String("log-level", "info", "libtelio log level: error, warning, info, debug or trace")
+	)
+	flag.Parse()
+
+	if err := run(*socketPath, *socketMode, *featuresPath, *allowUIDs, *allowGIDs, *logLevel); err != nil {
+		log.Fatalf("teliod: %v", err)
+	}
+}
+
+func run(socketPath, socketMode, featuresPath, allowUIDs, allowGIDs, logLevel string) error {
+	mode, err := strconv.ParseUint(socketMode, 8, 32)
+	if err != nil {
+		return fmt.Errorf("invalid socket mode: %w", err)
+	}
+	uids, err := parseIDs(allowUIDs)
+	if err != nil {
+		return fmt.Errorf("invalid -allow-uid: %w", err)
+	}
+	gids, err := parseIDs(allowGIDs)
+	if err != nil {
+		return fmt.Errorf("invalid -allow-gid: %w", err)
+	}
+	var level telio.TelioLogLevel
+	if err := level.UnmarshalText([]byte(logLevel)); err != nil {
+		return fmt.Errorf("invalid -log-level: %w", err)
+	}
+
+	features := telio.GetDefaultFeatureConfig()
+	if featuresPath != "" {
+		data, err := os.ReadFile(featuresPath)
+		if err != nil {
+			return fmt.Errorf("reading features: %w", err)
+		}
+		if features, err = telio.DeserializeFeatureConfig(string(data)); err != nil {
+			return fmt.Errorf("parsing features: %w", err)
+		}
+	}
+
+	dispatcher := events.NewDispatcher()
+	dev, err := telio.NewTelio(features, dispatcher)
+	if err != nil {
+		return fmt.Errorf("creating telio: %w", err)
+	}
+	defer func() {
+		if running, err := dev.IsRunning(); err == nil && running {
+			if err := dev.Stop(); err != nil {
+				log.Printf("stopping telio: %v", err)
+			}
+		}
+		if err := dev.Shutdown(); err != nil {
+			log.Printf("shutting down telio: %v", err)
+		}
+	}()
+
+	server := control.NewServer(dev, control.ServerOptions{AllowedUIDs: uids, AllowedGIDs: gids})
+	dispatcher.Subscribe(server.PublishEvent)
+	telio.SetGlobalLogger(level, logger{server})
+	defer telio.UnsetGlobalLogger()
+
+	l, err := control.Listen(socketPath, os.FileMode(mode))
+	if err != nil {
+		return fmt.Errorf("listening on %s: %w", socketPath, err)
+	}
+	defer os.Remove(socketPath)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	log.Printf("serving control API on %s", socketPath)
+	return server.Serve(ctx, l)
+}
+
+func parseIDs(s string) ([]uint32, error) {
+	var ids []uint32
+	for _, f := range strings.Split(s, ",") {
+		if f = strings.TrimSpace(f); f == "" {
+			continue
+		}
+		id, err := strconv.ParseUint(f, 10, 32)
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, uint32(id))
+	}
+	return ids, nil
+}
+
+// logger writes libtelio logs to stderr and forwards them to the log stream.
+type logger struct {
+	server *control.Server
+}
+
+func (l logger) Log(level telio.TelioLogLevel, payload string) error {
+	log.Printf("[%s] %s", level, payload)
+	return l.server.Log(level, payload)
+}
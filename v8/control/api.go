@@ -0,0 +1,258 @@
+// Package control implements a versioned JSON-over-HTTP control API for a
+// telio instance, meant to be served on a UNIX socket by a daemon.
+//
+// Every operation of telio.TelioInterface, except StartCustom which needs an
+// in-process adapter, is available as an endpoint under /v1. Reads use GET,
+// everything else uses POST with a JSON body. Events, logs and TP-Lite stats
+// are streamed as newline delimited JSON.
+//
+// State changing endpoints, and the ones exposing secrets or what other users
+// did (logs and TP-Lite stats), are only served to peers whose socket
+// credentials are permitted by ServerOptions.
+package control
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	telio "github.com/NordSecurity/libtelio-go/v8"
+)
+
+// APIVersion is the path prefix of the current API version.
+const APIVersion = "/v1"
+
+// Endpoints of the current API version.
+const (
+	PathVersion      = APIVersion + "/version"
+	PathRunning      = APIVersion + "/running"
+	PathStatus       = APIVersion + "/status"
+	PathPublicKey    = APIVersion + "/public-key"
+	PathSecretKey    = APIVersion + "/secret-key"
+	PathSetSecretKey = APIVersion + "/set-secret-key"
+	PathLastError    = APIVersion + "/last-error"
+	PathAdapterLuid  = APIVersion + "/adapter-luid"
+	PathPing         = APIVersion + "/ping"
+
+	PathStart        = APIVersion + "/start"
+	PathStop         = APIVersion + "/stop"
+	PathShutdown     = APIVersion + "/shutdown"
+	PathShutdownHard = APIVersion + "/shutdown-hard"
+
+	PathSetMeshnet    = APIVersion + "/meshnet/set"
+	PathSetMeshnetOff = APIVersion + "/meshnet/off"
+
+	PathConnect       = APIVersion + "/exit-nodes/connect"
+	PathDisconnect    = APIVersion + "/exit-nodes/disconnect"
+	PathDisconnectAll = APIVersion + "/exit-nodes/disconnect-all"
+
+	PathEnableMagicDns  = APIVersion + "/magic-dns/enable"
+	PathDisableMagicDns = APIVersion + "/magic-dns/disable"
+
+	PathSetFwmark           = APIVersion + "/fwmark"
+	PathSetExtIfFilter      = APIVersion + "/ext-if-filter"
+	PathSetTun              = APIVersion + "/tun"
+	PathSetTunnelSrcIp      = APIVersion + "/tunnel-src-ip"
+	PathNotifyNetwork       = APIVersion + "/notify/network-change"
+	PathNotifySleep         = APIVersion + "/notify/sleep"
+	PathNotifyWakeup        = APIVersion + "/notify/wakeup"
+	PathTriggerAnalytics    = APIVersion + "/trigger/analytics"
+	PathTriggerQos          = APIVersion + "/trigger/qos"
+	PathGenerateStackPanic  = APIVersion + "/panic/stack"
+	PathGenerateThreadPanic = APIVersion + "/panic/thread"
+
+	PathTpLiteWhitelist    = APIVersion + "/tplite/whitelist"
+	PathTpLiteStatsEnable  = APIVersion + "/tplite/stats/enable"
+	PathTpLiteStatsDisable = APIVersion + "/tplite/stats/disable"
+
+	PathStreamEvents      = APIVersion + "/stream/events"
+	PathStreamLogs        = APIVersion + "/stream/logs"
+	PathStreamTpLiteStats = APIVersion + "/stream/tplite-stats"
+)
+
+// Error is the body of every unsuccessful response.
+type Error struct {
+	// Human readable description
+	Message string `json:"error"`
+	// Name of the telio.TelioError variant, if the error came from libtelio
+	Kind string `json:"kind,omitempty"`
+}
+
+func (e *Error) Error() string {
+	if e.Kind != "" {
+		return fmt.Sprintf("%s (%s)", e.Message, e.Kind)
+	}
+	return e.Message
+}
+
+// Empty is the body of requests and responses without data.
+type Empty struct{}
+
+// VersionResponse is returned by PathVersion.
+type VersionResponse struct {
+	API     string `json:"api"`
+	Version string `json:"version"`
+	Commit  string `json:"commit"`
+}
+
+// RunningResponse is returned by PathRunning.
+type RunningResponse struct {
+	Running bool `json:"running"`
+}
+
+// KeyResponse is returned by PathPublicKey and PathSecretKey.
+type KeyResponse struct {
+	Key string `json:"key"`
+}
+
+// SetSecretKeyRequest is the body of PathSetSecretKey.
+type SetSecretKeyRequest struct {
+	SecretKey telio.SecretKey `json:"secret_key"`
+}
+
+// StringResponse is returned by PathLastError and PathPing.
+type StringResponse struct {
+	Value string `json:"value"`
+}
+
+// LuidResponse is returned by PathAdapterLuid.
+type LuidResponse struct {
+	Luid uint64 `json:"luid"`
+}
+
+// StartRequest is the body of PathStart. The start variant is picked from
+// the set fields: Tun, then ExtIfFilter, then Name.
+type StartRequest struct {
+	SecretKey telio.SecretKey `json:"secret_key"`
+	// Adapter name, see ParseAdapter. The platform default is used when empty.
+	Adapter     string   `json:"adapter,omitempty"`
+	Name        string   `json:"name,omitempty"`
+	Tun         *int32   `json:"tun,omitempty"`
+	ExtIfFilter []string `json:"ext_if_filter,omitempty"`
+}
+
+// SetMeshnetRequest is the body of PathSetMeshnet.
+type SetMeshnetRequest struct {
+	// Meshnet map, as returned by GET /v1/meshnet/machines/{machineIdentifier}/map
+	Config json.RawMessage `json:"config"`
+}
+
+// ConnectRequest is the body of PathConnect.
+type ConnectRequest struct {
+	Identifier *string           `json:"identifier,omitempty"`
+	PublicKey  telio.PublicKey   `json:"public_key"`
+	AllowedIps *[]telio.IpNet    `json:"allowed_ips,omitempty"`
+	Endpoint   *telio.SocketAddr `json:"endpoint,omitempty"`
+	// Connect with a post quantum tunnel, requires Endpoint
+	PostQuantum bool `json:"post_quantum,omitempty"`
+}
+
+// DisconnectRequest is the body of PathDisconnect.
+type DisconnectRequest struct {
+	PublicKey telio.PublicKey `json:"public_key"`
+}
+
+// MagicDnsRequest is the body of PathEnableMagicDns.
+type MagicDnsRequest struct {
+	ForwardServers []telio.IpAddr `json:"forward_servers"`
+}
+
+// FwmarkRequest is the body of PathSetFwmark.
+type FwmarkRequest struct {
+	Fwmark uint32 `json:"fwmark"`
+}
+
+// ExtIfFilterRequest is the body of PathSetExtIfFilter.
+type ExtIfFilterRequest struct {
+	Interfaces []string `json:"interfaces"`
+}
+
+// TunRequest is the body of PathSetTun. The descriptor must be valid in the
+// daemon process.
+type TunRequest struct {
+	Tun int32 `json:"tun"`
+}
+
+// TunnelSrcIpRequest is the body of PathSetTunnelSrcIp.
+type TunnelSrcIpRequest struct {
+	SrcIps []telio.IpAddr `json:"src_ips"`
+}
+
+// NetworkChangeRequest is the body of PathNotifyNetwork.
+type NetworkChangeRequest struct {
+	NetworkInfo string `json:"network_info"`
+}
+
+// TpLiteWhitelistRequest is the body of PathTpLiteWhitelist.
+type TpLiteWhitelistRequest struct {
+	Domains   []string            `json:"domains"`
+	Redirects []telio.DnsRedirect `json:"redirects"`
+}
+
+// TpLiteStatsRequest is the body of PathTpLiteStatsEnable.
+type TpLiteStatsRequest struct {
+	Options telio.TpLiteStatsOptions `json:"options"`
+}
+
+// Event is a message of PathStreamEvents.
+type Event struct {
+	// One of "node", "relay" or "error"
+	Type string `json:"type"`
+	// telio.TelioNode, telio.Server or telio.ErrorEvent respectively
+	Body json.RawMessage `json:"body"`
+}
+
+// LogEntry is a message of PathStreamLogs.
+type LogEntry struct {
+	Time    time.Time           `json:"time"`
+	Level   telio.TelioLogLevel `json:"level"`
+	Message string              `json:"message"`
+}
+
+// TpLiteStats is a message of PathStreamTpLiteStats.
+type TpLiteStats struct {
+	Time    time.Time             `json:"time"`
+	Domains []telio.BlockedDomain `json:"domains"`
+	Metrics telio.DnsMetrics      `json:"metrics"`
+}
+
+// ParseAdapter returns the adapter named "neptun", "boringtun",
+// "linux-native" or "windows-native", as accepted by
+// telio.TelioAdapterType.UnmarshalText. An empty name selects the platform
+// default. Custom adapters are implemented in Go and cannot be selected over
+// the API.
+func ParseAdapter(name string) (telio.TelioAdapterType, error) {
+	if name == "" {
+		return telio.GetDefaultAdapter(), nil
+	}
+	var a telio.TelioAdapterType
+	if err := a.UnmarshalText([]byte(name)); err != nil {
+		return 0, err
+	}
+	if a == telio.TelioAdapterTypeCustom {
+		return 0, fmt.Errorf("adapter %q is not supported", name)
+	}
+	return a, nil
+}
+
+func newEvent(e telio.Event) (Event, error) {
+	var (
+		kind string
+		body any
+	)
+	switch e := e.(type) {
+	case telio.EventNode:
+		kind, body = "node", e.Body
+	case telio.EventRelay:
+		kind, body = "relay", e.Body
+	case telio.EventError:
+		kind, body = "error", e.Body
+	default:
+		return Event{}, fmt.Errorf("unknown event %T", e)
+	}
+	raw, err := json.Marshal(body)
+	if err != nil {
+		return Event{}, err
+	}
+	return Event{Type: kind, Body: raw}, nil
+}
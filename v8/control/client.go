@@ -0,0 +1,110 @@
+package control
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+)
+
+// Client talks to a control Server over a UNIX socket.
+type Client struct {
+	http *http.Client
+}
+
+// NewClient creates a client for the server listening on socketPath.
+func NewClient(socketPath string) *Client {
+	var d net.Dialer
+	return &Client{http: &http.Client{
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				return d.DialContext(ctx, "unix", socketPath)
+			},
+		},
+	}}
+}
+
+// Get calls a read endpoint and decodes the response into resp.
+func (c *Client) Get(ctx context.Context, path string, resp any) error {
+	return c.do(ctx, http.MethodGet, path, nil, resp)
+}
+
+// Post calls a state changing endpoint with req as body and decodes the
+// response into resp, which may be nil.
+func (c *Client) Post(ctx context.Context, path string, req, resp any) error {
+	if req == nil {
+		req = Empty{}
+	}
+	data, err := json.Marshal(req)
+	if err != nil {
+		return fmt.Errorf("encoding request: %w", err)
+	}
+	return c.do(ctx, http.MethodPost, path, bytes.NewReader(data), resp)
+}
+
+// Stream calls a streaming endpoint and passes every message to fn until ctx
+// is done, the server closes the stream or fn fails.
+func (c *Client) Stream(ctx context.Context, path string, fn func(json.RawMessage) error) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://telio"+path, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return decodeError(resp)
+	}
+
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 0, 64<<10), maxRequestSize)
+	for scanner.Scan() {
+		if err := fn(json.RawMessage(bytes.Clone(scanner.Bytes()))); err != nil {
+			return err
+		}
+	}
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return scanner.Err()
+}
+
+func (c *Client) do(ctx context.Context, method, path string, body io.Reader, resp any) error {
+	req, err := http.NewRequestWithContext(ctx, method, "http://telio"+path, body)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	r, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer r.Body.Close()
+
+	if r.StatusCode != http.StatusOK {
+		return decodeError(r)
+	}
+	if resp == nil {
+		return nil
+	}
+	if err := json.NewDecoder(r.Body).Decode(resp); err != nil {
+		return fmt.Errorf("decoding response: %w", err)
+	}
+	return nil
+}
+
+func decodeError(r *http.Response) error {
+	var e Error
+	if err := json.NewDecoder(io.LimitReader(r.Body, 64<<10)).Decode(&e); err != nil || e.Message == "" {
+		return fmt.Errorf("unexpected status %s", r.Status)
+	}
+	return &e
+}
@@ -0,0 +1,41 @@
+package control
+
+import "sync"
+
+// hub broadcasts values to stream subscribers. Slow subscribers lose values
+// instead of blocking the publisher, which usually is a libtelio callback.
+type hub[T any] struct {
+	mu   sync.Mutex
+	subs map[chan T]struct{}
+}
+
+func newHub[T any]() *hub[T] {
+	return &hub[T]{subs: make(map[chan T]struct{})}
+}
+
+func (h *hub[T]) publish(v T) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for ch := range h.subs {
+		select {
+		case ch <- v:
+		default:
+		}
+	}
+}
+
+func (h *hub[T]) subscribe(size int) (<-chan T, func()) {
+	ch := make(chan T, size)
+	h.mu.Lock()
+	h.subs[ch] = struct{}{}
+	h.mu.Unlock()
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			h.mu.Lock()
+			delete(h.subs, ch)
+			h.mu.Unlock()
+		})
+	}
+}
@@ -0,0 +1,12 @@
+//go:build !unix
+
+package control
+
+import (
+	"net"
+	"os"
+)
+
+func listenUnix(path string, _ os.FileMode) (net.Listener, error) {
+	return net.Listen("unix", path)
+}
@@ -0,0 +1,24 @@
+//go:build unix
+
+package control
+
+import (
+	"net"
+	"os"
+	"sync"
+	"syscall"
+)
+
+// umaskMu serializes the umask changes of concurrent listenUnix calls.
+var umaskMu sync.Mutex
+
+// listenUnix binds the socket under a umask that leaves at most mode. The
+// umask is process wide, so files created meanwhile by other goroutines get
+// stricter permissions too.
+func listenUnix(path string, mode os.FileMode) (net.Listener, error) {
+	umaskMu.Lock()
+	defer umaskMu.Unlock()
+	old := syscall.Umask(int(0o777 &^ mode.Perm()))
+	defer syscall.Umask(old)
+	return net.Listen("unix", path)
+}
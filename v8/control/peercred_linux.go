@@ -0,0 +1,98 @@
+//go:build linux
+
+package control
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+func peerCredentials(conn net.Conn) (Credentials, error) {
+	uc, ok := conn.(*net.UnixConn)
+	if !ok {
+		return Credentials{}, fmt.Errorf("peer credentials need a UNIX socket, got %T", conn)
+	}
+	raw, err := uc.SyscallConn()
+	if err != nil {
+		return Credentials{}, err
+	}
+
+	var (
+		cred      *syscall.Ucred
+		groups    []uint32
+		credErr   error
+		groupsErr error
+	)
+	if err := raw.Control(func(fd uintptr) {
+		cred, credErr = syscall.GetsockoptUcred(int(fd), syscall.SOL_SOCKET, syscall.SO_PEERCRED)
+		groups, groupsErr = peerGroups(int(fd))
+	}); err != nil {
+		return Credentials{}, err
+	}
+	if credErr != nil {
+		return Credentials{}, fmt.Errorf("reading SO_PEERCRED: %w", credErr)
+	}
+	if errors.Is(groupsErr, unix.ENOPROTOOPT) {
+		// Kernels before 4.13 lack SO_PEERGROUPS
+		groups, groupsErr = procGroups(cred.Pid)
+	}
+	if groupsErr != nil {
+		return Credentials{}, fmt.Errorf("reading supplementary groups: %w", groupsErr)
+	}
+	return Credentials{Pid: cred.Pid, Uid: cred.Uid, Gid: cred.Gid, Groups: groups}, nil
+}
+
+// peerGroups reads the supplementary groups the peer had when connecting.
+func peerGroups(fd int) ([]uint32, error) {
+	buf := make([]uint32, 64)
+	for {
+		size := uint32(len(buf) * 4)
+		_, _, errno := unix.Syscall6(unix.SYS_GETSOCKOPT, uintptr(fd), unix.SOL_SOCKET, unix.SO_PEERGROUPS,
+			uintptr(unsafe.Pointer(&buf[0])), uintptr(unsafe.Pointer(&size)), 0)
+		switch {
+		case errno == unix.ERANGE:
+			// size holds the required length
+			buf = make([]uint32, size/4+1)
+		case errno != 0:
+			return nil, errno
+		default:
+			return buf[:size/4], nil
+		}
+	}
+}
+
+// procGroups reads the supplementary groups of a process from the Groups
+// line of /proc/<pid>/status.
+func procGroups(pid int32) ([]uint32, error) {
+	data, err := os.ReadFile(fmt.Sprintf("/proc/%d/status", pid))
+	if err != nil {
+		return nil, err
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		rest, ok := strings.CutPrefix(scanner.Text(), "Groups:")
+		if !ok {
+			continue
+		}
+		var groups []uint32
+		for _, f := range strings.Fields(rest) {
+			gid, err := strconv.ParseUint(f, 10, 32)
+			if err != nil {
+				return nil, fmt.Errorf("parsing group %q: %w", f, err)
+			}
+			groups = append(groups, uint32(gid))
+		}
+		return groups, nil
+	}
+	return nil, errors.New("no Groups line")
+}
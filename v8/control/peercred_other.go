@@ -0,0 +1,12 @@
+//go:build !linux
+
+package control
+
+import (
+	"errors"
+	"net"
+)
+
+func peerCredentials(conn net.Conn) (Credentials, error) {
+	return Credentials{}, errors.New("peer credentials are not supported on this platform")
+}
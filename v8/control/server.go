@@ -0,0 +1,437 @@
+package control
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"slices"
+	"time"
+
+	telio "github.com/NordSecurity/libtelio-go/v8"
+)
+
+const (
+	defaultStreamBufferSize = 256
+	maxRequestSize          = 16 << 20
+)
+
+// Credentials of the process on the other end of a control connection.
+type Credentials struct {
+	Pid int32
+	Uid uint32
+	Gid uint32
+	// Supplementary groups
+	Groups []uint32
+}
+
+type credentialsKey struct{}
+
+type peer struct {
+	cred Credentials
+	err  error
+}
+
+// CredentialsFromContext returns the peer credentials of the connection a
+// request was received on.
+func CredentialsFromContext(ctx context.Context) (Credentials, error) {
+	p, ok := ctx.Value(credentialsKey{}).(peer)
+	if !ok {
+		return Credentials{}, errors.New("no peer credentials for the connection")
+	}
+	return p.cred, p.err
+}
+
+// ServerOptions configure a Server.
+type ServerOptions struct {
+	// Users allowed to use privileged endpoints. Root and the user running
+	// the server are always allowed.
+	AllowedUIDs []uint32
+	// Groups allowed to use privileged endpoints, as primary or
+	// supplementary group
+	AllowedGIDs []uint32
+	// Number of messages buffered for every stream subscriber [default 256]
+	StreamBufferSize int
+}
+
+type handlerFunc func(r *http.Request) (any, error)
+
+type route struct {
+	method     string
+	privileged bool
+	handler    handlerFunc
+	stream     http.HandlerFunc
+}
+
+// Server serves the control API for a telio instance.
+//
+// Feed it with events through PublishEvent and register it as the global
+// logger so the corresponding streams have data.
+type Server struct {
+	dev    telio.TelioInterface
+	opts   ServerOptions
+	routes map[string]route
+
+	events *hub[Event]
+	logs   *hub[LogEntry]
+	stats  *hub[TpLiteStats]
+}
+
+var _ telio.TelioLoggerCb = (*Server)(nil)
+
+// NewServer creates a control server for dev.
+func NewServer(dev telio.TelioInterface, opts ServerOptions) *Server {
+	if opts.StreamBufferSize <= 0 {
+		opts.StreamBufferSize = defaultStreamBufferSize
+	}
+	s := &Server{
+		dev:    dev,
+		opts:   opts,
+		events: newHub[Event](),
+		logs:   newHub[LogEntry](),
+		stats:  newHub[TpLiteStats](),
+	}
+	s.routes = s.buildRoutes()
+	return s
+}
+
+// Listen removes a stale socket at path and listens on it, restricting the
+// socket file to mode. On Unix the socket is created with at most mode, so
+// there is no moment in which other users can connect.
+func Listen(path string, mode os.FileMode) (net.Listener, error) {
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("removing stale socket: %w", err)
+	}
+	l, err := listenUnix(path, mode)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(path, mode); err != nil {
+		l.Close()
+		return nil, fmt.Errorf("restricting socket: %w", err)
+	}
+	return l, nil
+}
+
+// Serve serves the API on l until ctx is done.
+func (s *Server) Serve(ctx context.Context, l net.Listener) error {
+	srv := &http.Server{
+		Handler:           s,
+		ReadHeaderTimeout: 10 * time.Second,
+		BaseContext:       func(net.Listener) context.Context { return ctx },
+		ConnContext: func(ctx context.Context, c net.Conn) context.Context {
+			cred, err := peerCredentials(c)
+			return context.WithValue(ctx, credentialsKey{}, peer{cred: cred, err: err})
+		},
+	}
+
+	errc := make(chan error, 1)
+	go func() { errc <- srv.Serve(l) }()
+
+	select {
+	case err := <-errc:
+		return err
+	case <-ctx.Done():
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := srv.Shutdown(shutdownCtx); err != nil {
+			srv.Close()
+		}
+		return nil
+	}
+}
+
+// PublishEvent forwards a libtelio event to the event stream subscribers.
+func (s *Server) PublishEvent(e telio.Event) {
+	if ev, err := newEvent(e); err == nil {
+		s.events.publish(ev)
+	}
+}
+
+// Log implements telio.TelioLoggerCb, forwarding log lines to the log stream
+// subscribers.
+func (s *Server) Log(level telio.TelioLogLevel, payload string) error {
+	s.logs.publish(LogEntry{Time: time.Now().UTC(), Level: level, Message: payload})
+	return nil
+}
+
+// ServeHTTP implements http.Handler.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	rt, ok := s.routes[r.URL.Path]
+	if !ok {
+		writeError(w, http.StatusNotFound, &Error{Message: "unknown endpoint " + r.URL.Path})
+		return
+	}
+	if r.Method != rt.method {
+		w.Header().Set("Allow", rt.method)
+		writeError(w, http.StatusMethodNotAllowed, &Error{Message: "use " + rt.method})
+		return
+	}
+	if rt.privileged {
+		if err := s.authorize(r.Context()); err != nil {
+			writeError(w, http.StatusForbidden, &Error{Message: err.Error()})
+			return
+		}
+	}
+
+	if rt.stream != nil {
+		rt.stream(w, r)
+		return
+	}
+	resp, err := rt.handler(r)
+	if err != nil {
+		status, body := errorResponse(err)
+		writeError(w, status, body)
+		return
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (s *Server) authorize(ctx context.Context) error {
+	cred, err := CredentialsFromContext(ctx)
+	if err != nil {
+		return fmt.Errorf("permission denied: %w", err)
+	}
+	if cred.Uid == 0 || cred.Uid == uint32(os.Geteuid()) ||
+		slices.Contains(s.opts.AllowedUIDs, cred.Uid) ||
+		slices.Contains(s.opts.AllowedGIDs, cred.Gid) {
+		return nil
+	}
+	for _, gid := range cred.Groups {
+		if slices.Contains(s.opts.AllowedGIDs, gid) {
+			return nil
+		}
+	}
+	return fmt.Errorf("permission denied for uid %d", cred.Uid)
+}
+
+func (s *Server) buildRoutes() map[string]route {
+	get := func(h handlerFunc) route { return route{method: http.MethodGet, handler: h} }
+	getPrivileged := func(h handlerFunc) route { return route{method: http.MethodGet, privileged: true, handler: h} }
+	post := func(h handlerFunc) route { return route{method: http.MethodPost, privileged: true, handler: h} }
+	stream := func(privileged bool, h http.HandlerFunc) route {
+		return route{method: http.MethodGet, privileged: privileged, stream: h}
+	}
+	dev := s.dev
+
+	return map[string]route{
+		PathVersion: get(func(*http.Request) (any, error) {
+			return VersionResponse{API: APIVersion, Version: telio.GetVersionTag(), Commit: telio.GetCommitSha()}, nil
+		}),
+		PathRunning: get(func(*http.Request) (any, error) {
+			running, err := dev.IsRunning()
+			return RunningResponse{Running: running}, err
+		}),
+		PathStatus: get(func(*http.Request) (any, error) {
+			return dev.GetStatusMap(), nil
+		}),
+		PathPublicKey: get(func(*http.Request) (any, error) {
+			return KeyResponse{Key: telio.GeneratePublicKey(dev.GetSecretKey())}, nil
+		}),
+		PathSecretKey: getPrivileged(func(*http.Request) (any, error) {
+			return KeyResponse{Key: dev.GetSecretKey()}, nil
+		}),
+		PathLastError: get(func(*http.Request) (any, error) {
+			return StringResponse{Value: dev.GetLastError()}, nil
+		}),
+		PathAdapterLuid: get(func(*http.Request) (any, error) {
+			return LuidResponse{Luid: dev.GetAdapterLuid()}, nil
+		}),
+		PathPing: getPrivileged(func(*http.Request) (any, error) {
+			msg, err := dev.ReceivePing()
+			return StringResponse{Value: msg}, err
+		}),
+
+		PathSetSecretKey: post(body(func(req SetSecretKeyRequest) error {
+			return dev.SetSecretKey(req.SecretKey)
+		})),
+		PathStart: post(body(func(req StartRequest) error {
+			adapter, err := ParseAdapter(req.Adapter)
+			if err != nil {
+				return badRequest(err)
+			}
+			switch {
+			case req.Tun != nil:
+				return dev.StartWithTun(req.SecretKey, adapter, *req.Tun)
+			case len(req.ExtIfFilter) > 0:
+				return dev.StartNamedExtIfFilter(req.SecretKey, adapter, req.Name, req.ExtIfFilter)
+			case req.Name != "":
+				return dev.StartNamed(req.SecretKey, adapter, req.Name)
+			}
+			return dev.Start(req.SecretKey, adapter)
+		})),
+		PathStop:         post(noBody(dev.Stop)),
+		PathShutdown:     post(noBody(dev.Shutdown)),
+		PathShutdownHard: post(noBody(dev.ShutdownHard)),
+
+		PathSetMeshnet: post(body(func(req SetMeshnetRequest) error {
+			cfg, err := telio.DeserializeMeshnetConfig(string(req.Config))
+			if err != nil {
+				return badRequest(err)
+			}
+			return dev.SetMeshnet(cfg)
+		})),
+		PathSetMeshnetOff: post(noBody(dev.SetMeshnetOff)),
+
+		PathConnect: post(body(func(req ConnectRequest) error {
+			if !req.PostQuantum {
+				return dev.ConnectToExitNodeWithId(req.Identifier, req.PublicKey, req.AllowedIps, req.Endpoint)
+			}
+			if req.Endpoint == nil {
+				return badRequest(errors.New("post quantum connections require an endpoint"))
+			}
+			return dev.ConnectToExitNodePostquantum(req.Identifier, req.PublicKey, req.AllowedIps, *req.Endpoint)
+		})),
+		PathDisconnect: post(body(func(req DisconnectRequest) error {
+			return dev.DisconnectFromExitNode(req.PublicKey)
+		})),
+		PathDisconnectAll: post(noBody(dev.DisconnectFromExitNodes)),
+
+		PathEnableMagicDns: post(body(func(req MagicDnsRequest) error {
+			return dev.EnableMagicDns(req.ForwardServers)
+		})),
+		PathDisableMagicDns: post(noBody(dev.DisableMagicDns)),
+
+		PathSetFwmark: post(body(func(req FwmarkRequest) error {
+			return dev.SetFwmark(req.Fwmark)
+		})),
+		PathSetExtIfFilter: post(body(func(req ExtIfFilterRequest) error {
+			return dev.SetExtIfFilter(req.Interfaces)
+		})),
+		PathSetTun: post(body(func(req TunRequest) error {
+			return dev.SetTun(req.Tun)
+		})),
+		PathSetTunnelSrcIp: post(body(func(req TunnelSrcIpRequest) error {
+			return dev.SetTunnelSrcIp(req.SrcIps)
+		})),
+		PathNotifyNetwork: post(body(func(req NetworkChangeRequest) error {
+			return dev.NotifyNetworkChange(req.NetworkInfo)
+		})),
+		PathNotifySleep:         post(noBody(dev.NotifySleep)),
+		PathNotifyWakeup:        post(noBody(dev.NotifyWakeup)),
+		PathTriggerAnalytics:    post(noBody(dev.TriggerAnalyticsEvent)),
+		PathTriggerQos:          post(noBody(dev.TriggerQosCollection)),
+		PathGenerateStackPanic:  post(noBody(dev.GenerateStackPanic)),
+		PathGenerateThreadPanic: post(noBody(dev.GenerateThreadPanic)),
+
+		PathTpLiteWhitelist: post(body(func(req TpLiteWhitelistRequest) error {
+			return dev.SetTpLiteDomainWhitelist(req.Domains, req.Redirects)
+		})),
+		PathTpLiteStatsEnable: post(body(func(req TpLiteStatsRequest) error {
+			return dev.EnableTpLiteStatsCollection(req.Options, statsCallback{s.stats})
+		})),
+		PathTpLiteStatsDisable: post(noBody(dev.DisableTpLiteStatsCollection)),
+
+		PathStreamEvents:      stream(false, streamHub(s.events, s.opts.StreamBufferSize)),
+		PathStreamLogs:        stream(true, streamHub(s.logs, s.opts.StreamBufferSize)),
+		PathStreamTpLiteStats: stream(true, streamHub(s.stats, s.opts.StreamBufferSize)),
+	}
+}
+
+type statsCallback struct {
+	stats *hub[TpLiteStats]
+}
+
+func (c statsCallback) Collect(domains []telio.BlockedDomain, metrics telio.DnsMetrics) {
+	c.stats.publish(TpLiteStats{Time: time.Now().UTC(), Domains: domains, Metrics: metrics})
+}
+
+type requestError struct {
+	err error
+}
+
+func (e requestError) Error() string { return e.err.Error() }
+func (e requestError) Unwrap() error { return e.err }
+
+func badRequest(err error) error {
+	return requestError{err}
+}
+
+func body[Req any](fn func(Req) error) handlerFunc {
+	return func(r *http.Request) (any, error) {
+		var req Req
+		dec := json.NewDecoder(io.LimitReader(r.Body, maxRequestSize))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&req); err != nil {
+			return nil, badRequest(fmt.Errorf("decoding request: %w", err))
+		}
+		return Empty{}, fn(req)
+	}
+}
+
+func noBody(fn func() error) handlerFunc {
+	return func(*http.Request) (any, error) {
+		return Empty{}, fn()
+	}
+}
+
+func streamHub[T any](h *hub[T], size int) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			writeError(w, http.StatusInternalServerError, &Error{Message: "streaming is not supported"})
+			return
+		}
+		ch, unsubscribe := h.subscribe(size)
+		defer unsubscribe()
+
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		w.Header().Set("Cache-Control", "no-store")
+		w.WriteHeader(http.StatusOK)
+		flusher.Flush()
+
+		enc := json.NewEncoder(w)
+		for {
+			select {
+			case <-r.Context().Done():
+				return
+			case v := <-ch:
+				if err := enc.Encode(v); err != nil {
+					return
+				}
+				flusher.Flush()
+			}
+		}
+	}
+}
+
+var telioErrorKinds = []struct {
+	target error
+	kind   string
+	status int
+}{
+	{telio.ErrTelioErrorInvalidKey, "InvalidKey", http.StatusBadRequest},
+	{telio.ErrTelioErrorBadConfig, "BadConfig", http.StatusBadRequest},
+	{telio.ErrTelioErrorInvalidString, "InvalidString", http.StatusBadRequest},
+	{telio.ErrTelioErrorAlreadyStarted, "AlreadyStarted", http.StatusConflict},
+	{telio.ErrTelioErrorNotStarted, "NotStarted", http.StatusConflict},
+	{telio.ErrTelioErrorLockError, "LockError", http.StatusInternalServerError},
+	{telio.ErrTelioErrorUnknownError, "UnknownError", http.StatusInternalServerError},
+}
+
+func errorResponse(err error) (int, *Error) {
+	var reqErr requestError
+	if errors.As(err, &reqErr) {
+		return http.StatusBadRequest, &Error{Message: err.Error()}
+	}
+	for _, k := range telioErrorKinds {
+		if errors.Is(err, k.target) {
+			return k.status, &Error{Message: err.Error(), Kind: k.kind}
+		}
+	}
+	return http.StatusInternalServerError, &Error{Message: err.Error()}
+}
+
+func writeError(w http.ResponseWriter, status int, e *Error) {
+	writeJSON(w, status, e)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,108 @@
+package control
+
+import (
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"testing"
+
+	telio "github.com/NordSecurity/libtelio-go/v8"
+)
+
+func TestAuthorizeSupplementaryGroups(t *testing.T) {
+	s := &Server{opts: ServerOptions{AllowedGIDs: []uint32{1001}}}
+	uid := uint32(os.Geteuid()) + 1
+	if uid == 0 {
+		uid = 4242
+	}
+	for _, tt := range []struct {
+		name string
+		cred Credentials
+		ok   bool
+	}{
+		{"primary group", Credentials{Uid: uid, Gid: 1001}, true},
+		{"supplementary group", Credentials{Uid: uid, Gid: 100, Groups: []uint32{27, 1001}}, true},
+		{"other groups", Credentials{Uid: uid, Gid: 100, Groups: []uint32{27}}, false},
+	} {
+		ctx := context.WithValue(context.Background(), credentialsKey{}, peer{cred: tt.cred})
+		if err := s.authorize(ctx); (err == nil) != tt.ok {
+			t.Errorf("%s: authorize = %v, want ok %v", tt.name, err, tt.ok)
+		}
+	}
+}
+
+func TestStreamsOfOtherUsersArePrivileged(t *testing.T) {
+	// Routes only bind the methods, nothing is called
+	s := NewServer(&telio.Telio{}, ServerOptions{})
+	for _, path := range []string{PathStreamLogs, PathStreamTpLiteStats} {
+		if !s.routes[path].privileged {
+			t.Errorf("%s is not privileged", path)
+		}
+	}
+}
+
+func TestListenModeAndPeerGroups(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("peer credentials are only read on Linux")
+	}
+	path := filepath.Join(t.TempDir(), "control.sock")
+	l, err := Listen(path, 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mode := info.Mode().Perm(); mode != 0o600 {
+		t.Fatalf("socket mode %o, want 600", mode)
+	}
+
+	accepted := make(chan net.Conn, 1)
+	go func() {
+		c, _ := l.Accept()
+		accepted <- c
+	}()
+	client, err := net.Dial("unix", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	conn := <-accepted
+	if conn == nil {
+		t.Fatal("accept failed")
+	}
+	defer conn.Close()
+
+	cred, err := peerCredentials(conn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	groups, err := os.Getgroups()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := make([]uint32, len(groups))
+	for i, g := range groups {
+		want[i] = uint32(g)
+	}
+	got := slices.Clone(cred.Groups)
+	slices.Sort(got)
+	slices.Sort(want)
+	if cred.Uid != uint32(os.Getuid()) || !slices.Equal(got, want) {
+		t.Fatalf("credentials %+v, want uid %d and groups %v", cred, os.Getuid(), want)
+	}
+
+	fromProc, err := procGroups(cred.Pid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	slices.Sort(fromProc)
+	if !slices.Equal(fromProc, want) {
+		t.Fatalf("groups from /proc %v, want %v", fromProc, want)
+	}
+}
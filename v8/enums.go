@@ -0,0 +1,325 @@
+package telio
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Names of the enum values as used in libtelio's JSON, indexed by value.
+
+var endpointProviderNames = []string{
+	EndpointProviderLocal: "local",
+	EndpointProviderStun:  "stun",
+	EndpointProviderUpnp:  "upnp",
+}
+
+var errorCodeNames = []string{
+	ErrorCodeNoError: "no_error",
+	ErrorCodeUnknown: "unknown",
+}
+
+var errorLevelNames = []string{
+	ErrorLevelCritical: "critical",
+	ErrorLevelSevere:   "severe",
+	ErrorLevelWarning:  "warning",
+	ErrorLevelNotice:   "notice",
+}
+
+var ipProtocolNames = []string{
+	IpProtocolUdp: "udp",
+	IpProtocolTcp: "tcp",
+}
+
+var linkStateNames = []string{
+	LinkStateDown: "down",
+	LinkStateUp:   "up",
+}
+
+var natTypeNames = []string{
+	NatTypeUdpBlocked:           "udp_blocked",
+	NatTypeOpenInternet:         "open_internet",
+	NatTypeSymmetricUdpFirewall: "symmetric_udp_firewall",
+	NatTypeFullCone:             "full_cone",
+	NatTypeRestrictedCone:       "restricted_cone",
+	NatTypePortRestrictedCone:   "port_restricted_cone",
+	NatTypeSymmetric:            "symmetric",
+	NatTypeUnknown:              "unknown",
+}
+
+var nodeStateNames = []string{
+	NodeStateDisconnected: "disconnected",
+	NodeStateConnecting:   "connecting",
+	NodeStateConnected:    "connected",
+}
+
+var pathTypeNames = []string{
+	PathTypeRelay:  "relay",
+	PathTypeDirect: "direct",
+}
+
+var relayStateNames = []string{
+	RelayStateDisconnected: "disconnected",
+	RelayStateConnecting:   "connecting",
+	RelayStateConnected:    "connected",
+}
+
+var rttTypeNames = []string{
+	RttTypePing: "ping",
+}
+
+var telioAdapterTypeNames = []string{
+	TelioAdapterTypeNepTun:           "neptun",
+	TelioAdapterTypeBoringTun:        "boringtun",
+	TelioAdapterTypeLinuxNativeTun:   "linux_native",
+	TelioAdapterTypeWindowsNativeTun: "windows_native",
+	TelioAdapterTypeCustom:           "custom",
+}
+
+var telioLogLevelNames = []string{
+	TelioLogLevelError:   "error",
+	TelioLogLevelWarning: "warning",
+	TelioLogLevelInfo:    "info",
+	TelioLogLevelDebug:   "debug",
+	TelioLogLevelTrace:   "trace",
+}
+
+var vpnConnectionErrorNames = []string{
+	VpnConnectionErrorUnknown:                "unknown",
+	VpnConnectionErrorConnectionLimitReached: "connection_limit_reached",
+	VpnConnectionErrorServerMaintenance:      "server_maintenance",
+	VpnConnectionErrorUnauthenticated:        "unauthenticated",
+	VpnConnectionErrorSuperseded:             "superseded",
+}
+
+func enumString(names []string, typ string, v uint) string {
+	if v < uint(len(names)) && names[v] != "" {
+		return names[v]
+	}
+	return fmt.Sprintf("%s(%d)", typ, v)
+}
+
+func enumMarshal(names []string, typ string, v uint) ([]byte, error) {
+	if v < uint(len(names)) && names[v] != "" {
+		return []byte(names[v]), nil
+	}
+	return nil, fmt.Errorf("invalid %s %d", typ, v)
+}
+
+// enumUnmarshal looks up a name ignoring case, accepting '-' for '_'.
+func enumUnmarshal(names []string, typ string, text []byte) (uint, error) {
+	name := strings.ReplaceAll(strings.ToLower(string(text)), "-", "_")
+	for v, n := range names {
+		if n != "" && n == name {
+			return uint(v), nil
+		}
+	}
+	return 0, fmt.Errorf("unknown %s %q", typ, text)
+}
+
+func (e EndpointProvider) String() string {
+	return enumString(endpointProviderNames, "EndpointProvider", uint(e))
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (e EndpointProvider) MarshalText() ([]byte, error) {
+	return enumMarshal(endpointProviderNames, "EndpointProvider", uint(e))
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (e *EndpointProvider) UnmarshalText(text []byte) error {
+	v, err := enumUnmarshal(endpointProviderNames, "EndpointProvider", text)
+	*e = EndpointProvider(v)
+	return err
+}
+
+func (e ErrorCode) String() string {
+	return enumString(errorCodeNames, "ErrorCode", uint(e))
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (e ErrorCode) MarshalText() ([]byte, error) {
+	return enumMarshal(errorCodeNames, "ErrorCode", uint(e))
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (e *ErrorCode) UnmarshalText(text []byte) error {
+	v, err := enumUnmarshal(errorCodeNames, "ErrorCode", text)
+	*e = ErrorCode(v)
+	return err
+}
+
+func (e ErrorLevel) String() string {
+	return enumString(errorLevelNames, "ErrorLevel", uint(e))
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (e ErrorLevel) MarshalText() ([]byte, error) {
+	return enumMarshal(errorLevelNames, "ErrorLevel", uint(e))
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (e *ErrorLevel) UnmarshalText(text []byte) error {
+	v, err := enumUnmarshal(errorLevelNames, "ErrorLevel", text)
+	*e = ErrorLevel(v)
+	return err
+}
+
+func (e IpProtocol) String() string {
+	return enumString(ipProtocolNames, "IpProtocol", uint(e))
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (e IpProtocol) MarshalText() ([]byte, error) {
+	return enumMarshal(ipProtocolNames, "IpProtocol", uint(e))
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (e *IpProtocol) UnmarshalText(text []byte) error {
+	v, err := enumUnmarshal(ipProtocolNames, "IpProtocol", text)
+	*e = IpProtocol(v)
+	return err
+}
+
+func (e LinkState) String() string {
+	return enumString(linkStateNames, "LinkState", uint(e))
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (e LinkState) MarshalText() ([]byte, error) {
+	return enumMarshal(linkStateNames, "LinkState", uint(e))
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (e *LinkState) UnmarshalText(text []byte) error {
+	v, err := enumUnmarshal(linkStateNames, "LinkState", text)
+	*e = LinkState(v)
+	return err
+}
+
+func (e NatType) String() string {
+	return enumString(natTypeNames, "NatType", uint(e))
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (e NatType) MarshalText() ([]byte, error) {
+	return enumMarshal(natTypeNames, "NatType", uint(e))
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (e *NatType) UnmarshalText(text []byte) error {
+	v, err := enumUnmarshal(natTypeNames, "NatType", text)
+	*e = NatType(v)
+	return err
+}
+
+func (e NodeState) String() string {
+	return enumString(nodeStateNames, "NodeState", uint(e))
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (e NodeState) MarshalText() ([]byte, error) {
+	return enumMarshal(nodeStateNames, "NodeState", uint(e))
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (e *NodeState) UnmarshalText(text []byte) error {
+	v, err := enumUnmarshal(nodeStateNames, "NodeState", text)
+	*e = NodeState(v)
+	return err
+}
+
+func (e PathType) String() string {
+	return enumString(pathTypeNames, "PathType", uint(e))
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (e PathType) MarshalText() ([]byte, error) {
+	return enumMarshal(pathTypeNames, "PathType", uint(e))
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (e *PathType) UnmarshalText(text []byte) error {
+	v, err := enumUnmarshal(pathTypeNames, "PathType", text)
+	*e = PathType(v)
+	return err
+}
+
+func (e RelayState) String() string {
+	return enumString(relayStateNames, "RelayState", uint(e))
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (e RelayState) MarshalText() ([]byte, error) {
+	return enumMarshal(relayStateNames, "RelayState", uint(e))
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (e *RelayState) UnmarshalText(text []byte) error {
+	v, err := enumUnmarshal(relayStateNames, "RelayState", text)
+	*e = RelayState(v)
+	return err
+}
+
+func (e RttType) String() string {
+	return enumString(rttTypeNames, "RttType", uint(e))
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (e RttType) MarshalText() ([]byte, error) {
+	return enumMarshal(rttTypeNames, "RttType", uint(e))
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (e *RttType) UnmarshalText(text []byte) error {
+	v, err := enumUnmarshal(rttTypeNames, "RttType", text)
+	*e = RttType(v)
+	return err
+}
+
+func (e TelioAdapterType) String() string {
+	return enumString(telioAdapterTypeNames, "TelioAdapterType", uint(e))
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (e TelioAdapterType) MarshalText() ([]byte, error) {
+	return enumMarshal(telioAdapterTypeNames, "TelioAdapterType", uint(e))
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (e *TelioAdapterType) UnmarshalText(text []byte) error {
+	v, err := enumUnmarshal(telioAdapterTypeNames, "TelioAdapterType", text)
+	*e = TelioAdapterType(v)
+	return err
+}
+
+func (e TelioLogLevel) String() string {
+	return enumString(telioLogLevelNames, "TelioLogLevel", uint(e))
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (e TelioLogLevel) MarshalText() ([]byte, error) {
+	return enumMarshal(telioLogLevelNames, "TelioLogLevel", uint(e))
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (e *TelioLogLevel) UnmarshalText(text []byte) error {
+	v, err := enumUnmarshal(telioLogLevelNames, "TelioLogLevel", text)
+	*e = TelioLogLevel(v)
+	return err
+}
+
+func (e VpnConnectionError) String() string {
+	return enumString(vpnConnectionErrorNames, "VpnConnectionError", uint(e))
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (e VpnConnectionError) MarshalText() ([]byte, error) {
+	return enumMarshal(vpnConnectionErrorNames, "VpnConnectionError", uint(e))
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (e *VpnConnectionError) UnmarshalText(text []byte) error {
+	v, err := enumUnmarshal(vpnConnectionErrorNames, "VpnConnectionError", text)
+	*e = VpnConnectionError(v)
+	return err
+}
@@ -0,0 +1,102 @@
+// Package events fans out the events libtelio delivers through a single
+// telio.TelioEventCb to any number of subscribers.
+package events
+
+import (
+	"sync"
+
+	telio "github.com/NordSecurity/libtelio-go/v8"
+)
+
+// Dispatcher is a telio.TelioEventCb which forwards every event to its
+// subscribers. Pass it to telio.NewTelio and subscribe the components
+// interested in events.
+//
+// Subscribers are called synchronously from the libtelio callback thread, in
+// the order of subscription, and must not block.
+type Dispatcher struct {
+	mu   sync.RWMutex
+	next uint64
+	subs map[uint64]func(telio.Event)
+	// Subscription order, so events are delivered deterministically
+	order []uint64
+}
+
+var _ telio.TelioEventCb = (*Dispatcher)(nil)
+
+// NewDispatcher creates a dispatcher without subscribers.
+func NewDispatcher() *Dispatcher {
+	return &Dispatcher{subs: make(map[uint64]func(telio.Event))}
+}
+
+// Event implements telio.TelioEventCb.
+func (d *Dispatcher) Event(payload telio.Event) error {
+	d.mu.RLock()
+	fns := make([]func(telio.Event), 0, len(d.order))
+	for _, id := range d.order {
+		fns = append(fns, d.subs[id])
+	}
+	d.mu.RUnlock()
+
+	for _, fn := range fns {
+		fn(payload)
+	}
+	return nil
+}
+
+// Subscribe registers fn for all following events. The returned function
+// removes the subscription and may be called more than once.
+func (d *Dispatcher) Subscribe(fn func(telio.Event)) (unsubscribe func()) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	id := d.next
+	d.next++
+	d.subs[id] = fn
+	d.order = append(d.order, id)
+
+	return func() {
+		d.mu.Lock()
+		defer d.mu.Unlock()
+		if _, ok := d.subs[id]; !ok {
+			return
+		}
+		delete(d.subs, id)
+		for i, o := range d.order {
+			if o == id {
+				d.order = append(d.order[:i], d.order[i+1:]...)
+				break
+			}
+		}
+	}
+}
+
+// Channel subscribes a buffered channel of the given size. Events which do
+// not fit into the buffer are dropped instead of blocking libtelio. The
+// returned function unsubscribes and closes the channel.
+func (d *Dispatcher) Channel(size int) (<-chan telio.Event, func()) {
+	ch := make(chan telio.Event, size)
+	var mu sync.Mutex
+	closed := false
+
+	unsubscribe := d.Subscribe(func(e telio.Event) {
+		mu.Lock()
+		defer mu.Unlock()
+		if closed {
+			return
+		}
+		select {
+		case ch <- e:
+		default:
+		}
+	})
+
+	return ch, func() {
+		unsubscribe()
+		mu.Lock()
+		defer mu.Unlock()
+		if !closed {
+			closed = true
+			close(ch)
+		}
+	}
+}
@@ -0,0 +1,407 @@
+// Package exitnode keeps a connection to a VPN exit node, failing over
+// between candidate servers when libtelio reports a problem.
+package exitnode
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	telio "github.com/NordSecurity/libtelio-go/v8"
+)
+
+const (
+	defaultConnectTimeout = 15 * time.Second
+	defaultMaxConnecting  = 30 * time.Second
+	defaultRetryDelay     = 5 * time.Second
+	eventQueueSize        = 256
+)
+
+// Connector is the part of telio.TelioInterface used by a Manager.
+type Connector interface {
+	ConnectToExitNodeWithId(identifier *string, publicKey telio.PublicKey, allowedIps *[]telio.IpNet, endpoint *telio.SocketAddr) error
+	ConnectToExitNodePostquantum(identifier *string, publicKey telio.PublicKey, allowedIps *[]telio.IpNet, endpoint telio.SocketAddr) error
+	DisconnectFromExitNode(publicKey telio.PublicKey) error
+}
+
+// Candidate is a VPN server which can be used as the exit node.
+type Candidate struct {
+	// Identifier passed to libtelio, generated by libtelio when empty
+	Identifier string
+	// Base64 encoded WireGuard public key of the server
+	PublicKey telio.PublicKey
+	// Endpoint of the server, must contain a port
+	Endpoint telio.SocketAddr
+	// Subnets routed to the server, nil is the same as "0.0.0.0/0"
+	AllowedIps []telio.IpNet
+	// Connect with a post quantum tunnel
+	PostQuantum bool
+}
+
+// Connect connects c to the exit node described by the candidate.
+func (cand Candidate) Connect(c Connector) error {
+	var id *string
+	if cand.Identifier != "" {
+		id = &cand.Identifier
+	}
+	var allowed *[]telio.IpNet
+	if cand.AllowedIps != nil {
+		allowed = &cand.AllowedIps
+	}
+	if cand.PostQuantum {
+		return c.ConnectToExitNodePostquantum(id, cand.PublicKey, allowed, cand.Endpoint)
+	}
+	endpoint := cand.Endpoint
+	return c.ConnectToExitNodeWithId(id, cand.PublicKey, allowed, &endpoint)
+}
+
+// Reason describes why a Manager failed over to the next candidate.
+type Reason int
+
+const (
+	// Connecting returned an error
+	ReasonConnectError Reason = iota + 1
+	// The exit node did not reach NodeStateConnected within Policy.ConnectTimeout
+	ReasonConnectTimeout
+	// The exit node stayed in NodeStateConnecting longer than Policy.MaxConnecting
+	ReasonConnectingTooLong
+	// libtelio reported a VpnConnectionError listed in Policy.FailoverOn
+	ReasonVpnConnectionError
+
+	// Internal: the retry delay after all candidates failed expired
+	reasonRetry Reason = -1
+)
+
+func (r Reason) String() string {
+	switch r {
+	case ReasonConnectError:
+		return "connect_error"
+	case ReasonConnectTimeout:
+		return "connect_timeout"
+	case ReasonConnectingTooLong:
+		return "connecting_too_long"
+	case ReasonVpnConnectionError:
+		return "vpn_connection_error"
+	}
+	return fmt.Sprintf("Reason(%d)", int(r))
+}
+
+// RefreshReason describes why the application should refresh its server list.
+type RefreshReason int
+
+const (
+	// A server announced maintenance, it is being removed from the server list
+	RefreshServerMaintenance RefreshReason = iota + 1
+	// A server rejected our credentials
+	RefreshUnauthenticated
+	// Every candidate failed
+	RefreshCandidatesExhausted
+)
+
+func (r RefreshReason) String() string {
+	switch r {
+	case RefreshServerMaintenance:
+		return "server_maintenance"
+	case RefreshUnauthenticated:
+		return "unauthenticated"
+	case RefreshCandidatesExhausted:
+		return "candidates_exhausted"
+	}
+	return fmt.Sprintf("RefreshReason(%d)", int(r))
+}
+
+// Failover describes a switch between two candidates.
+type Failover struct {
+	From   Candidate
+	To     Candidate
+	Reason Reason
+	// Set for ReasonVpnConnectionError
+	VpnError *telio.VpnConnectionError
+	// Set for ReasonConnectError
+	Err error
+}
+
+// Policy controls when a Manager fails over.
+type Policy struct {
+	// VPN errors which trigger a failover [default ServerMaintenance and ConnectionLimitReached]
+	FailoverOn []telio.VpnConnectionError
+	// How long a new connection may take to reach NodeStateConnected [default 15s]
+	ConnectTimeout time.Duration
+	// How long an established connection may stay in NodeStateConnecting or
+	// NodeStateDisconnected, a negative value disables the check [default 30s]
+	MaxConnecting time.Duration
+	// Delay before starting over with the first candidate once all failed [default 5s]
+	RetryDelay time.Duration
+}
+
+// Options configure a Manager.
+type Options struct {
+	Policy Policy
+	// Called when switching to another candidate
+	OnFailover func(Failover)
+	// Called when the application should refresh the candidate list and pass
+	// it to SetCandidates
+	OnRefreshNeeded func(RefreshReason)
+}
+
+// Status is a snapshot of the state of a Manager.
+type Status struct {
+	// Candidate currently in use, valid when Active is set
+	Current Candidate
+	Active  bool
+	// Last state libtelio reported for the current candidate
+	State telio.NodeState
+	// Last VPN error libtelio reported for the current candidate
+	VpnError *telio.VpnConnectionError
+}
+
+// Manager connects to the best candidate and fails over to the next one
+// according to its Policy. Feed it with events through Observe.
+//
+// Calls into libtelio are made from the goroutine running Run, never from the
+// event callback.
+type Manager struct {
+	conn   Connector
+	opts   Options
+	events chan telio.TelioNode
+	update chan []Candidate
+
+	mu     sync.Mutex
+	status Status
+}
+
+// NewManager creates a manager using c to connect.
+func NewManager(c Connector, opts Options) *Manager {
+	p := &opts.Policy
+	if p.FailoverOn == nil {
+		p.FailoverOn = []telio.VpnConnectionError{telio.VpnConnectionErrorServerMaintenance, telio.VpnConnectionErrorConnectionLimitReached}
+	}
+	if p.ConnectTimeout <= 0 {
+		p.ConnectTimeout = defaultConnectTimeout
+	}
+	if p.MaxConnecting == 0 {
+		p.MaxConnecting = defaultMaxConnecting
+	}
+	if p.RetryDelay <= 0 {
+		p.RetryDelay = defaultRetryDelay
+	}
+	return &Manager{
+		conn:   c,
+		opts:   opts,
+		events: make(chan telio.TelioNode, eventQueueSize),
+		update: make(chan []Candidate, 1),
+	}
+}
+
+// Observe forwards exit node events to the manager. It never blocks.
+func (m *Manager) Observe(event telio.Event) {
+	e, ok := event.(telio.EventNode)
+	if !ok || !(e.Body.IsExit || e.Body.IsVpn) {
+		return
+	}
+	select {
+	case m.events <- e.Body:
+	default:
+	}
+}
+
+// SetCandidates replaces the candidate list. The current connection is kept
+// if its candidate is still listed, otherwise the manager switches to the
+// first new candidate.
+func (m *Manager) SetCandidates(candidates []Candidate) {
+	for {
+		select {
+		case m.update <- candidates:
+			return
+		default:
+		}
+		// Only the latest list matters
+		select {
+		case <-m.update:
+		default:
+		}
+	}
+}
+
+// Status returns the current state of the manager.
+func (m *Manager) Status() Status {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.status
+}
+
+type session struct {
+	m          *Manager
+	candidates []Candidate
+	idx        int
+	active     bool
+	connected  bool
+	timer      *time.Timer
+	reason     Reason
+}
+
+// Run connects to the first of candidates and keeps an exit node connected
+// until ctx is done, when the exit node is disconnected.
+func (m *Manager) Run(ctx context.Context, candidates []Candidate) error {
+	s := &session{m: m, timer: time.NewTimer(0)}
+	<-s.timer.C
+	defer s.timer.Stop()
+	defer s.disconnect()
+
+	s.candidates = candidates
+	s.connect(0)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case c := <-m.update:
+			s.replace(c)
+		case node := <-m.events:
+			s.handle(node)
+		case <-s.timer.C:
+			s.expired()
+		}
+	}
+}
+
+func (s *session) current() Candidate {
+	return s.candidates[s.idx]
+}
+
+// connect connects to candidate idx, moving on to the next ones while
+// connecting fails.
+func (s *session) connect(idx int) {
+	s.disconnect()
+	for ; idx < len(s.candidates); idx++ {
+		s.idx = idx
+		cand := s.current()
+		if err := cand.Connect(s.m.conn); err != nil {
+			s.notifyFailover(cand, idx+1, ReasonConnectError, nil, err)
+			continue
+		}
+		s.active, s.connected = true, false
+		s.setStatus(Status{Current: cand, Active: true, State: telio.NodeStateConnecting})
+		s.arm(s.m.opts.Policy.ConnectTimeout, ReasonConnectTimeout)
+		return
+	}
+
+	s.setStatus(Status{})
+	s.refresh(RefreshCandidatesExhausted)
+	if len(s.candidates) > 0 {
+		s.arm(s.m.opts.Policy.RetryDelay, reasonRetry)
+	}
+}
+
+func (s *session) disconnect() {
+	s.disarm()
+	if !s.active {
+		return
+	}
+	_ = s.m.conn.DisconnectFromExitNode(s.current().PublicKey)
+	s.active = false
+}
+
+func (s *session) failover(reason Reason, vpnErr *telio.VpnConnectionError) {
+	from := s.current()
+	next := s.idx + 1
+	s.notifyFailover(from, next, reason, vpnErr, nil)
+	s.connect(next)
+}
+
+func (s *session) notifyFailover(from Candidate, next int, reason Reason, vpnErr *telio.VpnConnectionError, err error) {
+	if s.m.opts.OnFailover == nil || next >= len(s.candidates) {
+		return
+	}
+	s.m.opts.OnFailover(Failover{From: from, To: s.candidates[next], Reason: reason, VpnError: vpnErr, Err: err})
+}
+
+func (s *session) replace(candidates []Candidate) {
+	if s.active {
+		cur := s.current()
+		for i, c := range candidates {
+			if c.PublicKey == cur.PublicKey && c.Endpoint == cur.Endpoint {
+				s.candidates, s.idx = candidates, i
+				return
+			}
+		}
+		s.disconnect()
+	}
+	s.candidates = candidates
+	s.connect(0)
+}
+
+func (s *session) handle(node telio.TelioNode) {
+	if !s.active || node.PublicKey != s.current().PublicKey {
+		return
+	}
+	s.m.mu.Lock()
+	s.m.status.State = node.State
+	s.m.status.VpnError = node.VpnConnectionError
+	s.m.mu.Unlock()
+
+	if vpnErr := node.VpnConnectionError; vpnErr != nil {
+		switch *vpnErr {
+		case telio.VpnConnectionErrorServerMaintenance:
+			s.refresh(RefreshServerMaintenance)
+		case telio.VpnConnectionErrorUnauthenticated:
+			s.refresh(RefreshUnauthenticated)
+		}
+		for _, e := range s.m.opts.Policy.FailoverOn {
+			if e == *vpnErr {
+				s.failover(ReasonVpnConnectionError, vpnErr)
+				return
+			}
+		}
+	}
+
+	switch node.State {
+	case telio.NodeStateConnected:
+		s.connected = true
+		s.disarm()
+	case telio.NodeStateConnecting, telio.NodeStateDisconnected:
+		if s.connected && s.reason == 0 && s.m.opts.Policy.MaxConnecting > 0 {
+			s.arm(s.m.opts.Policy.MaxConnecting, ReasonConnectingTooLong)
+		}
+	}
+}
+
+func (s *session) expired() {
+	reason := s.reason
+	s.reason = 0
+	switch {
+	case reason == reasonRetry:
+		s.connect(0)
+	case reason != 0 && s.active:
+		s.failover(reason, nil)
+	}
+}
+
+func (s *session) arm(d time.Duration, reason Reason) {
+	s.disarm()
+	s.reason = reason
+	s.timer.Reset(d)
+}
+
+func (s *session) disarm() {
+	if !s.timer.Stop() {
+		select {
+		case <-s.timer.C:
+		default:
+		}
+	}
+	s.reason = 0
+}
+
+func (s *session) refresh(reason RefreshReason) {
+	if s.m.opts.OnRefreshNeeded != nil {
+		s.m.opts.OnRefreshNeeded(reason)
+	}
+}
+
+func (s *session) setStatus(status Status) {
+	s.m.mu.Lock()
+	s.m.status = status
+	s.m.mu.Unlock()
+}
@@ -0,0 +1,198 @@
+package exitnode
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	telio "github.com/NordSecurity/libtelio-go/v8"
+)
+
+// ErrPostQuantumUnavailable is returned when a post quantum tunnel is
+// mandatory but could not be established.
+var ErrPostQuantumUnavailable = errors.New("post quantum tunnel unavailable")
+
+// Mode is the kind of tunnel used to an exit node.
+type Mode int
+
+const (
+	// Not connected
+	ModeNone Mode = iota
+	// Classic WireGuard tunnel
+	ModeClassic
+	// Quantum resistant tunnel
+	ModePostQuantum
+)
+
+func (m Mode) String() string {
+	switch m {
+	case ModeNone:
+		return "none"
+	case ModeClassic:
+		return "classic"
+	case ModePostQuantum:
+		return "post_quantum"
+	}
+	return fmt.Sprintf("Mode(%d)", int(m))
+}
+
+// ModeChange is reported whenever the tunnel mode changes.
+type ModeChange struct {
+	Candidate Candidate
+	Mode      Mode
+	// Set when the classic tunnel is used because the post quantum one failed
+	Fallback bool
+	// Why the post quantum tunnel failed, set together with Fallback
+	Err error
+}
+
+// PostQuantumOptions configure a PostQuantumConnector.
+type PostQuantumOptions struct {
+	// Fail instead of falling back to a classic tunnel
+	Mandatory bool
+	// How long each attempt may take to reach NodeStateConnected [default 15s]
+	Timeout time.Duration
+	// Called when the mode changes, e.g. to show a "quantum resistant" badge
+	OnModeChange func(ModeChange)
+}
+
+// PostQuantumConnector connects to exit nodes over a post quantum tunnel,
+// falling back to a classic one unless the post quantum tunnel is mandatory.
+// Feed it with events through Observe.
+type PostQuantumConnector struct {
+	conn   Connector
+	opts   PostQuantumOptions
+	events chan telio.TelioNode
+
+	mu      sync.Mutex
+	mode    Mode
+	current Candidate
+}
+
+// NewPostQuantumConnector creates a connector using c to connect.
+func NewPostQuantumConnector(c Connector, opts PostQuantumOptions) *PostQuantumConnector {
+	if opts.Timeout <= 0 {
+		opts.Timeout = defaultConnectTimeout
+	}
+	return &PostQuantumConnector{
+		conn:   c,
+		opts:   opts,
+		events: make(chan telio.TelioNode, eventQueueSize),
+	}
+}
+
+// Observe forwards exit node events to the connector. It never blocks.
+func (p *PostQuantumConnector) Observe(event telio.Event) {
+	e, ok := event.(telio.EventNode)
+	if !ok || !(e.Body.IsExit || e.Body.IsVpn) {
+		return
+	}
+	select {
+	case p.events <- e.Body:
+	default:
+	}
+}
+
+// Mode returns the mode of the current tunnel.
+func (p *PostQuantumConnector) Mode() Mode {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.mode
+}
+
+// Connect connects to cand over a post quantum tunnel and waits until it is
+// up. When that fails and the policy allows it, the classic tunnel is tried.
+// The PostQuantum field of cand is ignored.
+//
+// Connect must not be called concurrently.
+func (p *PostQuantumConnector) Connect(ctx context.Context, cand Candidate) (Mode, error) {
+	p.drain()
+
+	cand.PostQuantum = true
+	pqErr := p.attempt(ctx, cand)
+	if pqErr == nil {
+		p.setMode(ModeChange{Candidate: cand, Mode: ModePostQuantum})
+		return ModePostQuantum, nil
+	}
+	_ = p.conn.DisconnectFromExitNode(cand.PublicKey)
+	if ctx.Err() != nil {
+		p.setMode(ModeChange{})
+		return ModeNone, ctx.Err()
+	}
+	if p.opts.Mandatory {
+		p.setMode(ModeChange{})
+		return ModeNone, fmt.Errorf("%w: %w", ErrPostQuantumUnavailable, pqErr)
+	}
+
+	p.drain()
+	cand.PostQuantum = false
+	if err := p.attempt(ctx, cand); err != nil {
+		_ = p.conn.DisconnectFromExitNode(cand.PublicKey)
+		p.setMode(ModeChange{})
+		return ModeNone, fmt.Errorf("classic fallback after %v: %w", pqErr, err)
+	}
+	p.setMode(ModeChange{Candidate: cand, Mode: ModeClassic, Fallback: true, Err: pqErr})
+	return ModeClassic, nil
+}
+
+// Disconnect disconnects from the current exit node.
+func (p *PostQuantumConnector) Disconnect() error {
+	p.mu.Lock()
+	cur, mode := p.current, p.mode
+	p.mu.Unlock()
+	if mode == ModeNone {
+		return nil
+	}
+	p.setMode(ModeChange{})
+	return p.conn.DisconnectFromExitNode(cur.PublicKey)
+}
+
+// attempt connects to cand and waits for NodeStateConnected.
+func (p *PostQuantumConnector) attempt(ctx context.Context, cand Candidate) error {
+	if err := cand.Connect(p.conn); err != nil {
+		return err
+	}
+	t := time.NewTimer(p.opts.Timeout)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-t.C:
+			return fmt.Errorf("not connected within %s", p.opts.Timeout)
+		case node := <-p.events:
+			if node.PublicKey != cand.PublicKey {
+				continue
+			}
+			if node.VpnConnectionError != nil {
+				return fmt.Errorf("vpn connection error %v", *node.VpnConnectionError)
+			}
+			if node.State == telio.NodeStateConnected {
+				return nil
+			}
+		}
+	}
+}
+
+// drain drops events left over from a previous attempt.
+func (p *PostQuantumConnector) drain() {
+	for {
+		select {
+		case <-p.events:
+		default:
+			return
+		}
+	}
+}
+
+func (p *PostQuantumConnector) setMode(change ModeChange) {
+	p.mu.Lock()
+	changed := p.mode != change.Mode || p.current.PublicKey != change.Candidate.PublicKey
+	p.mode, p.current = change.Mode, change.Candidate
+	p.mu.Unlock()
+	if changed && p.opts.OnModeChange != nil {
+		p.opts.OnModeChange(change)
+	}
+}
@@ -0,0 +1,278 @@
+package exitnode
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	defaultProbeSamples     = 5
+	defaultProbeInterval    = 100 * time.Millisecond
+	defaultProbeTimeout     = time.Second
+	defaultProbeConcurrency = 8
+)
+
+// ErrNoReachableCandidate is returned when no candidate answered a probe.
+var ErrNoReachableCandidate = errors.New("no reachable candidate")
+
+// Transport measures a single round trip to a candidate.
+type Transport interface {
+	RoundTrip(ctx context.Context, cand Candidate) (time.Duration, error)
+}
+
+// ProbeOptions configure a Prober.
+type ProbeOptions struct {
+	// Round trips measured per candidate [default 5]
+	Samples int
+	// Delay between two round trips to the same candidate [default 100ms]
+	Interval time.Duration
+	// How long to wait for a single response [default 1s]
+	Timeout time.Duration
+	// Candidates probed in parallel [default 8]
+	Concurrency int
+}
+
+// ProbeResult holds the measurements of a single candidate.
+type ProbeResult struct {
+	Candidate Candidate
+	// Successful round trips in the order they were measured
+	RTTs []time.Duration
+	// Median of RTTs
+	Median time.Duration
+	// Mean difference between consecutive RTTs
+	Jitter time.Duration
+	// Probes which failed or timed out
+	Lost int
+	// Last probe error, set when no probe succeeded
+	Err error
+}
+
+// Reachable reports whether at least one probe succeeded.
+func (r ProbeResult) Reachable() bool {
+	return len(r.RTTs) > 0
+}
+
+func (r ProbeResult) score() time.Duration {
+	return r.Median + r.Jitter
+}
+
+// Prober ranks candidates by their round trip time.
+type Prober struct {
+	transport Transport
+	opts      ProbeOptions
+}
+
+// NewProber creates a prober measuring round trips with t.
+func NewProber(t Transport, opts ProbeOptions) *Prober {
+	if opts.Samples <= 0 {
+		opts.Samples = defaultProbeSamples
+	}
+	if opts.Interval <= 0 {
+		opts.Interval = defaultProbeInterval
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = defaultProbeTimeout
+	}
+	if opts.Concurrency <= 0 {
+		opts.Concurrency = defaultProbeConcurrency
+	}
+	return &Prober{transport: t, opts: opts}
+}
+
+// Probe measures every candidate and returns the results ranked best first.
+// Candidates are ranked by the sum of their median round trip time and
+// jitter, then by the number of lost probes. Unreachable candidates come last
+// in their original order.
+func (p *Prober) Probe(ctx context.Context, candidates []Candidate) []ProbeResult {
+	results := make([]ProbeResult, len(candidates))
+	sem := make(chan struct{}, p.opts.Concurrency)
+	var wg sync.WaitGroup
+	for i, cand := range candidates {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, cand Candidate) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			results[i] = p.probe(ctx, cand)
+		}(i, cand)
+	}
+	wg.Wait()
+
+	Rank(results)
+	return results
+}
+
+// Best probes candidates and returns the best reachable one along with every
+// result.
+func (p *Prober) Best(ctx context.Context, candidates []Candidate) (Candidate, []ProbeResult, error) {
+	results := p.Probe(ctx, candidates)
+	if len(results) == 0 || !results[0].Reachable() {
+		if err := ctx.Err(); err != nil {
+			return Candidate{}, results, err
+		}
+		return Candidate{}, results, ErrNoReachableCandidate
+	}
+	return results[0].Candidate, results, nil
+}
+
+// Ranked probes candidates and returns them reordered best first, ready to be
+// passed to Manager.Run or Manager.SetCandidates. Unreachable candidates are
+// kept at the end since probes may be filtered while the tunnel is not.
+func (p *Prober) Ranked(ctx context.Context, candidates []Candidate) []Candidate {
+	results := p.Probe(ctx, candidates)
+	ranked := make([]Candidate, len(results))
+	for i, r := range results {
+		ranked[i] = r.Candidate
+	}
+	return ranked
+}
+
+// ConnectBest probes candidates and connects c to the best one.
+func (p *Prober) ConnectBest(ctx context.Context, c Connector, candidates []Candidate) (Candidate, error) {
+	best, _, err := p.Best(ctx, candidates)
+	if err != nil {
+		return Candidate{}, err
+	}
+	if err := best.Connect(c); err != nil {
+		return Candidate{}, fmt.Errorf("connecting to %s: %w", best.Endpoint, err)
+	}
+	return best, nil
+}
+
+func (p *Prober) probe(ctx context.Context, cand Candidate) ProbeResult {
+	result := ProbeResult{Candidate: cand}
+	for i := 0; i < p.opts.Samples; i++ {
+		if i > 0 {
+			t := time.NewTimer(p.opts.Interval)
+			select {
+			case <-ctx.Done():
+				t.Stop()
+			case <-t.C:
+			}
+		}
+		if err := ctx.Err(); err != nil {
+			result.Lost += p.opts.Samples - i
+			result.Err = err
+			break
+		}
+
+		sctx, cancel := context.WithTimeout(ctx, p.opts.Timeout)
+		rtt, err := p.transport.RoundTrip(sctx, cand)
+		cancel()
+		if err != nil {
+			result.Lost++
+			result.Err = err
+			continue
+		}
+		result.RTTs = append(result.RTTs, rtt)
+	}
+
+	if result.Reachable() {
+		result.Err = nil
+		result.Median = median(result.RTTs)
+		result.Jitter = jitter(result.RTTs)
+	}
+	return result
+}
+
+// Rank sorts results best first, see Prober.Probe.
+func Rank(results []ProbeResult) {
+	sort.SliceStable(results, func(i, j int) bool {
+		a, b := results[i], results[j]
+		if a.Reachable() != b.Reachable() {
+			return a.Reachable()
+		}
+		if !a.Reachable() {
+			return false
+		}
+		if a.score() != b.score() {
+			return a.score() < b.score()
+		}
+		return a.Lost < b.Lost
+	})
+}
+
+func median(rtts []time.Duration) time.Duration {
+	sorted := append([]time.Duration(nil), rtts...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	n := len(sorted)
+	if n%2 == 1 {
+		return sorted[n/2]
+	}
+	return (sorted[n/2-1] + sorted[n/2]) / 2
+}
+
+func jitter(rtts []time.Duration) time.Duration {
+	if len(rtts) < 2 {
+		return 0
+	}
+	var sum time.Duration
+	for i := 1; i < len(rtts); i++ {
+		d := rtts[i] - rtts[i-1]
+		if d < 0 {
+			d = -d
+		}
+		sum += d
+	}
+	return sum / time.Duration(len(rtts)-1)
+}
+
+// UDPTransport sends Payload to the candidate endpoint and measures the time
+// until a response arrives from it. It suits servers running an echo or
+// status responder next to WireGuard, and local stand-ins.
+type UDPTransport struct {
+	// Datagram sent to the endpoint, a single zero byte when empty
+	Payload []byte
+	// Reports whether a datagram is the response to Payload, every datagram
+	// is accepted when nil
+	Match func(resp []byte) bool
+}
+
+// RoundTrip implements Transport.
+func (t *UDPTransport) RoundTrip(ctx context.Context, cand Candidate) (time.Duration, error) {
+	payload := t.Payload
+	if len(payload) == 0 {
+		payload = []byte{0}
+	}
+	return udpRoundTrip(ctx, cand.Endpoint, payload, t.Match)
+}
+
+// udpRoundTrip sends req from a fresh socket and waits for a matching
+// response from the same endpoint.
+func udpRoundTrip(ctx context.Context, endpoint string, req []byte, match func([]byte) bool) (time.Duration, error) {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "udp", endpoint)
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close()
+
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+	stop := context.AfterFunc(ctx, func() { _ = conn.SetDeadline(time.Now()) })
+	defer stop()
+
+	start := time.Now()
+	if _, err := conn.Write(req); err != nil {
+		return 0, err
+	}
+	buf := make([]byte, 1500)
+	for {
+		n, err := conn.Read(buf)
+		if err != nil {
+			if ctx.Err() != nil {
+				return 0, ctx.Err()
+			}
+			return 0, err
+		}
+		if match == nil || match(buf[:n]) {
+			return time.Since(start), nil
+		}
+	}
+}
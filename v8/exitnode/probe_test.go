@@ -0,0 +1,219 @@
+package exitnode
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdh"
+	"encoding/binary"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	telio "github.com/NordSecurity/libtelio-go/v8"
+	"github.com/NordSecurity/libtelio-go/v8/keys"
+	"golang.org/x/crypto/blake2s"
+	"golang.org/x/crypto/chacha20poly1305"
+)
+
+// standIn serves UDP on localhost, answering every datagram reply accepts
+// after delay.
+func standIn(t *testing.T, delay time.Duration, reply func(req []byte) []byte) (telio.SocketAddr, <-chan []byte) {
+	t.Helper()
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	received := make(chan []byte, 16)
+	go func() {
+		buf := make([]byte, 1500)
+		for {
+			n, addr, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			req := bytes.Clone(buf[:n])
+			received <- req
+			if resp := reply(req); resp != nil {
+				time.Sleep(delay)
+				_, _ = conn.WriteTo(resp, addr)
+			}
+		}
+	}()
+	return conn.LocalAddr().String(), received
+}
+
+func echo(req []byte) []byte { return req }
+
+func TestProberRanksStandIns(t *testing.T) {
+	slow, _ := standIn(t, 40*time.Millisecond, echo)
+	fast, _ := standIn(t, 0, echo)
+	dead, _ := standIn(t, 0, func([]byte) []byte { return nil })
+
+	p := NewProber(&UDPTransport{}, ProbeOptions{Samples: 3, Interval: time.Millisecond, Timeout: 200 * time.Millisecond})
+	best, results, err := p.Best(context.Background(), []Candidate{{Endpoint: dead}, {Endpoint: slow}, {Endpoint: fast}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if best.Endpoint != fast {
+		t.Fatalf("best %s, want %s", best.Endpoint, fast)
+	}
+	if results[1].Candidate.Endpoint != slow || results[2].Reachable() || results[2].Lost != 3 {
+		t.Fatalf("unexpected ranking %+v", results)
+	}
+}
+
+// wgResponder checks a handshake initiation like a WireGuard server with the
+// secret key server, returning the initiator's static key.
+func wgResponder(server *ecdh.PrivateKey, msg []byte) (*ecdh.PublicKey, error) {
+	if len(msg) != wgInitiationSize || msg[0] != wgTypeInitiation {
+		return nil, errors.New("not an initiation")
+	}
+	mac1Key := wgHash([]byte(wgLabelMac1), server.PublicKey().Bytes())
+	mac, _ := blake2s.New128(mac1Key[:])
+	mac.Write(msg[:116])
+	if !bytes.Equal(mac.Sum(nil), msg[116:132]) {
+		return nil, errors.New("bad mac1")
+	}
+
+	ck := blake2s.Sum256([]byte(wgConstruction))
+	h := wgHash(ck[:], []byte(wgIdentifier))
+	h = wgHash(h[:], server.PublicKey().Bytes())
+	epub := msg[8:40]
+	ck = wgKdf1(ck[:], epub)
+	h = wgHash(h[:], epub)
+
+	ephemeral, err := ecdh.X25519().NewPublicKey(epub)
+	if err != nil {
+		return nil, err
+	}
+	es, err := server.ECDH(ephemeral)
+	if err != nil {
+		return nil, err
+	}
+	var key [32]byte
+	ck, key = wgKdf2(ck[:], es)
+	static, err := wgOpen(key, msg[40:88], h[:])
+	if err != nil {
+		return nil, err
+	}
+	h = wgHash(h[:], msg[40:88])
+	initiator, err := ecdh.X25519().NewPublicKey(static)
+	if err != nil {
+		return nil, err
+	}
+	ss, err := server.ECDH(initiator)
+	if err != nil {
+		return nil, err
+	}
+	_, key = wgKdf2(ck[:], ss)
+	if _, err := wgOpen(key, msg[88:116], h[:]); err != nil {
+		return nil, err
+	}
+	return initiator, nil
+}
+
+func wgOpen(key [32]byte, ciphertext, ad []byte) ([]byte, error) {
+	aead, _ := chacha20poly1305.New(key[:])
+	var nonce [chacha20poly1305.NonceSize]byte
+	return aead.Open(nil, nonce[:], ciphertext, ad)
+}
+
+func newX25519(t *testing.T) (*ecdh.PrivateKey, telio.SecretKey) {
+	t.Helper()
+	k, err := keys.GenerateSecretKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	priv, err := ecdh.X25519().NewPrivateKey(k[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return priv, k.Telio()
+}
+
+func TestWireGuardTransportStandIn(t *testing.T) {
+	server, serverSecret := newX25519(t)
+	client, clientSecret := newX25519(t)
+	serverKey, err := keys.SecretKeyFromTelio(serverSecret)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	errs := make(chan error, 8)
+	endpoint, _ := standIn(t, 0, func(req []byte) []byte {
+		initiator, err := wgResponder(server, req)
+		if err == nil && !initiator.Equal(client.PublicKey()) {
+			err = errors.New("initiation from an unexpected key")
+		}
+		errs <- err
+		if err != nil {
+			return nil
+		}
+		resp := make([]byte, wgResponseSize)
+		resp[0] = wgTypeResponse
+		copy(resp[8:12], req[4:8])
+		return resp
+	})
+
+	tr := &WireGuardTransport{SecretKey: clientSecret}
+	cand := Candidate{PublicKey: serverKey.PublicKey().Telio(), Endpoint: endpoint}
+	rtt, err := tr.RoundTrip(context.Background(), cand)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := <-errs; err != nil {
+		t.Fatalf("stand-in rejected the initiation: %v", err)
+	}
+	if rtt <= 0 {
+		t.Fatalf("rtt %v", rtt)
+	}
+}
+
+func TestWireGuardTransportResponseIndex(t *testing.T) {
+	server, serverSecret := newX25519(t)
+	_, clientSecret := newX25519(t)
+	serverKey, _ := keys.SecretKeyFromTelio(serverSecret)
+	endpoint, _ := standIn(t, 0, func(req []byte) []byte {
+		if _, err := wgResponder(server, req); err != nil {
+			return nil
+		}
+		resp := make([]byte, wgResponseSize)
+		resp[0] = wgTypeResponse
+		binary.LittleEndian.PutUint32(resp[8:12], binary.LittleEndian.Uint32(req[4:8])+1)
+		return resp
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	tr := &WireGuardTransport{SecretKey: clientSecret}
+	if _, err := tr.RoundTrip(ctx, Candidate{PublicKey: serverKey.PublicKey().Telio(), Endpoint: endpoint}); err == nil {
+		t.Fatal("accepted a response for another sender index")
+	}
+}
+
+func TestWireGuardTransportRefusesConnectedServer(t *testing.T) {
+	_, serverSecret := newX25519(t)
+	_, clientSecret := newX25519(t)
+	serverKey, _ := keys.SecretKeyFromTelio(serverSecret)
+	endpoint, received := standIn(t, 0, echo)
+	pub := serverKey.PublicKey().Telio()
+
+	tr := &WireGuardTransport{
+		SecretKey: clientSecret,
+		Status: func() []telio.TelioNode {
+			return []telio.TelioNode{{PublicKey: pub, IsExit: true, State: telio.NodeStateConnected}}
+		},
+	}
+	_, err := tr.RoundTrip(context.Background(), Candidate{PublicKey: pub, Endpoint: endpoint})
+	if !errors.Is(err, ErrConnected) {
+		t.Fatalf("got %v, want ErrConnected", err)
+	}
+	select {
+	case <-received:
+		t.Fatal("the connected server was probed")
+	case <-time.After(50 * time.Millisecond):
+	}
+}
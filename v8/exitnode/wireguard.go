@@ -0,0 +1,203 @@
+package exitnode
+
+import (
+	"context"
+	"crypto/ecdh"
+	"crypto/hmac"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"time"
+
+	telio "github.com/NordSecurity/libtelio-go/v8"
+	"github.com/NordSecurity/libtelio-go/v8/keys"
+	"golang.org/x/crypto/blake2s"
+	"golang.org/x/crypto/chacha20poly1305"
+)
+
+// WireGuard protocol constants, see https://www.wireguard.com/protocol/
+const (
+	wgConstruction = "Noise_IKpsk2_25519_ChaChaPoly_BLAKE2s"
+	wgIdentifier   = "WireGuard v1 zx2c4 Jason@zx2c4.com"
+	wgLabelMac1    = "mac1----"
+
+	wgTypeInitiation  = 1
+	wgTypeResponse    = 2
+	wgTypeCookieReply = 3
+
+	wgInitiationSize  = 148
+	wgResponseSize    = 92
+	wgCookieReplySize = 64
+
+	// TAI64 label of the Unix epoch
+	tai64Epoch = 0x400000000000000a
+)
+
+// ErrConnected is returned by WireGuardTransport for a server the device has a
+// tunnel to.
+var ErrConnected = errors.New("server has a live tunnel")
+
+// WireGuardTransport measures the time between a WireGuard handshake
+// initiation and the server's handshake response or cookie reply.
+//
+// The server only answers initiations from keys it knows, so SecretKey must be
+// the key registered with the VPN service. A valid initiation makes the server
+// move the peer's endpoint to the probe socket, which breaks a tunnel to that
+// server. Probe before connecting, or set Status so the server in use is
+// refused with ErrConnected.
+type WireGuardTransport struct {
+	SecretKey telio.SecretKey
+	// Nodes of the device using SecretKey, e.g. Telio.GetStatusMap. Exit
+	// nodes in it which are not disconnected are not probed.
+	Status func() []telio.TelioNode
+}
+
+// RoundTrip implements Transport.
+func (t *WireGuardTransport) RoundTrip(ctx context.Context, cand Candidate) (time.Duration, error) {
+	if t.Status != nil {
+		for _, n := range t.Status() {
+			if (n.IsExit || n.IsVpn) && n.PublicKey == cand.PublicKey && n.State != telio.NodeStateDisconnected {
+				return 0, fmt.Errorf("probing %s: %w", cand.Endpoint, ErrConnected)
+			}
+		}
+	}
+	static, err := parseX25519Private(t.SecretKey)
+	if err != nil {
+		return 0, fmt.Errorf("secret key: %w", err)
+	}
+	remote, err := parseX25519Public(cand.PublicKey)
+	if err != nil {
+		return 0, fmt.Errorf("public key of %s: %w", cand.Endpoint, err)
+	}
+	msg, index, err := wgInitiation(static, remote, time.Now())
+	if err != nil {
+		return 0, err
+	}
+	return udpRoundTrip(ctx, cand.Endpoint, msg, func(resp []byte) bool {
+		switch {
+		case len(resp) == wgResponseSize && resp[0] == wgTypeResponse:
+			return binary.LittleEndian.Uint32(resp[8:12]) == index
+		case len(resp) == wgCookieReplySize && resp[0] == wgTypeCookieReply:
+			return binary.LittleEndian.Uint32(resp[4:8]) == index
+		}
+		return false
+	})
+}
+
+func parseX25519Private(key telio.SecretKey) (*ecdh.PrivateKey, error) {
+	k, err := keys.SecretKeyFromTelio(key)
+	if err != nil {
+		return nil, err
+	}
+	defer k.Zero()
+	return ecdh.X25519().NewPrivateKey(k[:])
+}
+
+func parseX25519Public(key telio.PublicKey) (*ecdh.PublicKey, error) {
+	k, err := keys.PublicKeyFromTelio(key)
+	if err != nil {
+		return nil, err
+	}
+	return ecdh.X25519().NewPublicKey(k[:])
+}
+
+// wgInitiation builds a handshake initiation message from static to remote
+// and returns it with its sender index.
+func wgInitiation(static *ecdh.PrivateKey, remote *ecdh.PublicKey, now time.Time) ([]byte, uint32, error) {
+	ephemeral, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, 0, err
+	}
+	var idx [4]byte
+	if _, err := rand.Read(idx[:]); err != nil {
+		return nil, 0, err
+	}
+	index := binary.LittleEndian.Uint32(idx[:])
+
+	ck := blake2s.Sum256([]byte(wgConstruction))
+	h := wgHash(ck[:], []byte(wgIdentifier))
+	h = wgHash(h[:], remote.Bytes())
+
+	msg := make([]byte, wgInitiationSize)
+	msg[0] = wgTypeInitiation
+	binary.LittleEndian.PutUint32(msg[4:8], index)
+
+	epub := ephemeral.PublicKey().Bytes()
+	copy(msg[8:40], epub)
+	ck = wgKdf1(ck[:], epub)
+	h = wgHash(h[:], epub)
+
+	es, err := ephemeral.ECDH(remote)
+	if err != nil {
+		return nil, 0, err
+	}
+	var key [32]byte
+	ck, key = wgKdf2(ck[:], es)
+	encStatic := wgSeal(key, msg[40:40], static.PublicKey().Bytes(), h[:])
+	h = wgHash(h[:], encStatic)
+
+	ss, err := static.ECDH(remote)
+	if err != nil {
+		return nil, 0, err
+	}
+	_, key = wgKdf2(ck[:], ss)
+	wgSeal(key, msg[88:88], tai64n(now), h[:])
+
+	mac1Key := wgHash([]byte(wgLabelMac1), remote.Bytes())
+	mac, _ := blake2s.New128(mac1Key[:])
+	mac.Write(msg[:116])
+	copy(msg[116:132], mac.Sum(nil))
+	// mac2 stays zero without a cookie
+
+	return msg, index, nil
+}
+
+func wgHash(a, b []byte) [32]byte {
+	h, _ := blake2s.New256(nil)
+	h.Write(a)
+	h.Write(b)
+	var out [32]byte
+	h.Sum(out[:0])
+	return out
+}
+
+func wgHmac(key []byte, data ...[]byte) [32]byte {
+	mac := hmac.New(func() hash.Hash {
+		h, _ := blake2s.New256(nil)
+		return h
+	}, key)
+	for _, d := range data {
+		mac.Write(d)
+	}
+	var out [32]byte
+	mac.Sum(out[:0])
+	return out
+}
+
+func wgKdf1(key, input []byte) [32]byte {
+	t0 := wgHmac(key, input)
+	return wgHmac(t0[:], []byte{1})
+}
+
+func wgKdf2(key, input []byte) ([32]byte, [32]byte) {
+	t0 := wgHmac(key, input)
+	t1 := wgHmac(t0[:], []byte{1})
+	t2 := wgHmac(t0[:], t1[:], []byte{2})
+	return t1, t2
+}
+
+// wgSeal encrypts plaintext with a zero nonce, appending to dst.
+func wgSeal(key [32]byte, dst, plaintext, ad []byte) []byte {
+	aead, _ := chacha20poly1305.New(key[:])
+	var nonce [chacha20poly1305.NonceSize]byte
+	return aead.Seal(dst, nonce[:], plaintext, ad)
+}
+
+func tai64n(t time.Time) []byte {
+	var ts [12]byte
+	binary.BigEndian.PutUint64(ts[:8], tai64Epoch+uint64(t.Unix()))
+	binary.BigEndian.PutUint32(ts[8:], uint32(t.Nanosecond()))
+	return ts[:]
+}
@@ -0,0 +1,151 @@
+package telio
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+)
+
+// The generated types carry no struct tags, they would be lost whenever the
+// bindings are regenerated. Features is encoded here instead, in the format
+// of libtelio's serde implementation, so it can be read and written without
+// the native library. The YAML and TOML methods in features_yaml.go and
+// features_toml.go build on the same encoding.
+
+var (
+	textMarshaler   = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+	textUnmarshaler = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+	wordStart       = regexp.MustCompile(`([a-z0-9])([A-Z])`)
+)
+
+// JSONName returns the name of f in libtelio's JSON. uniffi derives the Go
+// field names from the snake_case Rust ones, which serde uses as they are.
+func JSONName(f reflect.StructField) string {
+	return strings.ToLower(wordStart.ReplaceAllString(f.Name, "${1}_${2}"))
+}
+
+// MarshalJSON encodes f like SerializeFeatureConfig. Absent sections are left
+// out and enums are written as their names.
+func (f Features) MarshalJSON() ([]byte, error) {
+	var b bytes.Buffer
+	if err := encodeJSON(&b, reflect.ValueOf(f)); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+// UnmarshalJSON decodes the JSON of SerializeFeatureConfig. Unlike
+// DeserializeFeatureConfig it does not fill in libtelio's defaults: missing
+// fields keep their value in f.
+func (f *Features) UnmarshalJSON(data []byte) error {
+	return decodeJSON(data, reflect.ValueOf(f).Elem())
+}
+
+func encodeJSON(b *bytes.Buffer, v reflect.Value) error {
+	if v.Type().Implements(textMarshaler) {
+		data, err := json.Marshal(v.Interface())
+		b.Write(data)
+		return err
+	}
+	switch v.Kind() {
+	case reflect.Pointer:
+		if v.IsNil() {
+			b.WriteString("null")
+			return nil
+		}
+		return encodeJSON(b, v.Elem())
+	case reflect.Struct:
+		b.WriteByte('{')
+		first := true
+		for i := 0; i < v.NumField(); i++ {
+			f := v.Type().Field(i)
+			if !f.IsExported() || (f.Type.Kind() == reflect.Pointer && v.Field(i).IsNil()) {
+				continue
+			}
+			if !first {
+				b.WriteByte(',')
+			}
+			first = false
+			name, _ := json.Marshal(JSONName(f))
+			b.Write(name)
+			b.WriteByte(':')
+			if err := encodeJSON(b, v.Field(i)); err != nil {
+				return fmt.Errorf("%s: %w", f.Name, err)
+			}
+		}
+		b.WriteByte('}')
+		return nil
+	case reflect.Slice:
+		// Byte slices too are arrays of numbers, not base64, and nil is empty
+		b.WriteByte('[')
+		for i := 0; i < v.Len(); i++ {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			if err := encodeJSON(b, v.Index(i)); err != nil {
+				return err
+			}
+		}
+		b.WriteByte(']')
+		return nil
+	}
+	data, err := json.Marshal(v.Interface())
+	b.Write(data)
+	return err
+}
+
+func decodeJSON(data []byte, v reflect.Value) error {
+	if string(bytes.TrimSpace(data)) == "null" {
+		v.SetZero()
+		return nil
+	}
+	if reflect.PointerTo(v.Type()).Implements(textUnmarshaler) {
+		return json.Unmarshal(data, v.Addr().Interface())
+	}
+	switch v.Kind() {
+	case reflect.Pointer:
+		elem := reflect.New(v.Type().Elem())
+		if !v.IsNil() {
+			elem.Elem().Set(v.Elem())
+		}
+		if err := decodeJSON(data, elem.Elem()); err != nil {
+			return err
+		}
+		v.Set(elem)
+		return nil
+	case reflect.Struct:
+		var fields map[string]json.RawMessage
+		if err := json.Unmarshal(data, &fields); err != nil {
+			return err
+		}
+		for i := 0; i < v.NumField(); i++ {
+			f := v.Type().Field(i)
+			raw, ok := fields[JSONName(f)]
+			if !ok || !f.IsExported() {
+				continue
+			}
+			if err := decodeJSON(raw, v.Field(i)); err != nil {
+				return fmt.Errorf("%s: %w", JSONName(f), err)
+			}
+		}
+		return nil
+	case reflect.Slice:
+		var items []json.RawMessage
+		if err := json.Unmarshal(data, &items); err != nil {
+			return err
+		}
+		s := reflect.MakeSlice(v.Type(), len(items), len(items))
+		for i, item := range items {
+			if err := decodeJSON(item, s.Index(i)); err != nil {
+				return err
+			}
+		}
+		v.Set(s)
+		return nil
+	}
+	return json.Unmarshal(data, v.Addr().Interface())
+}
@@ -0,0 +1,60 @@
+//go:build native
+
+// Run against the real libtelio with: go test -tags native .
+
+package telio
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+// nativeFeatures returns the defaults with the optional sections enabled.
+func nativeFeatures() Features {
+	b := NewFeaturesDefaultsBuilder()
+	for _, enable := range []func(*FeaturesDefaultsBuilder) *FeaturesDefaultsBuilder{
+		(*FeaturesDefaultsBuilder).EnableNurse,
+		(*FeaturesDefaultsBuilder).EnableDirect,
+		(*FeaturesDefaultsBuilder).EnableIpv6,
+		(*FeaturesDefaultsBuilder).EnableErrorNotificationService,
+		func(b *FeaturesDefaultsBuilder) *FeaturesDefaultsBuilder { return b.EnableLana("/tmp/lana.db", false) },
+	} {
+		next := enable(b)
+		b.Destroy()
+		b = next
+	}
+	defer b.Destroy()
+	return b.Build()
+}
+
+func TestFeaturesJSONAgainstNative(t *testing.T) {
+	for name, f := range map[string]Features{
+		"defaults": GetDefaultFeatureConfig(),
+		"enabled":  nativeFeatures(),
+	} {
+		native, err := SerializeFeatureConfig(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var decoded Features
+		if err := json.Unmarshal([]byte(native), &decoded); err != nil {
+			t.Fatalf("%s: decoding libtelio's JSON: %v", name, err)
+		}
+		if !reflect.DeepEqual(decoded, f) {
+			t.Errorf("%s: decoded differently from libtelio:\n%s", name, native)
+		}
+
+		data, err := json.Marshal(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		back, err := DeserializeFeatureConfig(string(data))
+		if err != nil {
+			t.Fatalf("%s: libtelio rejects the encoding: %v\n%s", name, err, data)
+		}
+		if !reflect.DeepEqual(back, f) {
+			t.Errorf("%s: libtelio decodes differently:\n%s", name, data)
+		}
+	}
+}
@@ -0,0 +1,180 @@
+package telio
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// fill sets every field below v to a value which is not zero. Enums get their
+// first value.
+func fill(v reflect.Value, n *uint64) {
+	*n++
+	switch v.Kind() {
+	case reflect.Pointer:
+		v.Set(reflect.New(v.Type().Elem()))
+		fill(v.Elem(), n)
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			fill(v.Field(i), n)
+		}
+	case reflect.Slice:
+		v.Set(reflect.MakeSlice(v.Type(), 2, 2))
+		fill(v.Index(0), n)
+		fill(v.Index(1), n)
+	case reflect.Bool:
+		v.SetBool(true)
+	case reflect.String:
+		v.SetString("s")
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		v.SetInt(int64(*n % 100))
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if reflect.PointerTo(v.Type()).Implements(textUnmarshaler) {
+			v.SetUint(1)
+		} else {
+			v.SetUint(*n % 200)
+		}
+	}
+}
+
+func TestFeaturesJSONRoundTrip(t *testing.T) {
+	var f Features
+	var n uint64
+	fill(reflect.ValueOf(&f).Elem(), &n)
+
+	data, err := json.Marshal(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var back Features
+	if err := json.Unmarshal(data, &back); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(f, back) {
+		t.Fatalf("round trip changed the features:\n%s", data)
+	}
+	again, err := json.Marshal(back)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(data, again) {
+		t.Fatalf("encoding is not stable:\n%s\n%s", data, again)
+	}
+}
+
+// libtelioFeatures is written in the format of SerializeFeatureConfig.
+const libtelioFeatures = `{
+	"wireguard": {
+		"persistent_keepalive": {"vpn": 25, "direct": 5, "proxying": 25, "stun": 50},
+		"polling": {"wireguard_polling_period": 1000, "wireguard_polling_period_after_state_change": 50},
+		"enable_dynamic_wg_nt_control": false,
+		"skt_buffer_size": null,
+		"inter_thread_channel_size": null,
+		"max_inter_thread_batched_pkts": null
+	},
+	"nurse": {
+		"heartbeat_interval": 3600,
+		"initial_heartbeat_interval": 300,
+		"qos": {"rtt_interval": 300, "rtt_tries": 3, "rtt_types": ["ping"], "buckets": 5},
+		"enable_relay_conn_data": true,
+		"enable_nat_traversal_conn_data": true,
+		"state_duration_cap": 86400
+	},
+	"lana": null,
+	"paths": {"priority": ["relay", "direct"], "force": null},
+	"direct": {"providers": ["local", "stun"], "endpoint_interval_secs": 10, "skip_unresponsive_peers": {"no_rx_threshold_secs": 180}},
+	"is_test_env": false,
+	"hide_user_data": true,
+	"hide_thread_id": true,
+	"derp": {"tcp_keepalive": 15, "derp_keepalive": 60, "enable_polling": null, "use_built_in_root_certificates": false},
+	"validate_keys": true,
+	"ipv6": false,
+	"nicknames": false,
+	"firewall": {"neptun_reset_conns": false, "boringtun_reset_conns": false, "exclude_private_ip_range": null, "outgoing_blacklist": [{"protocol": "udp", "ip": "10.0.0.1", "port": 53}]},
+	"flush_events_on_stop_timeout_seconds": null,
+	"link_detection": {"rtt_seconds": 15, "use_for_downgrade": false},
+	"dns": {"ttl_value": 60, "exit_dns": {"auto_switch_dns_ips": true}, "use_raw_forwarder": null},
+	"post_quantum_vpn": {"handshake_retry_interval_s": 8, "rekey_interval_s": 90, "version": 1},
+	"multicast": false,
+	"error_notification_service": {"buffer_size": 5, "allow_only_pq": false, "backoff": {"initial_s": 1, "maximal_s": 300}, "root_certificate_override": [48, 130, 1]}
+}`
+
+// compact removes nulls, which SerializeFeatureConfig writes for absent
+// options where MarshalJSON leaves them out, and formatting.
+func compact(t *testing.T, data []byte) any {
+	t.Helper()
+	var v any
+	if err := json.Unmarshal(data, &v); err != nil {
+		t.Fatal(err)
+	}
+	var drop func(any) any
+	drop = func(v any) any {
+		switch v := v.(type) {
+		case map[string]any:
+			for k, x := range v {
+				if x == nil {
+					delete(v, k)
+				} else {
+					v[k] = drop(x)
+				}
+			}
+		case []any:
+			for i := range v {
+				v[i] = drop(v[i])
+			}
+		}
+		return v
+	}
+	return drop(v)
+}
+
+func TestFeaturesJSONMatchesLibtelio(t *testing.T) {
+	var f Features
+	if err := json.Unmarshal([]byte(libtelioFeatures), &f); err != nil {
+		t.Fatal(err)
+	}
+	ens := f.ErrorNotificationService
+	if ens == nil || ens.RootCertificateOverride == nil || !bytes.Equal(*ens.RootCertificateOverride, []byte{48, 130, 1}) {
+		t.Fatalf("error notification service %+v", ens)
+	}
+	if f.Paths == nil || !reflect.DeepEqual(f.Paths.Priority, []PathType{PathTypeRelay, PathTypeDirect}) {
+		t.Fatalf("paths %+v", f.Paths)
+	}
+	if f.Firewall == nil || f.Firewall.OutgoingBlacklist[0].Protocol != IpProtocolUdp {
+		t.Fatalf("firewall %+v", f.Firewall)
+	}
+
+	data, err := json.Marshal(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{`"root_certificate_override":[48,130,1]`, `"providers":["local","stun"]`, `"rtt_types":["ping"]`} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("%s missing from %s", want, data)
+		}
+	}
+	if got, want := compact(t, data), compact(t, []byte(libtelioFeatures)); !reflect.DeepEqual(got, want) {
+		t.Fatalf("encoded differently from libtelio:\n%s", data)
+	}
+}
+
+func TestFeaturesJSONNilSlices(t *testing.T) {
+	data, err := json.Marshal(Features{Paths: &FeaturePaths{}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// serde rejects null for a Vec
+	if !strings.Contains(string(data), `"paths":{"priority":[]}`) {
+		t.Fatalf("nil slice not written as an empty array: %s", data)
+	}
+}
+
+func TestFeaturesJSONErrors(t *testing.T) {
+	var f Features
+	err := json.Unmarshal([]byte(`{"direct": {"providers": ["carrier_pigeon"]}}`), &f)
+	if err == nil || !strings.Contains(err.Error(), "direct: providers") {
+		t.Fatalf("got %v, want an error naming the field", err)
+	}
+}
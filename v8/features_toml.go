@@ -0,0 +1,164 @@
+package telio
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// MarshalTOML writes f as a TOML document with the field names of
+// MarshalJSON, for github.com/BurntSushi/toml and compatible encoders.
+// Sections are tables, lists of objects arrays of inline tables. TOML has no
+// null, absent options are left out as in MarshalJSON.
+//
+// The output is a whole document, Features cannot be embedded in a larger
+// one as a value.
+func (f Features) MarshalTOML() ([]byte, error) {
+	data, err := f.MarshalJSON()
+	if err != nil {
+		return nil, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	root, err := readOrdered(dec)
+	if err != nil {
+		return nil, err
+	}
+	var b bytes.Buffer
+	if err := writeTOMLTable(&b, "", root.(orderedObject)); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+// UnmarshalTOML decodes f like UnmarshalJSON from the table decoded by the
+// TOML library. It also works for Features inside a larger document.
+func (f *Features) UnmarshalTOML(v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return f.UnmarshalJSON(data)
+}
+
+// orderedObject is a JSON object with its members in document order.
+type orderedObject []orderedMember
+
+type orderedMember struct {
+	key   string
+	value any
+}
+
+// readOrdered decodes the next JSON value of dec, keeping objects ordered.
+func readOrdered(dec *json.Decoder) (any, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	switch tok {
+	case json.Delim('{'):
+		var obj orderedObject
+		for dec.More() {
+			key, err := dec.Token()
+			if err != nil {
+				return nil, err
+			}
+			v, err := readOrdered(dec)
+			if err != nil {
+				return nil, err
+			}
+			obj = append(obj, orderedMember{key: key.(string), value: v})
+		}
+		_, err = dec.Token()
+		return obj, err
+	case json.Delim('['):
+		list := []any{}
+		for dec.More() {
+			v, err := readOrdered(dec)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, v)
+		}
+		_, err = dec.Token()
+		return list, err
+	}
+	return tok, nil
+}
+
+// writeTOMLTable writes the members of obj, the table name, as key/value
+// pairs followed by its sub-tables.
+func writeTOMLTable(b *bytes.Buffer, name string, obj orderedObject) error {
+	if name != "" {
+		fmt.Fprintf(b, "\n[%s]\n", name)
+	}
+	for _, m := range obj {
+		if _, ok := m.value.(orderedObject); ok || m.value == nil {
+			continue
+		}
+		b.WriteString(m.key + " = ")
+		if err := writeTOMLValue(b, m.value); err != nil {
+			return fmt.Errorf("%s: %w", m.key, err)
+		}
+		b.WriteByte('\n')
+	}
+	for _, m := range obj {
+		sub, ok := m.value.(orderedObject)
+		if !ok {
+			continue
+		}
+		subName := m.key
+		if name != "" {
+			subName = name + "." + m.key
+		}
+		if err := writeTOMLTable(b, subName, sub); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeTOMLValue writes v inline. JSON strings and numbers are valid TOML.
+func writeTOMLValue(b *bytes.Buffer, v any) error {
+	switch v := v.(type) {
+	case orderedObject:
+		b.WriteByte('{')
+		first := true
+		for _, m := range v {
+			if m.value == nil {
+				continue
+			}
+			if !first {
+				b.WriteString(", ")
+			}
+			first = false
+			b.WriteString(m.key + " = ")
+			if err := writeTOMLValue(b, m.value); err != nil {
+				return fmt.Errorf("%s: %w", m.key, err)
+			}
+		}
+		b.WriteByte('}')
+	case []any:
+		b.WriteByte('[')
+		for i, x := range v {
+			if i > 0 {
+				b.WriteString(", ")
+			}
+			if err := writeTOMLValue(b, x); err != nil {
+				return err
+			}
+		}
+		b.WriteByte(']')
+	case string:
+		data, _ := json.Marshal(v)
+		b.Write(data)
+	case json.Number:
+		b.WriteString(string(v))
+	case bool:
+		fmt.Fprint(b, v)
+	default:
+		return errors.New("TOML has no null")
+	}
+	return nil
+}
@@ -0,0 +1,68 @@
+package telio
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/BurntSushi/toml"
+)
+
+func TestFeaturesTOMLRoundTrip(t *testing.T) {
+	var f Features
+	var n uint64
+	fill(reflect.ValueOf(&f).Elem(), &n)
+
+	var b strings.Builder
+	if err := toml.NewEncoder(&b).Encode(f); err != nil {
+		t.Fatal(err)
+	}
+	data := b.String()
+	if !strings.Contains(data, "\n[wireguard.persistent_keepalive]\n") || !strings.Contains(data, `priority = ["relay", "relay"]`) {
+		t.Fatalf("not written with libtelio's names:\n%s", data)
+	}
+	var back Features
+	if _, err := toml.Decode(data, &back); err != nil {
+		t.Fatalf("%v\n%s", err, data)
+	}
+	if !reflect.DeepEqual(f, back) {
+		t.Fatalf("round trip changed the features:\n%s", data)
+	}
+}
+
+func TestFeaturesTOMLMatchesJSON(t *testing.T) {
+	var want Features
+	if err := json.Unmarshal([]byte(libtelioFeatures), &want); err != nil {
+		t.Fatal(err)
+	}
+	data, err := want.MarshalTOML()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got Features
+	if _, err := toml.Decode(string(data), &got); err != nil {
+		t.Fatalf("%v\n%s", err, data)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("decoded differently from the JSON:\n%s", data)
+	}
+
+	// A config file embedding the features
+	var file struct {
+		Features Features `toml:"features"`
+	}
+	_, err = toml.Decode(`
+[features.paths]
+priority = ["relay", "direct"]
+
+[features.firewall]
+outgoing_blacklist = [{protocol = "udp", ip = "10.0.0.1", port = 53}]
+`, &file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(file.Features.Paths, want.Paths) || !reflect.DeepEqual(file.Features.Firewall.OutgoingBlacklist, want.Firewall.OutgoingBlacklist) {
+		t.Fatalf("decoded %+v", file.Features)
+	}
+}
@@ -0,0 +1,103 @@
+package telio
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+)
+
+// MarshalYAML returns f as generic maps, lists and scalars with the field
+// names of MarshalJSON, for gopkg.in/yaml.v3 and compatible encoders.
+func (f Features) MarshalYAML() (any, error) {
+	data, err := f.MarshalJSON()
+	if err != nil {
+		return nil, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	return yamlValue(v), nil
+}
+
+// UnmarshalYAML decodes f like UnmarshalJSON. It has the signature of
+// yaml.v2's Unmarshaler, which yaml.v3 still supports.
+func (f *Features) UnmarshalYAML(unmarshal func(any) error) error {
+	var v any
+	if err := unmarshal(&v); err != nil {
+		return err
+	}
+	v, err := jsonCompatible(v)
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return f.UnmarshalJSON(data)
+}
+
+// yamlValue replaces the numbers of a decoded JSON value with integers, so
+// they are not written as strings.
+func yamlValue(v any) any {
+	switch v := v.(type) {
+	case map[string]any:
+		for k, x := range v {
+			v[k] = yamlValue(x)
+		}
+	case []any:
+		for i, x := range v {
+			v[i] = yamlValue(x)
+		}
+	case json.Number:
+		if n, err := strconv.ParseInt(string(v), 10, 64); err == nil {
+			return n
+		}
+		if n, err := strconv.ParseUint(string(v), 10, 64); err == nil {
+			return n
+		}
+		if n, err := v.Float64(); err == nil {
+			return n
+		}
+	}
+	return v
+}
+
+// jsonCompatible converts the maps with any keys of YAML decoders to maps
+// with string keys.
+func jsonCompatible(v any) (any, error) {
+	switch v := v.(type) {
+	case map[any]any:
+		m := make(map[string]any, len(v))
+		for k, x := range v {
+			key, ok := k.(string)
+			if !ok {
+				return nil, fmt.Errorf("key %v is not a string", k)
+			}
+			var err error
+			if m[key], err = jsonCompatible(x); err != nil {
+				return nil, err
+			}
+		}
+		return m, nil
+	case map[string]any:
+		for k, x := range v {
+			var err error
+			if v[k], err = jsonCompatible(x); err != nil {
+				return nil, err
+			}
+		}
+	case []any:
+		for i, x := range v {
+			var err error
+			if v[i], err = jsonCompatible(x); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return v, nil
+}
@@ -0,0 +1,71 @@
+package telio
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestFeaturesYAMLRoundTrip(t *testing.T) {
+	var f Features
+	var n uint64
+	fill(reflect.ValueOf(&f).Elem(), &n)
+
+	data, err := yaml.Marshal(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), "persistent_keepalive:") || !strings.Contains(string(data), "- relay") {
+		t.Fatalf("not written with libtelio's names:\n%s", data)
+	}
+	var back Features
+	if err := yaml.Unmarshal(data, &back); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(f, back) {
+		t.Fatalf("round trip changed the features:\n%s", data)
+	}
+}
+
+func TestFeaturesYAMLMatchesJSON(t *testing.T) {
+	var want Features
+	if err := json.Unmarshal([]byte(libtelioFeatures), &want); err != nil {
+		t.Fatal(err)
+	}
+	// A config file embedding the features
+	var file struct {
+		Features Features `yaml:"features"`
+	}
+	err := yaml.Unmarshal([]byte(`
+features:
+  wireguard:
+    persistent_keepalive: {vpn: 25, direct: 5, proxying: 25, stun: 50}
+  paths:
+    priority: [relay, direct]
+  direct:
+    providers: [local, stun]
+  firewall:
+    outgoing_blacklist:
+      - {protocol: udp, ip: 10.0.0.1, port: 53}
+  error_notification_service:
+    root_certificate_override: [48, 130, 1]
+`), &file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := file.Features
+	if !reflect.DeepEqual(got.Wireguard.PersistentKeepalive, want.Wireguard.PersistentKeepalive) ||
+		!reflect.DeepEqual(got.Paths, want.Paths) ||
+		!reflect.DeepEqual(got.Direct.Providers, want.Direct.Providers) ||
+		!reflect.DeepEqual(got.Firewall.OutgoingBlacklist, want.Firewall.OutgoingBlacklist) ||
+		!reflect.DeepEqual(got.ErrorNotificationService.RootCertificateOverride, want.ErrorNotificationService.RootCertificateOverride) {
+		t.Fatalf("decoded %+v", got)
+	}
+
+	if err := yaml.Unmarshal([]byte("direct: {providers: [carrier_pigeon]}"), &got); err == nil || !strings.Contains(err.Error(), "direct: providers") {
+		t.Fatalf("got %v, want an error naming the field", err)
+	}
+}
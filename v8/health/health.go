@@ -0,0 +1,279 @@
+// Package health exposes liveness and readiness probes for an embedded
+// telio instance over HTTP.
+//
+// The Checker combines the status polled from libtelio with what was observed
+// through events (relay connectivity and errors), since the status map does
+// not describe the relay servers.
+package health
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	telio "github.com/NordSecurity/libtelio-go/v8"
+)
+
+const defaultErrorWindow = 5 * time.Minute
+
+// Status is the part of telio.TelioInterface used by a Checker.
+type Status interface {
+	IsRunning() (bool, error)
+	GetStatusMap() []telio.TelioNode
+}
+
+// Config holds the readiness thresholds of a Checker. The checks which are not
+// required are still reported, but only as warnings.
+type Config struct {
+	// Ready only when connected to at least one relay server
+	RequireRelay bool
+	// Ready only when connected to an exit node
+	RequireExitNode bool
+	// Ready only when at least this many meshnet peers are connected
+	MinConnectedPeers int
+	// Ready only when these peers are connected over a direct path
+	RequireDirect []telio.PublicKey
+	// How long an ErrorLevelCritical error fails the probes [default 5m]
+	ErrorWindow time.Duration
+}
+
+// CheckStatus is the outcome of a single check.
+type CheckStatus string
+
+const (
+	// The check passed
+	CheckOK CheckStatus = "ok"
+	// A check that is not required by Config failed
+	CheckWarn CheckStatus = "warn"
+	// A required check failed
+	CheckFail CheckStatus = "fail"
+)
+
+// Check is the result of a single check.
+type Check struct {
+	Name    string      `json:"name"`
+	Status  CheckStatus `json:"status"`
+	Message string      `json:"message,omitempty"`
+}
+
+// Report is the result of a probe.
+type Report struct {
+	Status CheckStatus `json:"status"`
+	Time   time.Time   `json:"time"`
+	Checks []Check     `json:"checks"`
+}
+
+// Healthy reports whether no required check failed.
+func (r Report) Healthy() bool {
+	return r.Status != CheckFail
+}
+
+type criticalError struct {
+	at    time.Time
+	event telio.ErrorEvent
+}
+
+// Checker evaluates liveness and readiness of a telio instance. Feed it events
+// with Observe, e.g. by subscribing it to an events.Dispatcher.
+//
+// Checker is an http.Handler serving the liveness probe on paths ending with
+// "/livez" and the readiness probe on every other path.
+type Checker struct {
+	status Status
+	cfg    Config
+	now    func() time.Time
+
+	mu     sync.Mutex
+	relays map[telio.PublicKey]telio.RelayState
+	errors []criticalError
+}
+
+// NewChecker creates a checker for status.
+func NewChecker(status Status, cfg Config) *Checker {
+	if cfg.ErrorWindow <= 0 {
+		cfg.ErrorWindow = defaultErrorWindow
+	}
+	return &Checker{
+		status: status,
+		cfg:    cfg,
+		now:    time.Now,
+		relays: make(map[telio.PublicKey]telio.RelayState),
+	}
+}
+
+// Observe records the relay states and critical errors reported by libtelio.
+func (c *Checker) Observe(event telio.Event) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	switch e := event.(type) {
+	case telio.EventRelay:
+		c.relays[e.Body.PublicKey] = e.Body.ConnState
+	case telio.EventError:
+		if e.Body.Level == telio.ErrorLevelCritical {
+			c.errors = append(c.errors, criticalError{at: c.now(), event: e.Body})
+		}
+	}
+}
+
+// Liveness reports whether libtelio is running and did not recently report a
+// critical error.
+func (c *Checker) Liveness() Report {
+	return c.report(c.checkRunning(), c.checkErrors())
+}
+
+// Readiness reports whether the instance is connected according to Config.
+func (c *Checker) Readiness() Report {
+	nodes := c.status.GetStatusMap()
+	checks := []Check{c.checkRunning(), c.checkErrors(), c.checkRelay(), c.checkExitNode(nodes), c.checkPeers(nodes)}
+	for _, key := range c.cfg.RequireDirect {
+		checks = append(checks, c.checkDirect(nodes, key))
+	}
+	return c.report(checks...)
+}
+
+// LivenessHandler serves the liveness probe.
+func (c *Checker) LivenessHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeReport(w, c.Liveness())
+	})
+}
+
+// ReadinessHandler serves the readiness probe.
+func (c *Checker) ReadinessHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeReport(w, c.Readiness())
+	})
+}
+
+// ServeHTTP implements http.Handler.
+func (c *Checker) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if strings.HasSuffix(r.URL.Path, "/livez") {
+		writeReport(w, c.Liveness())
+		return
+	}
+	writeReport(w, c.Readiness())
+}
+
+func writeReport(w http.ResponseWriter, report Report) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	if report.Healthy() {
+		w.WriteHeader(http.StatusOK)
+	} else {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	_ = json.NewEncoder(w).Encode(report)
+}
+
+func (c *Checker) report(checks ...Check) Report {
+	status := CheckOK
+	for _, check := range checks {
+		switch {
+		case check.Status == CheckFail:
+			status = CheckFail
+		case check.Status == CheckWarn && status == CheckOK:
+			status = CheckWarn
+		}
+	}
+	return Report{Status: status, Time: c.now().UTC(), Checks: checks}
+}
+
+func (c *Checker) checkRunning() Check {
+	check := Check{Name: "running", Status: CheckOK}
+	running, err := c.status.IsRunning()
+	switch {
+	case err != nil:
+		check.Status, check.Message = CheckFail, err.Error()
+	case !running:
+		check.Status, check.Message = CheckFail, "telio is not running"
+	}
+	return check
+}
+
+func (c *Checker) checkErrors() Check {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	cutoff := c.now().Add(-c.cfg.ErrorWindow)
+	i := 0
+	for i < len(c.errors) && c.errors[i].at.Before(cutoff) {
+		i++
+	}
+	c.errors = c.errors[i:]
+
+	if len(c.errors) == 0 {
+		return Check{Name: "critical_errors", Status: CheckOK}
+	}
+	last := c.errors[len(c.errors)-1]
+	return Check{
+		Name:    "critical_errors",
+		Status:  CheckFail,
+		Message: fmt.Sprintf("%d critical error(s) in the last %s, latest: %s", len(c.errors), c.cfg.ErrorWindow, last.event.Msg),
+	}
+}
+
+func (c *Checker) checkRelay() Check {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, state := range c.relays {
+		if state == telio.RelayStateConnected {
+			return Check{Name: "relay", Status: CheckOK}
+		}
+	}
+	return c.failed("relay", c.cfg.RequireRelay, "not connected to any relay server")
+}
+
+func (c *Checker) checkExitNode(nodes []telio.TelioNode) Check {
+	for _, n := range nodes {
+		if n.IsExit && n.State == telio.NodeStateConnected {
+			return Check{Name: "exit_node", Status: CheckOK}
+		}
+	}
+	return c.failed("exit_node", c.cfg.RequireExitNode, "not connected to an exit node")
+}
+
+func (c *Checker) checkPeers(nodes []telio.TelioNode) Check {
+	connected := 0
+	for _, n := range nodes {
+		if !n.IsVpn && n.State == telio.NodeStateConnected {
+			connected++
+		}
+	}
+	if connected >= c.cfg.MinConnectedPeers {
+		return Check{Name: "peers", Status: CheckOK, Message: fmt.Sprintf("%d peer(s) connected", connected)}
+	}
+	return Check{
+		Name:    "peers",
+		Status:  CheckFail,
+		Message: fmt.Sprintf("%d peer(s) connected, %d required", connected, c.cfg.MinConnectedPeers),
+	}
+}
+
+func (c *Checker) checkDirect(nodes []telio.TelioNode, key telio.PublicKey) Check {
+	name := "direct:" + key
+	for _, n := range nodes {
+		if n.PublicKey != key {
+			continue
+		}
+		switch {
+		case n.State != telio.NodeStateConnected:
+			return Check{Name: name, Status: CheckFail, Message: "peer is not connected"}
+		case n.Path != telio.PathTypeDirect:
+			return Check{Name: name, Status: CheckFail, Message: "peer is connected over relay"}
+		}
+		return Check{Name: name, Status: CheckOK}
+	}
+	return Check{Name: name, Status: CheckFail, Message: "peer is not in the status map"}
+}
+
+func (c *Checker) failed(name string, required bool, msg string) Check {
+	if required {
+		return Check{Name: name, Status: CheckFail, Message: msg}
+	}
+	return Check{Name: name, Status: CheckWarn, Message: msg}
+}
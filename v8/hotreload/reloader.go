@@ -0,0 +1,407 @@
+// Package hotreload applies changes of a config file to a running libtelio
+// instance.
+//
+// Fwmark, the external interface filter and the TP-Lite domain whitelist are
+// applied live through their setters. Features can only be passed to
+// telio.NewTelio, so changing any of them, e.g. the firewall blacklist,
+// recreates the instance. The restart keeps the secret key, adapter, meshnet
+// config and connected exit nodes, which reconnect the way they were connected
+// through the Reloader, e.g. post-quantum.
+package hotreload
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	telio "github.com/NordSecurity/libtelio-go/v8"
+)
+
+const defaultInterval = 2 * time.Second
+
+// Device is the part of telio.TelioInterface used by a Reloader.
+type Device interface {
+	GetSecretKey() telio.SecretKey
+	Start(secretKey telio.SecretKey, adapter telio.TelioAdapterType) error
+	StartNamedExtIfFilter(secretKey telio.SecretKey, adapter telio.TelioAdapterType, name string, extIfFilter []string) error
+	Stop() error
+	SetMeshnet(cfg telio.Config) error
+	SetMeshnetOff() error
+	GetStatusMap() []telio.TelioNode
+	ConnectToExitNode(publicKey telio.PublicKey, allowedIps *[]telio.IpNet, endpoint *telio.SocketAddr) error
+	ConnectToExitNodeWithId(identifier *string, publicKey telio.PublicKey, allowedIps *[]telio.IpNet, endpoint *telio.SocketAddr) error
+	ConnectToExitNodePostquantum(identifier *string, publicKey telio.PublicKey, allowedIps *[]telio.IpNet, endpoint telio.SocketAddr) error
+	DisconnectFromExitNode(publicKey telio.PublicKey) error
+	DisconnectFromExitNodes() error
+	SetFwmark(fwmark uint32) error
+	SetExtIfFilter(extIfFilter []string) error
+	SetTpLiteDomainWhitelist(domains []string, redirects []telio.DnsRedirect) error
+}
+
+// Options configure a Reloader.
+type Options struct {
+	// Creates a stopped instance, e.g. with telio.NewTelio [required]
+	New func(features telio.Features) (Device, error)
+	// Adapter the instance is started with
+	Adapter telio.TelioAdapterType
+	// Name of the tunnel interface, empty for the platform default
+	Name string
+	// How often the file is checked for changes [default 2s]
+	Interval time.Duration
+	// Parses the features of the file [default DefaultParseFeatures]
+	ParseFeatures ParseFeatures
+	// Reconnects an exit node after a restart [default ConnectToExitNodeWithId,
+	// or ConnectToExitNodePostquantum for post-quantum nodes]
+	Reconnect func(dev Device, node ExitNode) error
+	// Called after a change was applied with the device in use afterwards,
+	// which is a new one after a restart. It may use the Reloader.
+	OnReload func(plan Plan, dev Device)
+	// Called by Run when a reload fails, the running settings stay in place
+	OnError func(error)
+}
+
+// ExitNode is an exit node to reconnect after a restart.
+type ExitNode struct {
+	Identifier *string
+	PublicKey  telio.PublicKey
+	AllowedIps *[]telio.IpNet
+	Endpoint   *telio.SocketAddr
+	// Connected with ConnectToExitNodePostquantum
+	PostQuantum bool
+}
+
+// Reloader watches a config file and applies its changes. Pass meshnet
+// configs through its SetMeshnet and SetMeshnetOff, and exit node connections
+// through its ConnectToExitNode methods, so they survive restarts as they
+// were made. Use Device to reach the current instance.
+type Reloader struct {
+	path string
+	opts Options
+
+	// Serializes reloads and their OnReload calls
+	reloadMu sync.Mutex
+
+	mu      sync.Mutex
+	dev     Device
+	running Settings
+	meshnet *telio.Config
+	exits   map[telio.PublicKey]ExitNode
+	data    []byte
+	modTime time.Time
+	// Content which failed to apply, retried once the file changes
+	failed        []byte
+	failedModTime time.Time
+}
+
+// New creates a reloader for dev, which runs with the settings in the file
+// at path, e.g. as read with ParseSettings.
+func New(path string, dev Device, running Settings, opts Options) (*Reloader, error) {
+	if opts.New == nil {
+		return nil, errors.New("hotreload: Options.New is required")
+	}
+	if opts.Interval <= 0 {
+		opts.Interval = defaultInterval
+	}
+	if opts.ParseFeatures == nil {
+		opts.ParseFeatures = DefaultParseFeatures
+	}
+	if opts.Reconnect == nil {
+		opts.Reconnect = connectExitNode
+	}
+	r := &Reloader{path: path, opts: opts, dev: dev, running: running, exits: make(map[telio.PublicKey]ExitNode)}
+	// The running settings came from the current content
+	if info, err := os.Stat(path); err == nil {
+		r.modTime = info.ModTime()
+		r.data, _ = os.ReadFile(path)
+	}
+	return r, nil
+}
+
+// Device returns the instance in use.
+func (r *Reloader) Device() Device {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.dev
+}
+
+// Settings returns the settings in use.
+func (r *Reloader) Settings() Settings {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.running
+}
+
+// SetMeshnet applies cfg and keeps it for restarts.
+func (r *Reloader) SetMeshnet(cfg telio.Config) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err := r.dev.SetMeshnet(cfg); err != nil {
+		return err
+	}
+	r.meshnet = &cfg
+	return nil
+}
+
+// SetMeshnetOff turns meshnet off, also after restarts.
+func (r *Reloader) SetMeshnetOff() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err := r.dev.SetMeshnetOff(); err != nil {
+		return err
+	}
+	r.meshnet = nil
+	return nil
+}
+
+// ConnectToExitNode connects to an exit node and keeps it for restarts.
+func (r *Reloader) ConnectToExitNode(publicKey telio.PublicKey, allowedIps *[]telio.IpNet, endpoint *telio.SocketAddr) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err := r.dev.ConnectToExitNode(publicKey, allowedIps, endpoint); err != nil {
+		return err
+	}
+	r.exits[publicKey] = ExitNode{PublicKey: publicKey, AllowedIps: allowedIps, Endpoint: endpoint}
+	return nil
+}
+
+// ConnectToExitNodeWithId connects to an exit node and keeps it for restarts.
+func (r *Reloader) ConnectToExitNodeWithId(identifier *string, publicKey telio.PublicKey, allowedIps *[]telio.IpNet, endpoint *telio.SocketAddr) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err := r.dev.ConnectToExitNodeWithId(identifier, publicKey, allowedIps, endpoint); err != nil {
+		return err
+	}
+	r.exits[publicKey] = ExitNode{Identifier: identifier, PublicKey: publicKey, AllowedIps: allowedIps, Endpoint: endpoint}
+	return nil
+}
+
+// ConnectToExitNodePostquantum connects to a VPN server with a post-quantum
+// handshake and keeps it for restarts, which reconnect it the same way.
+func (r *Reloader) ConnectToExitNodePostquantum(identifier *string, publicKey telio.PublicKey, allowedIps *[]telio.IpNet, endpoint telio.SocketAddr) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err := r.dev.ConnectToExitNodePostquantum(identifier, publicKey, allowedIps, endpoint); err != nil {
+		return err
+	}
+	r.exits[publicKey] = ExitNode{Identifier: identifier, PublicKey: publicKey, AllowedIps: allowedIps, Endpoint: &endpoint, PostQuantum: true}
+	return nil
+}
+
+// DisconnectFromExitNode disconnects from an exit node.
+func (r *Reloader) DisconnectFromExitNode(publicKey telio.PublicKey) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err := r.dev.DisconnectFromExitNode(publicKey); err != nil {
+		return err
+	}
+	delete(r.exits, publicKey)
+	return nil
+}
+
+// DisconnectFromExitNodes disconnects from all exit nodes.
+func (r *Reloader) DisconnectFromExitNodes() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err := r.dev.DisconnectFromExitNodes(); err != nil {
+		return err
+	}
+	clear(r.exits)
+	return nil
+}
+
+// Reload applies the file if it changed and returns what was done. Content
+// which failed to apply is not tried again until the file changes.
+func (r *Reloader) Reload() (Plan, error) {
+	r.reloadMu.Lock()
+	defer r.reloadMu.Unlock()
+
+	plan, dev, err := r.reload()
+	// Called unlocked, it may use the Reloader
+	if dev != nil && r.opts.OnReload != nil && !plan.Empty() {
+		r.opts.OnReload(plan, dev)
+	}
+	return plan, err
+}
+
+// reload applies the file if it changed. It returns the device in use when
+// the change was applied.
+func (r *Reloader) reload() (Plan, Device, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	info, err := os.Stat(r.path)
+	if err != nil {
+		return Plan{}, nil, err
+	}
+	if r.data != nil && info.ModTime().Equal(r.modTime) ||
+		r.failed != nil && info.ModTime().Equal(r.failedModTime) {
+		return Plan{}, nil, nil
+	}
+	data, err := os.ReadFile(r.path)
+	if err != nil {
+		return Plan{}, nil, err
+	}
+	if bytes.Equal(data, r.data) {
+		r.modTime = info.ModTime()
+		return Plan{}, nil, nil
+	}
+	if r.failed != nil && bytes.Equal(data, r.failed) {
+		r.failedModTime = info.ModTime()
+		return Plan{}, nil, nil
+	}
+	next, err := ParseSettings(data, r.opts.ParseFeatures)
+	if err != nil {
+		r.failed, r.failedModTime = data, info.ModTime()
+		return Plan{}, nil, fmt.Errorf("%s: %w", r.path, err)
+	}
+
+	plan := Classify(r.running, next)
+	applied := true
+	if plan.Restart() {
+		applied, err = r.restart(next)
+	} else if err = applyLive(r.dev, next, plan.Live); err != nil {
+		applied = false
+	}
+	if !applied {
+		r.failed, r.failedModTime = data, info.ModTime()
+		return plan, nil, err
+	}
+	r.running, r.data, r.modTime = next, data, info.ModTime()
+	r.failed = nil
+	return plan, r.dev, err
+}
+
+// Run checks the file every Options.Interval until ctx is done.
+func (r *Reloader) Run(ctx context.Context) error {
+	ticker := time.NewTicker(r.opts.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+		if _, err := r.Reload(); err != nil && r.opts.OnError != nil {
+			r.opts.OnError(err)
+		}
+	}
+}
+
+// restart replaces the device with one created with next.Features and
+// reports whether it did. When the new one fails to start, the previous
+// instance is started again. Exit nodes which fail to reconnect don't undo
+// the restart.
+func (r *Reloader) restart(next Settings) (bool, error) {
+	nextDev, err := r.opts.New(next.Features)
+	if err != nil {
+		return false, fmt.Errorf("creating instance: %w", err)
+	}
+
+	key := r.dev.GetSecretKey()
+	var exits []ExitNode
+	for _, n := range r.dev.GetStatusMap() {
+		if !(n.IsExit || n.IsVpn) || n.State == telio.NodeStateDisconnected {
+			continue
+		}
+		if e, ok := r.exits[n.PublicKey]; ok {
+			exits = append(exits, e)
+			continue
+		}
+		// Connected through Device, not the Reloader
+		id, allowed := n.Identifier, n.AllowedIps
+		exits = append(exits, ExitNode{Identifier: &id, PublicKey: n.PublicKey, AllowedIps: &allowed, Endpoint: n.Endpoint})
+	}
+	if err := r.dev.Stop(); err != nil {
+		destroy(nextDev)
+		return false, fmt.Errorf("stopping instance: %w", err)
+	}
+
+	startErr := r.start(nextDev, key, next)
+	if startErr == nil {
+		destroy(r.dev)
+		r.dev = nextDev
+		return true, r.reconnect(exits)
+	}
+	_ = nextDev.Stop()
+	destroy(nextDev)
+	if err := r.start(r.dev, key, r.running); err != nil {
+		return false, errors.Join(startErr, fmt.Errorf("restoring previous instance: %w", err))
+	}
+	return false, errors.Join(startErr, r.reconnect(exits))
+}
+
+// start starts dev with the settings s and restores the meshnet config.
+func (r *Reloader) start(dev Device, key telio.SecretKey, s Settings) error {
+	var err error
+	if r.opts.Name != "" || s.ExtIfFilter != nil {
+		var filter []string
+		if s.ExtIfFilter != nil {
+			filter = *s.ExtIfFilter
+		}
+		err = dev.StartNamedExtIfFilter(key, r.opts.Adapter, r.opts.Name, filter)
+	} else {
+		err = dev.Start(key, r.opts.Adapter)
+	}
+	if err != nil {
+		return fmt.Errorf("starting instance: %w", err)
+	}
+
+	// The filter was passed to start
+	if err := applyLive(dev, s, []string{LiveFwmark, LiveTpLiteWhitelist}); err != nil {
+		return err
+	}
+	if r.meshnet != nil {
+		if err := dev.SetMeshnet(*r.meshnet); err != nil {
+			return fmt.Errorf("restoring meshnet: %w", err)
+		}
+	}
+	return nil
+}
+
+// reconnect connects the device to the exit nodes of the previous instance.
+func (r *Reloader) reconnect(exits []ExitNode) error {
+	var errs []error
+	for _, n := range exits {
+		if err := r.opts.Reconnect(r.dev, n); err != nil {
+			errs = append(errs, fmt.Errorf("reconnecting exit node %s: %w", n.PublicKey, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// applyLive applies the named settings of s which are set.
+func applyLive(dev Device, s Settings, names []string) error {
+	for _, name := range names {
+		var err error
+		switch {
+		case name == LiveFwmark && s.Fwmark != nil:
+			err = dev.SetFwmark(*s.Fwmark)
+		case name == LiveExtIfFilter && s.ExtIfFilter != nil:
+			err = dev.SetExtIfFilter(*s.ExtIfFilter)
+		case name == LiveTpLiteWhitelist && s.TpLiteWhitelist != nil:
+			err = dev.SetTpLiteDomainWhitelist(s.TpLiteWhitelist.Domains, s.TpLiteWhitelist.Redirects)
+		}
+		if err != nil {
+			return fmt.Errorf("applying %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+func connectExitNode(dev Device, n ExitNode) error {
+	if n.PostQuantum {
+		return dev.ConnectToExitNodePostquantum(n.Identifier, n.PublicKey, n.AllowedIps, *n.Endpoint)
+	}
+	return dev.ConnectToExitNodeWithId(n.Identifier, n.PublicKey, n.AllowedIps, n.Endpoint)
+}
+
+// destroy frees the native object of dev, if it has one.
+func destroy(dev Device) {
+	if d, ok := dev.(interface{ Destroy() }); ok {
+		d.Destroy()
+	}
+}
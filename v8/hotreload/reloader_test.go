@@ -0,0 +1,210 @@
+package hotreload
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	telio "github.com/NordSecurity/libtelio-go/v8"
+)
+
+// fakeDevice records how exit nodes are connected.
+type fakeDevice struct {
+	startErr error
+	started  bool
+	nodes    []telio.TelioNode
+	// "pq" or "id" by public key
+	connected map[telio.PublicKey]string
+}
+
+func newFakeDevice() *fakeDevice {
+	return &fakeDevice{connected: make(map[telio.PublicKey]string)}
+}
+
+func (d *fakeDevice) GetSecretKey() telio.SecretKey { return "key" }
+
+func (d *fakeDevice) Start(telio.SecretKey, telio.TelioAdapterType) error {
+	d.started = d.startErr == nil
+	return d.startErr
+}
+
+func (d *fakeDevice) StartNamedExtIfFilter(key telio.SecretKey, adapter telio.TelioAdapterType, _ string, _ []string) error {
+	return d.Start(key, adapter)
+}
+
+func (d *fakeDevice) Stop() error {
+	d.started = false
+	return nil
+}
+
+func (d *fakeDevice) SetMeshnet(telio.Config) error                { return nil }
+func (d *fakeDevice) SetMeshnetOff() error                         { return nil }
+func (d *fakeDevice) GetStatusMap() []telio.TelioNode              { return d.nodes }
+func (d *fakeDevice) SetFwmark(uint32) error                       { return nil }
+func (d *fakeDevice) SetExtIfFilter([]string) error                { return nil }
+func (d *fakeDevice) DisconnectFromExitNodes() error               { return nil }
+func (d *fakeDevice) DisconnectFromExitNode(telio.PublicKey) error { return nil }
+
+func (d *fakeDevice) SetTpLiteDomainWhitelist([]string, []telio.DnsRedirect) error { return nil }
+
+func (d *fakeDevice) connect(key telio.PublicKey, how string) error {
+	d.connected[key] = how
+	d.nodes = append(d.nodes, telio.TelioNode{PublicKey: key, IsVpn: true, State: telio.NodeStateConnected})
+	return nil
+}
+
+func (d *fakeDevice) ConnectToExitNode(key telio.PublicKey, _ *[]telio.IpNet, _ *telio.SocketAddr) error {
+	return d.connect(key, "id")
+}
+
+func (d *fakeDevice) ConnectToExitNodeWithId(_ *string, key telio.PublicKey, _ *[]telio.IpNet, _ *telio.SocketAddr) error {
+	return d.connect(key, "id")
+}
+
+func (d *fakeDevice) ConnectToExitNodePostquantum(_ *string, key telio.PublicKey, _ *[]telio.IpNet, _ telio.SocketAddr) error {
+	return d.connect(key, "pq")
+}
+
+func parseJSON(data []byte) (telio.Features, error) {
+	var f telio.Features
+	err := json.Unmarshal(data, &f)
+	return f, err
+}
+
+// write replaces the file and gives it a modification time of its own.
+func write(t *testing.T, path, content string, mtime time.Time) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(path, mtime, mtime); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func newReloader(t *testing.T, dev *fakeDevice, newDev func() *fakeDevice, created *int) (*Reloader, string) {
+	t.Helper()
+	return newReloaderOpts(t, dev, newDev, created, Options{})
+}
+
+func newReloaderOpts(t *testing.T, dev *fakeDevice, newDev func() *fakeDevice, created *int, opts Options) (*Reloader, string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "telio.json")
+	now := time.Now()
+	write(t, path, `{"features": {"ipv6": false}}`, now)
+	running, err := ParseSettings([]byte(`{"features": {"ipv6": false}}`), parseJSON)
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts.New = func(telio.Features) (Device, error) {
+		*created++
+		return newDev(), nil
+	}
+	opts.ParseFeatures = parseJSON
+	r, err := New(path, dev, running, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return r, path
+}
+
+func TestReloadFailedStartNotRetried(t *testing.T) {
+	dev := newFakeDevice()
+	created := 0
+	r, path := newReloader(t, dev, func() *fakeDevice {
+		d := newFakeDevice()
+		d.startErr = errors.New("tunnel busy")
+		return d
+	}, &created)
+	mtime := time.Now().Add(time.Minute)
+
+	write(t, path, `{"features": {"ipv6": true}}`, mtime)
+	if _, err := r.Reload(); err == nil {
+		t.Fatal("restart with a failing instance succeeded")
+	}
+	if created != 1 || r.Device() != Device(dev) || !dev.started {
+		t.Fatal("previous instance not restored")
+	}
+
+	// Neither another tick nor rewriting the same content restarts again
+	if _, err := r.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	write(t, path, `{"features": {"ipv6": true}}`, mtime.Add(time.Minute))
+	if _, err := r.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if created != 1 {
+		t.Fatalf("failed content retried, %d instances created", created)
+	}
+
+	write(t, path, `{"features": {"ipv6": true, "nicknames": true}}`, mtime.Add(2*time.Minute))
+	if _, err := r.Reload(); err == nil || created != 2 {
+		t.Fatalf("changed content not tried, %d instances created", created)
+	}
+}
+
+func TestRestartReconnectsPostQuantum(t *testing.T) {
+	dev := newFakeDevice()
+	var next *fakeDevice
+	created := 0
+	r, path := newReloader(t, dev, func() *fakeDevice {
+		next = newFakeDevice()
+		return next
+	}, &created)
+
+	if err := r.ConnectToExitNodePostquantum(nil, "pq-server", nil, "192.0.2.1:51820"); err != nil {
+		t.Fatal(err)
+	}
+	// Not connected through the Reloader
+	if err := dev.ConnectToExitNodeWithId(nil, "classic-server", nil, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	write(t, path, `{"features": {"ipv6": true}}`, time.Now().Add(time.Minute))
+	plan, err := r.Reload()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !plan.Restart() || r.Device() != Device(next) {
+		t.Fatalf("no restart for %v", plan)
+	}
+	if next.connected["pq-server"] != "pq" || next.connected["classic-server"] != "id" {
+		t.Fatalf("reconnected %v", next.connected)
+	}
+}
+
+func TestOnReloadMayUseReloader(t *testing.T) {
+	var r *Reloader
+	var got Device
+	var settings Settings
+	created := 0
+	r, path := newReloaderOpts(t, newFakeDevice(), newFakeDevice, &created, Options{
+		OnReload: func(_ Plan, dev Device) {
+			got, settings = r.Device(), r.Settings()
+			if err := r.SetMeshnetOff(); err != nil {
+				t.Error(err)
+			}
+		},
+	})
+
+	write(t, path, `{"features": {"ipv6": true}}`, time.Now().Add(time.Minute))
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		if _, err := r.Reload(); err != nil {
+			t.Error(err)
+		}
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("OnReload deadlocked")
+	}
+	if got == nil || got != r.Device() || !settings.Features.Ipv6 {
+		t.Fatal("OnReload did not see the new instance")
+	}
+}
@@ -0,0 +1,173 @@
+package hotreload
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+
+	telio "github.com/NordSecurity/libtelio-go/v8"
+	"github.com/NordSecurity/libtelio-go/v8/schema"
+)
+
+// Names of the settings applied live, as used in Plan.Live and in the file.
+const (
+	LiveFwmark          = "fwmark"
+	LiveExtIfFilter     = "ext_if_filter"
+	LiveTpLiteWhitelist = "tp_lite_whitelist"
+)
+
+// Settings is the content of a config file:
+//
+//	{
+//	  "features": { ... },
+//	  "fwmark": 11673110,
+//	  "ext_if_filter": ["eth0"],
+//	  "tp_lite_whitelist": {"domains": ["example.com"], "redirects": []}
+//	}
+//
+// Only "features" is used to create the instance, the other settings are
+// optional and applied through their setters.
+type Settings struct {
+	Features telio.Features
+	// Applied with SetFwmark when set
+	Fwmark *uint32
+	// Applied with SetExtIfFilter when set
+	ExtIfFilter *[]string
+	// Applied with SetTpLiteDomainWhitelist when set
+	TpLiteWhitelist *Whitelist
+}
+
+// Whitelist is the argument of SetTpLiteDomainWhitelist.
+type Whitelist struct {
+	Domains   []string            `json:"domains"`
+	Redirects []telio.DnsRedirect `json:"redirects"`
+}
+
+type settingsFile struct {
+	Features        json.RawMessage `json:"features"`
+	Fwmark          *uint32         `json:"fwmark"`
+	ExtIfFilter     *[]string       `json:"ext_if_filter"`
+	TpLiteWhitelist *Whitelist      `json:"tp_lite_whitelist"`
+}
+
+// ParseFeatures parses the features JSON of a config file.
+type ParseFeatures func(data []byte) (telio.Features, error)
+
+// DefaultParseFeatures validates features with schema.ValidateFeatures and
+// deserializes them with libtelio, which fills in its defaults.
+func DefaultParseFeatures(data []byte) (telio.Features, error) {
+	if err := schema.ValidateFeatures(data); err != nil {
+		return telio.Features{}, err
+	}
+	return telio.DeserializeFeatureConfig(string(data))
+}
+
+// ParseSettings parses a config file, using DefaultParseFeatures when parse
+// is nil. Missing features get libtelio's defaults.
+func ParseSettings(data []byte, parse ParseFeatures) (Settings, error) {
+	if parse == nil {
+		parse = DefaultParseFeatures
+	}
+	var f settingsFile
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&f); err != nil {
+		return Settings{}, err
+	}
+	if len(f.Features) == 0 || string(f.Features) == "null" {
+		f.Features = json.RawMessage("{}")
+	}
+	features, err := parse(f.Features)
+	if err != nil {
+		return Settings{}, fmt.Errorf("features: %w", err)
+	}
+	return Settings{
+		Features:        features,
+		Fwmark:          f.Fwmark,
+		ExtIfFilter:     f.ExtIfFilter,
+		TpLiteWhitelist: f.TpLiteWhitelist,
+	}, nil
+}
+
+// Plan describes how to get from running settings to new ones.
+type Plan struct {
+	// JSON paths of the changed features, e.g. "nurse.heartbeat_interval".
+	// libtelio has no setter for any of them, so they need a restart.
+	Features []string
+	// Settings which changed and are applied live, see the Live constants
+	Live []string
+}
+
+// Restart reports whether the instance must be recreated.
+func (p Plan) Restart() bool {
+	return len(p.Features) > 0
+}
+
+// Empty reports whether nothing changed.
+func (p Plan) Empty() bool {
+	return len(p.Features) == 0 && len(p.Live) == 0
+}
+
+func (p Plan) String() string {
+	switch {
+	case p.Empty():
+		return "no changes"
+	case p.Restart():
+		return "restart for " + strings.Join(p.Features, ", ")
+	}
+	return "apply " + strings.Join(p.Live, ", ")
+}
+
+// Classify compares the running settings with new ones. Settings removed
+// from the file are not reverted, libtelio keeps their last value.
+func Classify(running, next Settings) Plan {
+	var p Plan
+	diffValues("", reflect.ValueOf(running.Features), reflect.ValueOf(next.Features), &p.Features)
+	if next.Fwmark != nil && (running.Fwmark == nil || *running.Fwmark != *next.Fwmark) {
+		p.Live = append(p.Live, LiveFwmark)
+	}
+	if next.ExtIfFilter != nil && (running.ExtIfFilter == nil || !slices.Equal(*running.ExtIfFilter, *next.ExtIfFilter)) {
+		p.Live = append(p.Live, LiveExtIfFilter)
+	}
+	if next.TpLiteWhitelist != nil && (running.TpLiteWhitelist == nil || !reflect.DeepEqual(*running.TpLiteWhitelist, *next.TpLiteWhitelist)) {
+		p.Live = append(p.Live, LiveTpLiteWhitelist)
+	}
+	return p
+}
+
+// diffValues appends the paths at which a and b differ, descending into
+// structs present on both sides.
+func diffValues(path string, a, b reflect.Value, out *[]string) {
+	switch a.Kind() {
+	case reflect.Pointer:
+		if a.IsNil() || b.IsNil() {
+			if a.IsNil() != b.IsNil() {
+				*out = append(*out, path)
+			}
+			return
+		}
+		diffValues(path, a.Elem(), b.Elem(), out)
+	case reflect.Struct:
+		t := a.Type()
+		for i := 0; i < t.NumField(); i++ {
+			name := telio.JSONName(t.Field(i))
+			if path != "" {
+				name = path + "." + name
+			}
+			diffValues(name, a.Field(i), b.Field(i), out)
+		}
+	case reflect.Slice:
+		// libtelio does not tell nil from empty
+		if a.Len() == 0 && b.Len() == 0 {
+			return
+		}
+		fallthrough
+	default:
+		if !reflect.DeepEqual(a.Interface(), b.Interface()) {
+			*out = append(*out, path)
+		}
+	}
+}
@@ -0,0 +1,380 @@
+// Package keyrotation periodically replaces the device secret key without
+// breaking meshnet connections.
+//
+// A rotation generates a new key, registers its public key with the
+// application's backend and waits for the backend to publish a meshnet config
+// for it. Only then libtelio switches to the new key. When no peer reconnects
+// within a grace period, the previous key is restored and registered again.
+// The new key is written to the key store once a peer reconnected with it, so
+// a process dying during a rotation restarts with the previous key; register
+// the stored key again on start up, as a rollback would.
+package keyrotation
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	telio "github.com/NordSecurity/libtelio-go/v8"
+	"github.com/NordSecurity/libtelio-go/v8/keys"
+	"github.com/NordSecurity/libtelio-go/v8/keystore"
+)
+
+const (
+	eventQueueSize = 256
+
+	defaultInterval        = 30 * 24 * time.Hour
+	defaultRetryInterval   = time.Hour
+	defaultConfigTimeout   = 5 * time.Minute
+	defaultGracePeriod     = time.Minute
+	defaultRollbackTimeout = 30 * time.Second
+)
+
+var (
+	// ErrConfigTimeout is returned when the backend did not publish a meshnet
+	// config for the new key in time
+	ErrConfigTimeout = errors.New("no meshnet config for the new key")
+	// ErrPeersLost is returned when no peer reconnected with the new key
+	ErrPeersLost = errors.New("no peer reconnected with the new key")
+)
+
+// Device is the part of telio.TelioInterface used by a Scheduler.
+type Device interface {
+	keystore.Device
+	SetMeshnet(cfg telio.Config) error
+	SetMeshnetOff() error
+}
+
+// Register tells the application's backend to use publicKey for this device
+// from now on.
+type Register func(ctx context.Context, publicKey telio.PublicKey) error
+
+// Options configure a Scheduler.
+type Options struct {
+	// How often the key is rotated [default 720h]
+	Interval time.Duration
+	// When the current key was created, the first rotation is due Interval
+	// later [default when Run starts]
+	KeyCreated time.Time
+	// How long to wait before retrying a failed rotation [default 1h]
+	RetryInterval time.Duration
+	// How long the backend may take to publish a meshnet config for the new
+	// key [default 5m]
+	ConfigTimeout time.Duration
+	// How long peers have to reconnect with the new key [default 1m]
+	GracePeriod time.Duration
+	// Called after every rotation attempt, e.g. to persist KeyCreated
+	OnRotation func(Result)
+}
+
+// Result describes a rotation attempt.
+type Result struct {
+	// When the rotation finished
+	Time           time.Time
+	Previous, Next keys.PublicKey
+	// Set when the previous key was restored
+	RolledBack bool
+	Err        error
+}
+
+// Scheduler rotates the secret key of a device. Pass every meshnet config
+// through its SetMeshnet and SetMeshnetOff instead of calling the device
+// directly and feed it with events through Observe.
+//
+// The key the device runs with must be the one in the key store, see
+// keystore.Sync.
+type Scheduler struct {
+	dev      Device
+	ks       keystore.KeyStore
+	register Register
+	opts     Options
+	events   chan telio.TelioNode
+	configs  chan telio.Config
+
+	// Held during a rotation
+	rotating sync.Mutex
+
+	mu        sync.Mutex
+	cfg       *telio.Config
+	pending   *telio.PublicKey
+	connected map[telio.PublicKey]bool
+}
+
+// New creates a scheduler rotating the key of dev stored in ks.
+func New(dev Device, ks keystore.KeyStore, register Register, opts Options) *Scheduler {
+	if opts.Interval <= 0 {
+		opts.Interval = defaultInterval
+	}
+	if opts.RetryInterval <= 0 {
+		opts.RetryInterval = defaultRetryInterval
+	}
+	if opts.ConfigTimeout <= 0 {
+		opts.ConfigTimeout = defaultConfigTimeout
+	}
+	if opts.GracePeriod <= 0 {
+		opts.GracePeriod = defaultGracePeriod
+	}
+	return &Scheduler{
+		dev:       dev,
+		ks:        ks,
+		register:  register,
+		opts:      opts,
+		events:    make(chan telio.TelioNode, eventQueueSize),
+		configs:   make(chan telio.Config, 1),
+		connected: make(map[telio.PublicKey]bool),
+	}
+}
+
+// Observe forwards meshnet peer events to the scheduler. It never blocks.
+func (s *Scheduler) Observe(event telio.Event) {
+	e, ok := event.(telio.EventNode)
+	if !ok || e.Body.IsExit || e.Body.IsVpn {
+		return
+	}
+	s.mu.Lock()
+	if e.Body.State == telio.NodeStateConnected {
+		s.connected[e.Body.PublicKey] = true
+	} else {
+		delete(s.connected, e.Body.PublicKey)
+	}
+	s.mu.Unlock()
+	select {
+	case s.events <- e.Body:
+	default:
+	}
+}
+
+// SetMeshnet applies cfg. A config for a key being rotated to is held back
+// until the device switched to that key.
+func (s *Scheduler) SetMeshnet(cfg telio.Config) error {
+	s.mu.Lock()
+	if s.pending != nil && cfg.This.PublicKey == *s.pending {
+		s.mu.Unlock()
+		// Keep only the latest config
+		select {
+		case <-s.configs:
+		default:
+		}
+		select {
+		case s.configs <- cfg:
+		default:
+		}
+		return nil
+	}
+	s.cfg = &cfg
+	s.mu.Unlock()
+	return s.dev.SetMeshnet(cfg)
+}
+
+// SetMeshnetOff turns meshnet off. Later rotations do not wait for a meshnet
+// config.
+func (s *Scheduler) SetMeshnetOff() error {
+	s.mu.Lock()
+	s.cfg = nil
+	s.mu.Unlock()
+	return s.dev.SetMeshnetOff()
+}
+
+// Run rotates the key every Interval until ctx is done.
+func (s *Scheduler) Run(ctx context.Context) error {
+	created := s.opts.KeyCreated
+	if created.IsZero() {
+		created = time.Now()
+	}
+	t := time.NewTimer(time.Until(created.Add(s.opts.Interval)))
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-t.C:
+		}
+		wait := s.opts.Interval
+		if err := s.Rotate(ctx); err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			wait = s.opts.RetryInterval
+		}
+		t.Reset(wait)
+	}
+}
+
+// Rotate rotates the key now. When ctx is done before the rotation finished,
+// the previous key is restored.
+func (s *Scheduler) Rotate(ctx context.Context) error {
+	s.rotating.Lock()
+	defer s.rotating.Unlock()
+	res := s.rotate(ctx)
+	res.Time = time.Now()
+	if s.opts.OnRotation != nil {
+		s.opts.OnRotation(res)
+	}
+	return res.Err
+}
+
+func (s *Scheduler) rotate(ctx context.Context) Result {
+	if err := keystore.Verify(s.dev, s.ks); err != nil {
+		return Result{Err: fmt.Errorf("checking running key: %w", err)}
+	}
+	rot, err := keystore.Rotate(s.ks)
+	if err != nil {
+		return Result{Err: err}
+	}
+	defer rot.Zero()
+	previous, next := rot.Previous, rot.Next
+	res := Result{Previous: previous.PublicKey(), Next: next.PublicKey()}
+
+	nextPub := res.Next.Telio()
+	s.mu.Lock()
+	s.pending = &nextPub
+	oldCfg := s.cfg
+	s.mu.Unlock()
+	defer s.clearPending()
+
+	fail := func(err error, swapped bool) Result {
+		res.Err = err
+		res.RolledBack = swapped
+		if rerr := s.rollback(ctx, previous, oldCfg, swapped); rerr != nil {
+			res.Err = errors.Join(err, fmt.Errorf("rolling back: %w", rerr))
+		}
+		return res
+	}
+
+	if err := s.register(ctx, nextPub); err != nil {
+		return fail(fmt.Errorf("registering new key: %w", err), false)
+	}
+	var cfg *telio.Config
+	if oldCfg != nil {
+		c, err := s.waitConfig(ctx)
+		if err != nil {
+			return fail(err, false)
+		}
+		cfg = &c
+	}
+
+	s.drainEvents()
+	expected := s.expected(cfg)
+	if err := s.dev.SetSecretKey(next.Telio()); err != nil {
+		return fail(fmt.Errorf("setting new key: %w", err), true)
+	}
+	if cfg != nil {
+		if err := s.dev.SetMeshnet(*cfg); err != nil {
+			return fail(fmt.Errorf("setting meshnet config: %w", err), true)
+		}
+		s.applied(cfg)
+	}
+
+	if err := s.waitPeers(ctx, expected); err != nil {
+		return fail(err, true)
+	}
+	if err := rot.Commit(); err != nil {
+		return fail(err, true)
+	}
+	return res
+}
+
+// waitConfig waits for a meshnet config for the pending key.
+func (s *Scheduler) waitConfig(ctx context.Context) (telio.Config, error) {
+	t := time.NewTimer(s.opts.ConfigTimeout)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return telio.Config{}, ctx.Err()
+	case <-t.C:
+		return telio.Config{}, fmt.Errorf("%w within %s", ErrConfigTimeout, s.opts.ConfigTimeout)
+	case cfg := <-s.configs:
+		return cfg, nil
+	}
+}
+
+// expected returns the peers of cfg connected with the previous key. Peers
+// which were offline anyway can't tell whether the new key works.
+func (s *Scheduler) expected(cfg *telio.Config) map[telio.PublicKey]bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	expected := make(map[telio.PublicKey]bool)
+	if cfg == nil || cfg.Peers == nil {
+		return expected
+	}
+	for _, p := range *cfg.Peers {
+		if s.connected[p.Base.PublicKey] {
+			expected[p.Base.PublicKey] = true
+		}
+	}
+	return expected
+}
+
+// waitPeers waits until one of the expected peers connected. A single peer
+// suffices: when the backend failed to distribute the new key, none of them
+// can connect, while requiring all would roll back whenever a peer happens to
+// go offline.
+func (s *Scheduler) waitPeers(ctx context.Context, expected map[telio.PublicKey]bool) error {
+	if len(expected) == 0 {
+		return nil
+	}
+	t := time.NewTimer(s.opts.GracePeriod)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-t.C:
+			return fmt.Errorf("%w within %s", ErrPeersLost, s.opts.GracePeriod)
+		case node := <-s.events:
+			if expected[node.PublicKey] && node.State == telio.NodeStateConnected {
+				return nil
+			}
+		}
+	}
+}
+
+// rollback restores the previous key in the device when it was already
+// swapped and registers it again. The key store still holds it. It runs even
+// when ctx is done.
+func (s *Scheduler) rollback(ctx context.Context, previous keys.SecretKey, cfg *telio.Config, swapped bool) error {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), defaultRollbackTimeout)
+	defer cancel()
+
+	var errs []error
+	if swapped {
+		errs = append(errs, s.dev.SetSecretKey(previous.Telio()))
+		if cfg != nil {
+			errs = append(errs, s.dev.SetMeshnet(*cfg))
+			s.applied(cfg)
+		}
+	}
+	errs = append(errs, s.register(ctx, previous.PublicKey().Telio()))
+	return errors.Join(errs...)
+}
+
+// applied records cfg as the running config and stops holding back configs.
+func (s *Scheduler) applied(cfg *telio.Config) {
+	s.mu.Lock()
+	s.cfg = cfg
+	s.pending = nil
+	s.mu.Unlock()
+}
+
+func (s *Scheduler) clearPending() {
+	s.mu.Lock()
+	s.pending = nil
+	s.mu.Unlock()
+	select {
+	case <-s.configs:
+	default:
+	}
+}
+
+// drainEvents drops events received with the previous key.
+func (s *Scheduler) drainEvents() {
+	for {
+		select {
+		case <-s.events:
+		default:
+			return
+		}
+	}
+}
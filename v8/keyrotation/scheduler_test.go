@@ -0,0 +1,199 @@
+package keyrotation
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	telio "github.com/NordSecurity/libtelio-go/v8"
+	"github.com/NordSecurity/libtelio-go/v8/keys"
+	"github.com/NordSecurity/libtelio-go/v8/keystore"
+)
+
+// memStore is a KeyStore in memory.
+type memStore struct {
+	mu       sync.Mutex
+	key      *keys.SecretKey
+	storeErr error
+}
+
+func (m *memStore) Load() (keys.SecretKey, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.key == nil {
+		return keys.SecretKey{}, keystore.ErrNotFound
+	}
+	return *m.key, nil
+}
+
+func (m *memStore) Store(key keys.SecretKey) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.storeErr != nil {
+		return m.storeErr
+	}
+	m.key = &key
+	return nil
+}
+
+func (m *memStore) stored(t *testing.T) keys.SecretKey {
+	t.Helper()
+	key, err := m.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+// fakeDevice records the key and meshnet config it runs with.
+type fakeDevice struct {
+	mu  sync.Mutex
+	key telio.SecretKey
+	cfg *telio.Config
+}
+
+func (d *fakeDevice) GetSecretKey() telio.SecretKey {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.key
+}
+
+func (d *fakeDevice) SetSecretKey(key telio.SecretKey) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.key = key
+	return nil
+}
+
+func (d *fakeDevice) SetMeshnet(cfg telio.Config) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.cfg = &cfg
+	return nil
+}
+
+func (d *fakeDevice) SetMeshnetOff() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.cfg = nil
+	return nil
+}
+
+func (d *fakeDevice) running(t *testing.T) keys.SecretKey {
+	t.Helper()
+	key, err := keys.SecretKeyFromTelio(d.GetSecretKey())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+const peerKey telio.PublicKey = "peer"
+
+func meshnetConfig(this keys.PublicKey) telio.Config {
+	return telio.Config{
+		This:  telio.PeerBase{PublicKey: this.Telio()},
+		Peers: &[]telio.Peer{{Base: telio.PeerBase{PublicKey: peerKey}}},
+	}
+}
+
+func peerState(state telio.NodeState) telio.Event {
+	return telio.EventNode{Body: telio.TelioNode{PublicKey: peerKey, State: state}}
+}
+
+// setup returns a device running the stored key with meshnet on and the
+// peer connected.
+func setup(t *testing.T) (*fakeDevice, *memStore, keys.SecretKey) {
+	t.Helper()
+	ks := &memStore{}
+	key, _, err := keystore.LoadOrGenerate(ks)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &fakeDevice{key: key.Telio()}, ks, key
+}
+
+func TestRotateCommitsAfterPeersReconnect(t *testing.T) {
+	dev, ks, previous := setup(t)
+	var s *Scheduler
+	s = New(dev, ks, func(ctx context.Context, pub telio.PublicKey) error {
+		if pub == previous.PublicKey().Telio() {
+			return nil
+		}
+		// A crash from here on restarts with the previous key
+		if !ks.stored(t).Equal(previous) {
+			t.Error("new key stored before the peers reconnected")
+		}
+		next, err := keys.PublicKeyFromTelio(pub)
+		if err != nil {
+			return err
+		}
+		go func() {
+			s.SetMeshnet(meshnetConfig(next))
+			time.Sleep(10 * time.Millisecond)
+			if !ks.stored(t).Equal(previous) {
+				t.Error("new key stored before the peers reconnected")
+			}
+			s.Observe(peerState(telio.NodeStateConnected))
+		}()
+		return nil
+	}, Options{GracePeriod: 5 * time.Second, ConfigTimeout: 5 * time.Second})
+	if err := s.SetMeshnet(meshnetConfig(previous.PublicKey())); err != nil {
+		t.Fatal(err)
+	}
+	s.Observe(peerState(telio.NodeStateConnected))
+
+	if err := s.Rotate(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	next := dev.running(t)
+	if next.Equal(previous) || !ks.stored(t).Equal(next) {
+		t.Fatal("device and store do not agree on the new key")
+	}
+}
+
+func TestRotateRollsBackWithoutTouchingStore(t *testing.T) {
+	dev, ks, previous := setup(t)
+	var registered []telio.PublicKey
+	var s *Scheduler
+	s = New(dev, ks, func(ctx context.Context, pub telio.PublicKey) error {
+		registered = append(registered, pub)
+		if next, err := keys.PublicKeyFromTelio(pub); err == nil && pub != previous.PublicKey().Telio() {
+			go s.SetMeshnet(meshnetConfig(next))
+		}
+		return nil
+	}, Options{GracePeriod: 20 * time.Millisecond, ConfigTimeout: 5 * time.Second})
+	if err := s.SetMeshnet(meshnetConfig(previous.PublicKey())); err != nil {
+		t.Fatal(err)
+	}
+	s.Observe(peerState(telio.NodeStateConnected))
+
+	err := s.Rotate(context.Background())
+	if !errors.Is(err, ErrPeersLost) {
+		t.Fatalf("got %v, want ErrPeersLost", err)
+	}
+	if !dev.running(t).Equal(previous) || !ks.stored(t).Equal(previous) {
+		t.Fatal("previous key not restored")
+	}
+	if len(registered) != 2 || registered[1] != previous.PublicKey().Telio() {
+		t.Fatalf("registered %v, want the previous key registered again", registered)
+	}
+}
+
+func TestRotateCommitFailure(t *testing.T) {
+	dev, ks, previous := setup(t)
+	ks.storeErr = errors.New("read-only file system")
+	s := New(dev, ks, func(context.Context, telio.PublicKey) error { return nil }, Options{})
+
+	// Meshnet is off, the rotation only has to store the key
+	var res Result
+	s.opts.OnRotation = func(r Result) { res = r }
+	if err := s.Rotate(context.Background()); err == nil {
+		t.Fatal("rotation succeeded without storing the key")
+	}
+	if !res.RolledBack || !dev.running(t).Equal(previous) {
+		t.Fatal("device keeps a key that was not stored")
+	}
+}
@@ -0,0 +1,194 @@
+// Package keys provides typed WireGuard keys.
+//
+// telio.PublicKey and telio.SecretKey are plain strings, so a malformed key is
+// only noticed when libtelio rejects it. The types of this package are
+// validated when parsed, derive public keys in Go with crypto/ecdh and
+// convert back to the strings expected by libtelio with their Telio methods.
+package keys
+
+import (
+	"crypto/ecdh"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+
+	telio "github.com/NordSecurity/libtelio-go/v8"
+)
+
+// Size is the size of a key in bytes.
+const Size = 32
+
+// ErrInvalidKey is returned when parsing a malformed key.
+var ErrInvalidKey = errors.New("invalid key")
+
+// PublicKey is an X25519 public key.
+type PublicKey [Size]byte
+
+// SecretKey is an X25519 secret key. String and GoString redact it, use
+// Base64 or Hex to format it on purpose.
+type SecretKey [Size]byte
+
+// GenerateSecretKey returns a new clamped secret key.
+func GenerateSecretKey() (SecretKey, error) {
+	priv, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return SecretKey{}, err
+	}
+	var k SecretKey
+	copy(k[:], priv.Bytes())
+	k.clamp()
+	return k, nil
+}
+
+// ParsePublicKey parses a base64 or hex encoded public key.
+func ParsePublicKey(s string) (PublicKey, error) {
+	var k PublicKey
+	err := decode(k[:], s)
+	return k, err
+}
+
+// ParseSecretKey parses a base64 or hex encoded secret key.
+func ParseSecretKey(s string) (SecretKey, error) {
+	var k SecretKey
+	err := decode(k[:], s)
+	return k, err
+}
+
+// PublicKeyFromTelio converts a key of the string based API.
+func PublicKeyFromTelio(k telio.PublicKey) (PublicKey, error) {
+	return ParsePublicKey(k)
+}
+
+// SecretKeyFromTelio converts a key of the string based API.
+func SecretKeyFromTelio(k telio.SecretKey) (SecretKey, error) {
+	return ParseSecretKey(k)
+}
+
+// Telio returns the key as expected by libtelio.
+func (k PublicKey) Telio() telio.PublicKey {
+	return k.Base64()
+}
+
+// Base64 returns the standard base64 encoding of the key.
+func (k PublicKey) Base64() string {
+	return base64.StdEncoding.EncodeToString(k[:])
+}
+
+// Hex returns the lower case hex encoding of the key.
+func (k PublicKey) Hex() string {
+	return hex.EncodeToString(k[:])
+}
+
+func (k PublicKey) String() string {
+	return k.Base64()
+}
+
+// IsZero reports whether the key is all zeros.
+func (k PublicKey) IsZero() bool {
+	return k.Equal(PublicKey{})
+}
+
+// Equal compares two keys in constant time.
+func (k PublicKey) Equal(o PublicKey) bool {
+	return subtle.ConstantTimeCompare(k[:], o[:]) == 1
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (k PublicKey) MarshalText() ([]byte, error) {
+	return []byte(k.Base64()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (k *PublicKey) UnmarshalText(text []byte) error {
+	return decode(k[:], string(text))
+}
+
+// Telio returns the key as expected by libtelio.
+func (k SecretKey) Telio() telio.SecretKey {
+	return k.Base64()
+}
+
+// PublicKey derives the public key.
+func (k SecretKey) PublicKey() PublicKey {
+	priv, err := ecdh.X25519().NewPrivateKey(k[:])
+	if err != nil {
+		// Only fails for keys of the wrong size
+		panic(err)
+	}
+	var pub PublicKey
+	copy(pub[:], priv.PublicKey().Bytes())
+	return pub
+}
+
+// Base64 returns the standard base64 encoding of the key.
+func (k SecretKey) Base64() string {
+	return base64.StdEncoding.EncodeToString(k[:])
+}
+
+// Hex returns the lower case hex encoding of the key.
+func (k SecretKey) Hex() string {
+	return hex.EncodeToString(k[:])
+}
+
+func (k SecretKey) String() string {
+	return "SecretKey(redacted)"
+}
+
+// GoString implements fmt.GoStringer, so %#v does not leak the key either.
+func (k SecretKey) GoString() string {
+	return k.String()
+}
+
+// IsZero reports whether the key is all zeros, e.g. after Zero.
+func (k SecretKey) IsZero() bool {
+	return k.Equal(SecretKey{})
+}
+
+// Equal compares two keys in constant time.
+func (k SecretKey) Equal(o SecretKey) bool {
+	return subtle.ConstantTimeCompare(k[:], o[:]) == 1
+}
+
+// Zero overwrites the key. Copies made earlier are not affected.
+func (k *SecretKey) Zero() {
+	clear(k[:])
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (k SecretKey) MarshalText() ([]byte, error) {
+	return []byte(k.Base64()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (k *SecretKey) UnmarshalText(text []byte) error {
+	return decode(k[:], string(text))
+}
+
+// clamp applies the X25519 clamping also done by wg genkey.
+func (k *SecretKey) clamp() {
+	k[0] &= 248
+	k[31] = (k[31] & 127) | 64
+}
+
+func decode(dst []byte, s string) error {
+	var buf [Size * 2]byte
+	var n int
+	var err error
+	switch len(s) {
+	case base64.StdEncoding.EncodedLen(Size):
+		n, err = base64.StdEncoding.Decode(buf[:], []byte(s))
+	case hex.EncodedLen(Size):
+		n, err = hex.Decode(buf[:], []byte(s))
+	default:
+		return fmt.Errorf("%w: length %d", ErrInvalidKey, len(s))
+	}
+	defer clear(buf[:])
+	if err != nil || n != Size {
+		return fmt.Errorf("%w: malformed encoding", ErrInvalidKey)
+	}
+	copy(dst, buf[:Size])
+	return nil
+}
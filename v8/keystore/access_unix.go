@@ -0,0 +1,26 @@
+//go:build unix
+
+package keystore
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"syscall"
+)
+
+// checkAccess fails when the file is accessible to other users than its
+// owner, or not owned by the current user.
+func checkAccess(fi fs.FileInfo) error {
+	if perm := fi.Mode().Perm(); perm&0o077 != 0 {
+		return fmt.Errorf("has mode %v", perm)
+	}
+	st, ok := fi.Sys().(*syscall.Stat_t)
+	if !ok {
+		return nil
+	}
+	if euid := os.Geteuid(); int(st.Uid) != euid {
+		return fmt.Errorf("is owned by uid %d instead of %d", st.Uid, euid)
+	}
+	return nil
+}
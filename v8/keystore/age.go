@@ -0,0 +1,89 @@
+package keystore
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+
+	"filippo.io/age"
+	"filippo.io/age/armor"
+	"github.com/NordSecurity/libtelio-go/v8/internal/atomicfile"
+	"github.com/NordSecurity/libtelio-go/v8/keys"
+)
+
+// Passphrase returns the passphrase protecting an EncryptedStore.
+type Passphrase func() (string, error)
+
+// EncryptedStore keeps the key in an armored age file encrypted with a
+// passphrase. The file has the same mode and ownership requirements as with
+// FileStore.
+type EncryptedStore struct {
+	path       string
+	passphrase Passphrase
+}
+
+// NewEncryptedStore creates a store for the file at path.
+func NewEncryptedStore(path string, passphrase Passphrase) *EncryptedStore {
+	return &EncryptedStore{path: path, passphrase: passphrase}
+}
+
+// Load implements KeyStore.
+func (e *EncryptedStore) Load() (keys.SecretKey, error) {
+	data, err := readSecure(e.path)
+	if err != nil {
+		return keys.SecretKey{}, err
+	}
+	pass, err := e.passphrase()
+	if err != nil {
+		return keys.SecretKey{}, fmt.Errorf("getting passphrase: %w", err)
+	}
+	identity, err := age.NewScryptIdentity(pass)
+	if err != nil {
+		return keys.SecretKey{}, err
+	}
+
+	r, err := age.Decrypt(armor.NewReader(bytes.NewReader(data)), identity)
+	if err != nil {
+		return keys.SecretKey{}, fmt.Errorf("decrypting %s: %w", e.path, err)
+	}
+	var key keys.SecretKey
+	n, err := io.ReadFull(r, key[:])
+	if err != nil || n != keys.Size {
+		key.Zero()
+		return keys.SecretKey{}, fmt.Errorf("%s: %w", e.path, keys.ErrInvalidKey)
+	}
+	if extra, _ := r.Read(make([]byte, 1)); extra != 0 {
+		key.Zero()
+		return keys.SecretKey{}, fmt.Errorf("%s: %w: trailing data", e.path, keys.ErrInvalidKey)
+	}
+	return key, nil
+}
+
+// Store implements KeyStore.
+func (e *EncryptedStore) Store(key keys.SecretKey) error {
+	pass, err := e.passphrase()
+	if err != nil {
+		return fmt.Errorf("getting passphrase: %w", err)
+	}
+	recipient, err := age.NewScryptRecipient(pass)
+	if err != nil {
+		return err
+	}
+
+	var b bytes.Buffer
+	a := armor.NewWriter(&b)
+	w, err := age.Encrypt(a, recipient)
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(key[:]); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	if err := a.Close(); err != nil {
+		return err
+	}
+	return atomicfile.WriteFile(e.path, b.Bytes(), 0o600)
+}
@@ -0,0 +1,71 @@
+package keystore
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+
+	"github.com/NordSecurity/libtelio-go/v8/internal/atomicfile"
+	"github.com/NordSecurity/libtelio-go/v8/keys"
+)
+
+// FileStore keeps the key base64 encoded in a file with mode 0600. Loading
+// fails with ErrInsecure when the file is accessible to other users or owned
+// by another user than the process.
+type FileStore struct {
+	path string
+}
+
+// NewFileStore creates a store for the file at path. Its directory must exist.
+func NewFileStore(path string) *FileStore {
+	return &FileStore{path: path}
+}
+
+// Load implements KeyStore.
+func (f *FileStore) Load() (keys.SecretKey, error) {
+	data, err := readSecure(f.path)
+	if err != nil {
+		return keys.SecretKey{}, err
+	}
+	defer clear(data)
+	key, err := keys.ParseSecretKey(string(bytes.TrimSpace(data)))
+	if err != nil {
+		return keys.SecretKey{}, fmt.Errorf("%s: %w", f.path, err)
+	}
+	return key, nil
+}
+
+// Store implements KeyStore.
+func (f *FileStore) Store(key keys.SecretKey) error {
+	data, _ := key.MarshalText()
+	defer clear(data)
+	return atomicfile.WriteFile(f.path, append(data, '\n'), 0o600)
+}
+
+// readSecure reads a file only its owner, the current user, may access.
+func readSecure(path string) ([]byte, error) {
+	file, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	fi, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if err := checkAccess(fi); err != nil {
+		return nil, fmt.Errorf("%w: %s %w", ErrInsecure, path, err)
+	}
+
+	var b bytes.Buffer
+	if _, err := b.ReadFrom(file); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
@@ -0,0 +1,57 @@
+//go:build linux
+
+package keystore
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/NordSecurity/libtelio-go/v8/keys"
+	"golang.org/x/sys/unix"
+)
+
+// KeyringStore keeps the key in the Linux kernel keyring as a "user" key. The
+// kernel keyring does not survive a reboot, so it suits keys which can be
+// regenerated, or caching a key loaded from another store.
+type KeyringStore struct {
+	description string
+	keyring     int
+}
+
+// NewKeyringStore creates a store for the key with the description, e.g.
+// "libtelio:device", in keyring, e.g. unix.KEY_SPEC_USER_KEYRING or
+// unix.KEY_SPEC_SESSION_KEYRING.
+func NewKeyringStore(description string, keyring int) *KeyringStore {
+	return &KeyringStore{description: description, keyring: keyring}
+}
+
+// Load implements KeyStore.
+func (k *KeyringStore) Load() (keys.SecretKey, error) {
+	id, err := unix.KeyctlSearch(k.keyring, "user", k.description, 0)
+	if errors.Is(err, unix.ENOKEY) || errors.Is(err, unix.EKEYEXPIRED) || errors.Is(err, unix.EKEYREVOKED) {
+		return keys.SecretKey{}, ErrNotFound
+	}
+	if err != nil {
+		return keys.SecretKey{}, fmt.Errorf("searching key %q: %w", k.description, err)
+	}
+
+	var key keys.SecretKey
+	n, err := unix.KeyctlBuffer(unix.KEYCTL_READ, id, key[:], 0)
+	if err != nil {
+		return keys.SecretKey{}, fmt.Errorf("reading key %q: %w", k.description, err)
+	}
+	if n != keys.Size {
+		key.Zero()
+		return keys.SecretKey{}, fmt.Errorf("key %q: %w: %d bytes", k.description, keys.ErrInvalidKey, n)
+	}
+	return key, nil
+}
+
+// Store implements KeyStore. An existing key with the same description is
+// updated in place.
+func (k *KeyringStore) Store(key keys.SecretKey) error {
+	if _, err := unix.AddKey("user", k.description, key[:], k.keyring); err != nil {
+		return fmt.Errorf("adding key %q: %w", k.description, err)
+	}
+	return nil
+}
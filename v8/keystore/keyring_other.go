@@ -0,0 +1,29 @@
+//go:build !linux
+
+package keystore
+
+import (
+	"errors"
+
+	"github.com/NordSecurity/libtelio-go/v8/keys"
+)
+
+// KeyringStore keeps the key in the Linux kernel keyring. It is only
+// implemented on Linux.
+type KeyringStore struct{}
+
+// NewKeyringStore creates a store whose methods fail with
+// errors.ErrUnsupported.
+func NewKeyringStore(description string, keyring int) *KeyringStore {
+	return &KeyringStore{}
+}
+
+// Load implements KeyStore.
+func (k *KeyringStore) Load() (keys.SecretKey, error) {
+	return keys.SecretKey{}, errors.ErrUnsupported
+}
+
+// Store implements KeyStore.
+func (k *KeyringStore) Store(key keys.SecretKey) error {
+	return errors.ErrUnsupported
+}
@@ -0,0 +1,132 @@
+// Package keystore persists the device secret key passed to telio.Start and
+// telio.SetSecretKey.
+//
+// Three KeyStore backends are provided: a plain file readable only by its
+// owner, the Linux kernel keyring and a passphrase encrypted age file.
+package keystore
+
+import (
+	"errors"
+	"fmt"
+
+	telio "github.com/NordSecurity/libtelio-go/v8"
+	"github.com/NordSecurity/libtelio-go/v8/keys"
+)
+
+var (
+	// ErrNotFound is returned by Load when no key is stored yet
+	ErrNotFound = errors.New("no key stored")
+	// ErrInsecure is returned when stored key material is accessible to
+	// other users
+	ErrInsecure = errors.New("key store is accessible to other users")
+	// ErrMismatch is returned by Verify when libtelio runs with another key
+	ErrMismatch = errors.New("running key differs from the stored key")
+)
+
+// KeyStore persists a secret key.
+type KeyStore interface {
+	// Load returns the stored key or ErrNotFound
+	Load() (keys.SecretKey, error)
+	// Store replaces the stored key
+	Store(key keys.SecretKey) error
+}
+
+// LoadOrGenerate loads the stored key, generating and storing a new one on
+// first use. generated reports whether the key is new.
+func LoadOrGenerate(ks KeyStore) (key keys.SecretKey, generated bool, err error) {
+	key, err = ks.Load()
+	if err == nil {
+		return key, false, nil
+	}
+	if !errors.Is(err, ErrNotFound) {
+		return keys.SecretKey{}, false, err
+	}
+	if key, err = keys.GenerateSecretKey(); err != nil {
+		return keys.SecretKey{}, false, err
+	}
+	if err := ks.Store(key); err != nil {
+		return keys.SecretKey{}, false, fmt.Errorf("storing new key: %w", err)
+	}
+	return key, true, nil
+}
+
+// Rotation is a key rotation in progress. The new key is only stored by
+// Commit, so the stored key stays the previous one until the caller confirmed
+// that the new key works, even when the process dies in between.
+type Rotation struct {
+	ks KeyStore
+	// The stored key, zero when none was stored yet
+	Previous keys.SecretKey
+	// The generated key
+	Next keys.SecretKey
+}
+
+// Rotate generates a new key to replace the stored one. Nothing is stored
+// until Commit is called; dropping the Rotation aborts it.
+func Rotate(ks KeyStore) (*Rotation, error) {
+	previous, err := ks.Load()
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return nil, err
+	}
+	next, err := keys.GenerateSecretKey()
+	if err != nil {
+		previous.Zero()
+		return nil, err
+	}
+	return &Rotation{ks: ks, Previous: previous, Next: next}, nil
+}
+
+// Commit stores the new key.
+func (r *Rotation) Commit() error {
+	if err := r.ks.Store(r.Next); err != nil {
+		return fmt.Errorf("storing new key: %w", err)
+	}
+	return nil
+}
+
+// Zero overwrites both keys.
+func (r *Rotation) Zero() {
+	r.Previous.Zero()
+	r.Next.Zero()
+}
+
+// Device is the part of telio.TelioInterface using the secret key.
+type Device interface {
+	GetSecretKey() telio.SecretKey
+	SetSecretKey(secretKey telio.SecretKey) error
+}
+
+// Verify checks that dev runs with the stored key.
+func Verify(dev Device, ks KeyStore) error {
+	stored, err := ks.Load()
+	if err != nil {
+		return err
+	}
+	defer stored.Zero()
+	running, err := keys.SecretKeyFromTelio(dev.GetSecretKey())
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrMismatch, err)
+	}
+	defer running.Zero()
+	if !running.Equal(stored) {
+		return ErrMismatch
+	}
+	return nil
+}
+
+// Sync makes dev run with the stored key, returning whether it was changed.
+func Sync(dev Device, ks KeyStore) (bool, error) {
+	err := Verify(dev, ks)
+	if !errors.Is(err, ErrMismatch) {
+		return false, err
+	}
+	stored, err := ks.Load()
+	if err != nil {
+		return false, err
+	}
+	defer stored.Zero()
+	if err := dev.SetSecretKey(stored.Telio()); err != nil {
+		return false, err
+	}
+	return true, nil
+}
@@ -0,0 +1,49 @@
+package keystore
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestRotateStoresOnlyOnCommit(t *testing.T) {
+	ks := NewFileStore(filepath.Join(t.TempDir(), "key"))
+	key, generated, err := LoadOrGenerate(ks)
+	if err != nil || !generated {
+		t.Fatalf("generating key: %v", err)
+	}
+
+	r, err := Rotate(ks)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Zero()
+	if !r.Previous.Equal(key) || r.Next.Equal(key) {
+		t.Fatal("rotation does not replace the stored key")
+	}
+	// A process dying here leaves the previous key in place
+	stored, err := ks.Load()
+	if err != nil || !stored.Equal(key) {
+		t.Fatalf("key stored before commit: %v", err)
+	}
+
+	if err := r.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if stored, err = ks.Load(); err != nil || !stored.Equal(r.Next) {
+		t.Fatalf("new key not stored after commit: %v", err)
+	}
+}
+
+func TestRotateWithoutStoredKey(t *testing.T) {
+	ks := NewFileStore(filepath.Join(t.TempDir(), "key"))
+	r, err := Rotate(ks)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !r.Previous.IsZero() || r.Next.IsZero() {
+		t.Fatal("unexpected keys for a first rotation")
+	}
+	if _, err := ks.Load(); err == nil {
+		t.Fatal("key stored before commit")
+	}
+}
@@ -0,0 +1,331 @@
+// Package killswitch blocks traffic outside the VPN tunnel while an exit node
+// connection is down.
+//
+// Once armed, the kill switch only lets packets leave through the tunnel
+// interface, from sockets carrying the fwmark passed to telio.SetFwmark (the
+// ones libtelio uses to reach VPN and relay servers), and to the allowlisted
+// LAN and relay addresses. The rules stay installed while the exit node
+// reconnects and are only removed by Disarm, so call it when the user
+// disconnects on purpose.
+//
+// The rules live in their own nftables table, which is replaced atomically on
+// every change. A table left behind by a crashed process keeps blocking until
+// Disarm is called, failing closed.
+package killswitch
+
+import (
+	"context"
+	"fmt"
+	"net/netip"
+	"sync"
+
+	telio "github.com/NordSecurity/libtelio-go/v8"
+)
+
+const (
+	defaultTable   = "telio-killswitch"
+	eventQueueSize = 256
+)
+
+// PrivateNetworks are the destinations usually allowed with SetLAN to keep
+// the local network reachable.
+var PrivateNetworks = []telio.IpNet{
+	"10.0.0.0/8",
+	"172.16.0.0/12",
+	"192.168.0.0/16",
+	"169.254.0.0/16",
+	"224.0.0.0/4",
+	"255.255.255.255/32",
+	"fc00::/7",
+	"fe80::/10",
+	"ff00::/8",
+}
+
+// Config describes the traffic allowed by a KillSwitch.
+type Config struct {
+	// Name of the tunnel interface
+	Interface string
+	// Value passed to telio.SetFwmark
+	Fwmark uint32
+	// Destinations reachable outside the tunnel, see PrivateNetworks
+	LAN []telio.IpNet
+	// Allow the IPv4 addresses of the relay servers reported in EventRelay
+	AllowRelays bool
+	// Allow DHCP and DHCPv6 so the physical interface keeps its address
+	AllowDHCP bool
+	// Name of the nftables table [default "telio-killswitch"]
+	Table string
+	// Path of the network namespace to configure, e.g. /var/run/netns/test,
+	// the namespace of the calling process when empty
+	Netns string
+}
+
+// State is the state of a KillSwitch.
+type State int
+
+const (
+	// No rules are installed
+	StateOff State = iota
+	// Rules are installed and the exit node is connected
+	StateProtected
+	// Rules are installed and the exit node is not connected, only
+	// allowlisted traffic leaves the host
+	StateBlocking
+)
+
+func (s State) String() string {
+	switch s {
+	case StateOff:
+		return "off"
+	case StateProtected:
+		return "protected"
+	case StateBlocking:
+		return "blocking"
+	}
+	return fmt.Sprintf("State(%d)", int(s))
+}
+
+// Options configure a KillSwitch.
+type Options struct {
+	// Arm when an exit node connects
+	AutoArm bool
+	// Called when the state changes, it must not call into the KillSwitch
+	OnStateChange func(State)
+	// Called when updating the rules from Run fails
+	OnError func(error)
+}
+
+// ruleset is what a firewall installs.
+type ruleset struct {
+	table     string
+	iface     string
+	fwmark    uint32
+	allow     []netip.Prefix
+	allowDHCP bool
+}
+
+// firewall installs the rules on the host.
+type firewall interface {
+	install(rules ruleset) error
+	remove(table string) error
+}
+
+// KillSwitch follows the exit node state and keeps the blocking rules
+// installed while armed. Feed it with events through Observe and process them
+// with Run.
+type KillSwitch struct {
+	fw     firewall
+	opts   Options
+	events chan telio.Event
+
+	mu        sync.Mutex
+	cfg       Config
+	lan       []netip.Prefix
+	relays    map[telio.PublicKey]netip.Addr
+	armed     bool
+	connected bool
+	state     State
+}
+
+// New creates a kill switch for cfg. Nothing is changed until it is armed.
+func New(cfg Config, opts Options) (*KillSwitch, error) {
+	if cfg.Interface == "" {
+		return nil, fmt.Errorf("interface is required")
+	}
+	if cfg.Table == "" {
+		cfg.Table = defaultTable
+	}
+	lan, err := parsePrefixes(cfg.LAN)
+	if err != nil {
+		return nil, err
+	}
+	fw, err := newFirewall(cfg.Netns)
+	if err != nil {
+		return nil, err
+	}
+	return &KillSwitch{
+		fw:     fw,
+		opts:   opts,
+		events: make(chan telio.Event, eventQueueSize),
+		cfg:    cfg,
+		lan:    lan,
+		relays: make(map[telio.PublicKey]netip.Addr),
+	}, nil
+}
+
+// Observe forwards exit node and relay events to the kill switch. It never
+// blocks.
+func (k *KillSwitch) Observe(event telio.Event) {
+	switch e := event.(type) {
+	case telio.EventNode:
+		if !(e.Body.IsExit || e.Body.IsVpn) {
+			return
+		}
+	case telio.EventRelay:
+	default:
+		return
+	}
+	select {
+	case k.events <- event:
+	default:
+	}
+}
+
+// Run applies the observed events until ctx is done. The rules are left in
+// place when it returns.
+func (k *KillSwitch) Run(ctx context.Context) error {
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case event := <-k.events:
+			if err := k.handle(event); err != nil && k.opts.OnError != nil {
+				k.opts.OnError(err)
+			}
+		}
+	}
+}
+
+// State returns the current state.
+func (k *KillSwitch) State() State {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	return k.state
+}
+
+// Arm installs the rules. Arming an armed kill switch reinstalls them.
+func (k *KillSwitch) Arm() error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if err := k.install(); err != nil {
+		return err
+	}
+	k.armed = true
+	k.updateState()
+	return nil
+}
+
+// Disarm removes the rules, including ones left behind by a previous process
+// using the same table.
+func (k *KillSwitch) Disarm() error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if err := k.fw.remove(k.cfg.Table); err != nil {
+		return fmt.Errorf("removing kill switch rules: %w", err)
+	}
+	k.armed = false
+	k.updateState()
+	return nil
+}
+
+// SetLAN replaces the destinations reachable outside the tunnel.
+func (k *KillSwitch) SetLAN(lan []telio.IpNet) error {
+	prefixes, err := parsePrefixes(lan)
+	if err != nil {
+		return err
+	}
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.cfg.LAN, k.lan = lan, prefixes
+	return k.reinstall()
+}
+
+// SetRelays replaces the allowlisted relay servers, e.g. with the ones of
+// the meshnet config. Later relay events update the list again when
+// Config.AllowRelays is set.
+func (k *KillSwitch) SetRelays(servers []telio.Server) error {
+	relays := make(map[telio.PublicKey]netip.Addr, len(servers))
+	for _, s := range servers {
+		addr, err := netip.ParseAddr(s.Ipv4)
+		if err != nil {
+			return fmt.Errorf("relay %s: %w", s.Hostname, err)
+		}
+		relays[s.PublicKey] = addr
+	}
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.relays = relays
+	return k.reinstall()
+}
+
+func (k *KillSwitch) handle(event telio.Event) error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	switch e := event.(type) {
+	case telio.EventNode:
+		k.connected = e.Body.State == telio.NodeStateConnected
+		if k.opts.AutoArm && k.connected && !k.armed {
+			if err := k.install(); err != nil {
+				return err
+			}
+			k.armed = true
+		}
+		k.updateState()
+	case telio.EventRelay:
+		if !k.cfg.AllowRelays {
+			return nil
+		}
+		addr, err := netip.ParseAddr(e.Body.Ipv4)
+		if err != nil || k.relays[e.Body.PublicKey] == addr {
+			return nil
+		}
+		k.relays[e.Body.PublicKey] = addr
+		return k.reinstall()
+	}
+	return nil
+}
+
+func (k *KillSwitch) reinstall() error {
+	if !k.armed {
+		return nil
+	}
+	return k.install()
+}
+
+func (k *KillSwitch) install() error {
+	allow := append([]netip.Prefix(nil), k.lan...)
+	for _, addr := range k.relays {
+		allow = append(allow, netip.PrefixFrom(addr, addr.BitLen()))
+	}
+	err := k.fw.install(ruleset{
+		table:     k.cfg.Table,
+		iface:     k.cfg.Interface,
+		fwmark:    k.cfg.Fwmark,
+		allow:     allow,
+		allowDHCP: k.cfg.AllowDHCP,
+	})
+	if err != nil {
+		return fmt.Errorf("installing kill switch rules: %w", err)
+	}
+	return nil
+}
+
+func (k *KillSwitch) updateState() {
+	state := StateOff
+	switch {
+	case k.armed && k.connected:
+		state = StateProtected
+	case k.armed:
+		state = StateBlocking
+	}
+	if state == k.state {
+		return
+	}
+	k.state = state
+	if k.opts.OnStateChange != nil {
+		k.opts.OnStateChange(state)
+	}
+}
+
+func parsePrefixes(nets []telio.IpNet) ([]netip.Prefix, error) {
+	out := make([]netip.Prefix, 0, len(nets))
+	for _, s := range nets {
+		p, err := netip.ParsePrefix(s)
+		if err != nil {
+			return nil, fmt.Errorf("allowlisted network %q: %w", s, err)
+		}
+		out = append(out, p.Masked())
+	}
+	return out, nil
+}
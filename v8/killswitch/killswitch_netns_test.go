@@ -0,0 +1,224 @@
+//go:build linux && netns
+
+// Run as root with: go test -tags netns ./killswitch
+
+package killswitch
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"os"
+	"runtime"
+	"testing"
+
+	telio "github.com/NordSecurity/libtelio-go/v8"
+	"github.com/vishvananda/netlink"
+	"github.com/vishvananda/netns"
+	"golang.org/x/sys/unix"
+)
+
+const (
+	testInterface = "telio-test"
+	testFwmark    = 11673110
+)
+
+// testNetns creates a network namespace with a tunnel interface on
+// 10.5.0.1/24 and a physical one on 192.0.2.1/24 holding the default route,
+// and returns its path and handle.
+func testNetns(t *testing.T) (string, netns.NsHandle) {
+	t.Helper()
+	if os.Geteuid() != 0 {
+		t.Skip("creating a network namespace requires root")
+	}
+
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	orig, err := netns.Get()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer orig.Close()
+
+	name := fmt.Sprintf("telio-killswitch-%d", os.Getpid())
+	ns, err := netns.NewNamed(name)
+	if err != nil {
+		t.Skipf("creating a network namespace: %v", err)
+	}
+	if err := netns.Set(orig); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		ns.Close()
+		netns.DeleteNamed(name)
+	})
+
+	h, err := netlink.NewHandleAt(ns)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer h.Close()
+	lo, err := h.LinkByName("lo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := h.LinkSetUp(lo); err != nil {
+		t.Fatal(err)
+	}
+	for name, addr := range map[string]string{testInterface: "10.5.0.1/24", "wan0": "192.0.2.1/24"} {
+		veth := &netlink.Veth{LinkAttrs: netlink.LinkAttrs{Name: name}, PeerName: name + "-peer"}
+		if err := h.LinkAdd(veth); err != nil {
+			t.Fatal(err)
+		}
+		for _, n := range []string{name, veth.PeerName} {
+			l, err := h.LinkByName(n)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := h.LinkSetUp(l); err != nil {
+				t.Fatal(err)
+			}
+		}
+		ip, err := netlink.ParseAddr(addr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := h.AddrAdd(veth, ip); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := h.RouteAdd(&netlink.Route{Dst: nil, Gw: net.ParseIP("192.0.2.254")}); err != nil {
+		t.Fatal(err)
+	}
+	return "/var/run/netns/" + name, ns
+}
+
+// send sends a UDP datagram to addr from a socket created in ns, with mark
+// set as SO_MARK when not zero.
+func send(t *testing.T, ns netns.NsHandle, addr string, mark int) error {
+	t.Helper()
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	orig, err := netns.Get()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer orig.Close()
+	if err := netns.Set(ns); err != nil {
+		t.Fatal(err)
+	}
+	fd, err := unix.Socket(unix.AF_INET, unix.SOCK_DGRAM, 0)
+	if err := netns.Set(orig); err != nil {
+		t.Fatal(err)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer unix.Close(fd)
+
+	if mark != 0 {
+		if err := unix.SetsockoptInt(fd, unix.SOL_SOCKET, unix.SO_MARK, mark); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ip := netip.MustParseAddr(addr).As4()
+	return unix.Sendto(fd, []byte("ping"), 0, &unix.SockaddrInet4{Port: 53, Addr: ip})
+}
+
+// reachable reports whether the kill switch lets a datagram to addr leave.
+func reachable(t *testing.T, ns netns.NsHandle, addr string, mark int) bool {
+	t.Helper()
+	err := send(t, ns, addr, mark)
+	if err != nil && !errors.Is(err, unix.EPERM) {
+		t.Fatalf("sending to %s: %v", addr, err)
+	}
+	return err == nil
+}
+
+func newTestKillSwitch(t *testing.T, path string) *KillSwitch {
+	t.Helper()
+	k, err := New(Config{
+		Interface: testInterface,
+		Fwmark:    testFwmark,
+		LAN:       []telio.IpNet{"192.0.2.0/24"},
+		Table:     fmt.Sprintf("telio-test-%d", os.Getpid()),
+		Netns:     path,
+	}, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return k
+}
+
+func TestKillSwitchNetns(t *testing.T) {
+	path, ns := testNetns(t)
+	k := newTestKillSwitch(t, path)
+
+	if !reachable(t, ns, "198.51.100.1", 0) {
+		t.Fatal("traffic blocked before arming")
+	}
+	if err := k.Arm(); err != nil {
+		t.Skipf("installing nftables rules: %v", err)
+	}
+	defer k.Disarm()
+
+	for _, tt := range []struct {
+		name string
+		addr string
+		mark int
+		want bool
+	}{
+		{"internet", "198.51.100.1", 0, false},
+		{"tunnel", "10.5.0.9", 0, true},
+		{"LAN", "192.0.2.9", 0, true},
+		{"fwmark", "198.51.100.1", testFwmark, true},
+		{"other mark", "198.51.100.1", testFwmark + 1, false},
+		{"loopback", "127.0.0.1", 0, true},
+	} {
+		if got := reachable(t, ns, tt.addr, tt.mark); got != tt.want {
+			t.Errorf("%s: reachable %v, want %v", tt.name, got, tt.want)
+		}
+	}
+	if k.State() != StateBlocking {
+		t.Errorf("state %v, want blocking", k.State())
+	}
+
+	if err := k.SetRelays([]telio.Server{{Ipv4: "198.51.100.7"}}); err != nil {
+		t.Fatal(err)
+	}
+	if !reachable(t, ns, "198.51.100.7", 0) || reachable(t, ns, "198.51.100.1", 0) {
+		t.Error("relay allowlist not applied")
+	}
+
+	if err := k.Disarm(); err != nil {
+		t.Fatal(err)
+	}
+	if !reachable(t, ns, "198.51.100.1", 0) {
+		t.Fatal("traffic blocked after disarming")
+	}
+}
+
+func TestKillSwitchNetnsDisarmLeftovers(t *testing.T) {
+	path, ns := testNetns(t)
+	crashed := newTestKillSwitch(t, path)
+	if err := crashed.Arm(); err != nil {
+		t.Skipf("installing nftables rules: %v", err)
+	}
+
+	// A new process keeps blocking until it disarms
+	k := newTestKillSwitch(t, path)
+	if reachable(t, ns, "198.51.100.1", 0) {
+		t.Fatal("rules left behind are not blocking")
+	}
+	if err := k.Disarm(); err != nil {
+		t.Fatal(err)
+	}
+	if !reachable(t, ns, "198.51.100.1", 0) {
+		t.Fatal("rules left behind after disarming")
+	}
+	// Disarming without rules is not an error
+	if err := k.Disarm(); err != nil {
+		t.Fatal(err)
+	}
+}
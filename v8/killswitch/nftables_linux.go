@@ -0,0 +1,152 @@
+//go:build linux
+
+package killswitch
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net/netip"
+
+	"github.com/google/nftables"
+	"github.com/google/nftables/binaryutil"
+	"github.com/google/nftables/expr"
+	"github.com/vishvananda/netns"
+	"golang.org/x/sys/unix"
+)
+
+// nftFirewall installs the rules as an inet nftables table with a single
+// output chain.
+type nftFirewall struct {
+	netns string
+}
+
+func newFirewall(netns string) (firewall, error) {
+	return &nftFirewall{netns: netns}, nil
+}
+
+func (f *nftFirewall) conn() (*nftables.Conn, func(), error) {
+	if f.netns == "" {
+		c, err := nftables.New()
+		return c, func() {}, err
+	}
+	ns, err := netns.GetFromPath(f.netns)
+	if err != nil {
+		return nil, nil, fmt.Errorf("opening network namespace %s: %w", f.netns, err)
+	}
+	c, err := nftables.New(nftables.WithNetNSFd(int(ns)))
+	if err != nil {
+		ns.Close()
+		return nil, nil, err
+	}
+	return c, func() { ns.Close() }, nil
+}
+
+// install replaces the table in a single transaction, so traffic is never
+// let through while the rules are updated.
+func (f *nftFirewall) install(rs ruleset) error {
+	c, done, err := f.conn()
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	table := &nftables.Table{Family: nftables.TableFamilyINet, Name: rs.table}
+	// Adding an existing table is a no-op, which makes deleting it safe
+	c.AddTable(table)
+	c.DelTable(table)
+	c.AddTable(table)
+
+	policy := nftables.ChainPolicyDrop
+	chain := c.AddChain(&nftables.Chain{
+		Name:     "output",
+		Table:    table,
+		Type:     nftables.ChainTypeFilter,
+		Hooknum:  nftables.ChainHookOutput,
+		Priority: nftables.ChainPriorityFilter,
+		Policy:   &policy,
+	})
+	add := func(exprs ...expr.Any) {
+		exprs = append(exprs, &expr.Verdict{Kind: expr.VerdictAccept})
+		c.AddRule(&nftables.Rule{Table: table, Chain: chain, Exprs: exprs})
+	}
+
+	add(matchOifname("lo")...)
+	add(matchOifname(rs.iface)...)
+	if rs.fwmark != 0 {
+		add(
+			&expr.Meta{Key: expr.MetaKeyMARK, Register: 1},
+			&expr.Cmp{Op: expr.CmpOpEq, Register: 1, Data: binaryutil.NativeEndian.PutUint32(rs.fwmark)},
+		)
+	}
+	for _, p := range rs.allow {
+		add(matchDaddr(p)...)
+	}
+	if rs.allowDHCP {
+		add(matchUDP(unix.NFPROTO_IPV4, 68, 67)...)
+		add(matchUDP(unix.NFPROTO_IPV6, 546, 547)...)
+	}
+	// Fail fast instead of letting connections time out
+	c.AddRule(&nftables.Rule{Table: table, Chain: chain, Exprs: []expr.Any{
+		&expr.Reject{Type: unix.NFT_REJECT_ICMPX_UNREACH, Code: unix.NFT_REJECT_ICMPX_ADMIN_PROHIBITED},
+	}})
+
+	return c.Flush()
+}
+
+func (f *nftFirewall) remove(name string) error {
+	c, done, err := f.conn()
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	table := &nftables.Table{Family: nftables.TableFamilyINet, Name: name}
+	c.AddTable(table)
+	c.DelTable(table)
+	return c.Flush()
+}
+
+func matchOifname(name string) []expr.Any {
+	data := make([]byte, unix.IFNAMSIZ)
+	copy(data, name)
+	return []expr.Any{
+		&expr.Meta{Key: expr.MetaKeyOIFNAME, Register: 1},
+		&expr.Cmp{Op: expr.CmpOpEq, Register: 1, Data: data},
+	}
+}
+
+func matchNfproto(proto byte) []expr.Any {
+	return []expr.Any{
+		&expr.Meta{Key: expr.MetaKeyNFPROTO, Register: 1},
+		&expr.Cmp{Op: expr.CmpOpEq, Register: 1, Data: []byte{proto}},
+	}
+}
+
+func matchDaddr(p netip.Prefix) []expr.Any {
+	proto, offset := byte(unix.NFPROTO_IPV6), uint32(24)
+	if p.Addr().Is4() {
+		proto, offset = unix.NFPROTO_IPV4, 16
+	}
+	addr := p.Addr().AsSlice()
+	mask := make([]byte, len(addr))
+	for i := 0; i < p.Bits(); i++ {
+		mask[i/8] |= 0x80 >> (i % 8)
+	}
+	return append(matchNfproto(proto),
+		&expr.Payload{DestRegister: 1, Base: expr.PayloadBaseNetworkHeader, Offset: offset, Len: uint32(len(addr))},
+		&expr.Bitwise{SourceRegister: 1, DestRegister: 1, Len: uint32(len(addr)), Mask: mask, Xor: make([]byte, len(addr))},
+		&expr.Cmp{Op: expr.CmpOpEq, Register: 1, Data: addr},
+	)
+}
+
+func matchUDP(proto byte, sport, dport uint16) []expr.Any {
+	ports := make([]byte, 4)
+	binary.BigEndian.PutUint16(ports[:2], sport)
+	binary.BigEndian.PutUint16(ports[2:], dport)
+	return append(matchNfproto(proto),
+		&expr.Meta{Key: expr.MetaKeyL4PROTO, Register: 1},
+		&expr.Cmp{Op: expr.CmpOpEq, Register: 1, Data: []byte{unix.IPPROTO_UDP}},
+		&expr.Payload{DestRegister: 1, Base: expr.PayloadBaseTransportHeader, Offset: 0, Len: 4},
+		&expr.Cmp{Op: expr.CmpOpEq, Register: 1, Data: ports},
+	)
+}
@@ -0,0 +1,19 @@
+//go:build !linux
+
+package killswitch
+
+import "errors"
+
+type unsupportedFirewall struct{}
+
+func newFirewall(netns string) (firewall, error) {
+	return unsupportedFirewall{}, nil
+}
+
+func (unsupportedFirewall) install(ruleset) error {
+	return errors.ErrUnsupported
+}
+
+func (unsupportedFirewall) remove(string) error {
+	return errors.ErrUnsupported
+}
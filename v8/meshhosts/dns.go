@@ -0,0 +1,176 @@
+package meshhosts
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+const (
+	defaultTTL     = 60
+	maxMessageSize = 512
+
+	// Bounds of the pause after a failed read
+	minReadBackoff = 5 * time.Millisecond
+	maxReadBackoff = time.Second
+)
+
+// Responder is a Sink answering A and AAAA queries for the records over UDP.
+// Names under one of its zones which are not records get NXDOMAIN, other
+// queries are refused.
+type Responder struct {
+	zones []string
+	ttl   uint32
+
+	mu    sync.RWMutex
+	names map[string]Record
+}
+
+// NewResponder creates a responder authoritative for zones, e.g. "nord".
+func NewResponder(zones ...string) *Responder {
+	r := &Responder{ttl: defaultTTL, names: make(map[string]Record)}
+	for _, z := range zones {
+		r.zones = append(r.zones, normalize(z))
+	}
+	return r
+}
+
+// Update implements Sink.
+func (r *Responder) Update(records []Record) error {
+	names := make(map[string]Record, len(records))
+	for _, rec := range records {
+		names[rec.Name] = rec
+	}
+	r.mu.Lock()
+	r.names = names
+	r.mu.Unlock()
+	return nil
+}
+
+// ListenAndServe serves queries on the UDP address addr until ctx is done.
+func (r *Responder) ListenAndServe(ctx context.Context, addr string) error {
+	var lc net.ListenConfig
+	conn, err := lc.ListenPacket(ctx, "udp", addr)
+	if err != nil {
+		return err
+	}
+	return r.Serve(ctx, conn)
+}
+
+// Serve serves queries received on conn until ctx is done, then closes conn.
+// Failed reads are retried after a pause growing up to a second.
+func (r *Responder) Serve(ctx context.Context, conn net.PacketConn) error {
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+	defer conn.Close()
+
+	buf := make([]byte, 65535)
+	backoff := time.Duration(0)
+	for {
+		n, from, err := conn.ReadFrom(buf)
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			if errors.Is(err, net.ErrClosed) {
+				return err
+			}
+			backoff = min(max(2*backoff, minReadBackoff), maxReadBackoff)
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(backoff):
+			}
+			continue
+		}
+		backoff = 0
+		if resp := r.answer(buf[:n]); resp != nil {
+			_, _ = conn.WriteTo(resp, from)
+		}
+	}
+}
+
+// answer builds the response to a query, nil when it should be ignored.
+func (r *Responder) answer(query []byte) []byte {
+	var p dnsmessage.Parser
+	hdr, err := p.Start(query)
+	if err != nil || hdr.Response {
+		return nil
+	}
+	q, err := p.Question()
+	if err != nil {
+		return nil
+	}
+
+	resp := dnsmessage.Header{ID: hdr.ID, Response: true, OpCode: hdr.OpCode, RecursionDesired: hdr.RecursionDesired}
+	name := normalize(q.Name.String())
+	// Records outside the zones are not served either
+	zone := r.inZone(name)
+	var rec Record
+	var found bool
+	if zone {
+		rec, found = r.lookup(name)
+	}
+	switch {
+	case hdr.OpCode != 0 || q.Class != dnsmessage.ClassINET:
+		resp.RCode = dnsmessage.RCodeNotImplemented
+	case !zone:
+		resp.RCode = dnsmessage.RCodeRefused
+	case !found:
+		resp.Authoritative = true
+		resp.RCode = dnsmessage.RCodeNameError
+	default:
+		resp.Authoritative = true
+	}
+
+	b := dnsmessage.NewBuilder(make([]byte, 0, maxMessageSize), resp)
+	b.EnableCompression()
+	if err := b.StartQuestions(); err != nil {
+		return nil
+	}
+	if err := b.Question(q); err != nil {
+		return nil
+	}
+	if err := b.StartAnswers(); err != nil {
+		return nil
+	}
+	if resp.RCode == dnsmessage.RCodeSuccess {
+		rh := dnsmessage.ResourceHeader{Name: q.Name, Class: dnsmessage.ClassINET, TTL: r.ttl}
+		for _, addr := range rec.Addrs {
+			switch {
+			case addr.Is4() && q.Type == dnsmessage.TypeA:
+				err = b.AResource(rh, dnsmessage.AResource{A: addr.As4()})
+			case addr.Is6() && q.Type == dnsmessage.TypeAAAA:
+				err = b.AAAAResource(rh, dnsmessage.AAAAResource{AAAA: addr.As16()})
+			}
+			if err != nil {
+				return nil
+			}
+		}
+	}
+	out, err := b.Finish()
+	if err != nil || len(out) > maxMessageSize {
+		return nil
+	}
+	return out
+}
+
+func (r *Responder) lookup(name string) (Record, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	rec, ok := r.names[name]
+	return rec, ok
+}
+
+func (r *Responder) inZone(name string) bool {
+	for _, z := range r.zones {
+		if name == z {
+			return true
+		}
+	}
+	return inZone(name, r.zones)
+}
@@ -0,0 +1,97 @@
+package meshhosts
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/netip"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+func query(t *testing.T, name string) []byte {
+	t.Helper()
+	b := dnsmessage.NewBuilder(nil, dnsmessage.Header{ID: 1, RecursionDesired: true})
+	if err := b.StartQuestions(); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Question(dnsmessage.Question{Name: dnsmessage.MustNewName(name), Type: dnsmessage.TypeA, Class: dnsmessage.ClassINET}); err != nil {
+		t.Fatal(err)
+	}
+	q, err := b.Finish()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return q
+}
+
+func TestResponderZones(t *testing.T) {
+	r := NewResponder("nord")
+	addr := []netip.Addr{netip.MustParseAddr("100.64.0.5")}
+	// Update takes records as they are, also ones outside the zones
+	if err := r.Update([]Record{{Name: "peer.nord", Addrs: addr}, {Name: "bank.example.com", Addrs: addr}}); err != nil {
+		t.Fatal(err)
+	}
+	for name, want := range map[string]dnsmessage.RCode{
+		"peer.nord.":        dnsmessage.RCodeSuccess,
+		"other.nord.":       dnsmessage.RCodeNameError,
+		"bank.example.com.": dnsmessage.RCodeRefused,
+		"www.example.org.":  dnsmessage.RCodeRefused,
+	} {
+		var p dnsmessage.Parser
+		hdr, err := p.Start(r.answer(query(t, name)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if hdr.RCode != want {
+			t.Errorf("%s: %v, want %v", name, hdr.RCode, want)
+			continue
+		}
+		if err := p.SkipAllQuestions(); err != nil {
+			t.Fatal(err)
+		}
+		answers, err := p.AllAnswers()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if (want == dnsmessage.RCodeSuccess) != (len(answers) == 1) {
+			t.Errorf("%s: %d answers", name, len(answers))
+		}
+	}
+}
+
+// failingConn fails every read with a temporary error.
+type failingConn struct {
+	net.PacketConn
+	reads  atomic.Int32
+	closed atomic.Bool
+}
+
+func (c *failingConn) ReadFrom([]byte) (int, net.Addr, error) {
+	c.reads.Add(1)
+	if c.closed.Load() {
+		return 0, nil, net.ErrClosed
+	}
+	return 0, nil, errors.New("no buffer space available")
+}
+
+func (c *failingConn) Close() error {
+	c.closed.Store(true)
+	return nil
+}
+
+func TestServeBacksOffOnReadErrors(t *testing.T) {
+	conn := &failingConn{}
+	ctx, cancel := context.WithTimeout(context.Background(), 300*time.Millisecond)
+	defer cancel()
+	if err := NewResponder("nord").Serve(ctx, conn); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("got %v", err)
+	}
+	// 5, 10, 20, 40, 80 and 160ms pauses fit in 300ms
+	if n := conn.reads.Load(); n > 10 {
+		t.Fatalf("%d reads in 300ms", n)
+	}
+}
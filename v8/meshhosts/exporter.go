@@ -0,0 +1,145 @@
+package meshhosts
+
+import (
+	"context"
+	"sync"
+
+	telio "github.com/NordSecurity/libtelio-go/v8"
+)
+
+const eventQueueSize = 256
+
+// Sink publishes records.
+type Sink interface {
+	Update(records []Record) error
+}
+
+// StatusMap is the part of telio.TelioInterface used by an Exporter.
+type StatusMap interface {
+	GetStatusMap() []telio.TelioNode
+}
+
+// Options configure an Exporter.
+type Options struct {
+	BuildOptions
+	// Used to load the nodes known before the exporter started, optional
+	Status StatusMap
+	// Called when updating a sink fails
+	OnError func(error)
+}
+
+// Exporter keeps sinks in sync with the meshnet. Pass it every config given
+// to telio.SetMeshnet through SetConfig and feed it with events through
+// Observe; Run updates the sinks whenever the records change.
+type Exporter struct {
+	sinks  []Sink
+	opts   Options
+	events chan telio.TelioNode
+	notify chan struct{}
+
+	mu      sync.Mutex
+	cfg     *telio.Config
+	nodes   map[telio.PublicKey]telio.TelioNode
+	records []Record
+}
+
+// NewExporter creates an exporter publishing to sinks.
+func NewExporter(opts Options, sinks ...Sink) *Exporter {
+	return &Exporter{
+		sinks:  sinks,
+		opts:   opts,
+		events: make(chan telio.TelioNode, eventQueueSize),
+		notify: make(chan struct{}, 1),
+		nodes:  make(map[telio.PublicKey]telio.TelioNode),
+	}
+}
+
+// SetConfig replaces the meshnet config, nil when meshnet is off.
+func (e *Exporter) SetConfig(cfg *telio.Config) {
+	e.mu.Lock()
+	e.cfg = cfg
+	if cfg == nil {
+		e.nodes = make(map[telio.PublicKey]telio.TelioNode)
+	}
+	e.mu.Unlock()
+	select {
+	case e.notify <- struct{}{}:
+	default:
+	}
+}
+
+// Observe forwards node events to the exporter. It never blocks.
+func (e *Exporter) Observe(event telio.Event) {
+	n, ok := event.(telio.EventNode)
+	if !ok || n.Body.IsVpn {
+		return
+	}
+	select {
+	case e.events <- n.Body:
+	default:
+	}
+}
+
+// Records returns the records last published.
+func (e *Exporter) Records() []Record {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.records
+}
+
+// Run updates the sinks until ctx is done. The sinks are emptied when it
+// returns.
+func (e *Exporter) Run(ctx context.Context) error {
+	if e.opts.Status != nil {
+		e.mu.Lock()
+		for _, n := range e.opts.Status.GetStatusMap() {
+			e.nodes[n.PublicKey] = n
+		}
+		e.mu.Unlock()
+	}
+	e.sync(true)
+	defer e.publish(nil)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case n := <-e.events:
+			e.mu.Lock()
+			e.nodes[n.PublicKey] = n
+			e.mu.Unlock()
+			e.sync(false)
+		case <-e.notify:
+			e.sync(false)
+		}
+	}
+}
+
+func (e *Exporter) sync(force bool) {
+	e.mu.Lock()
+	var records []Record
+	if e.cfg != nil {
+		nodes := make([]telio.TelioNode, 0, len(e.nodes))
+		for _, n := range e.nodes {
+			nodes = append(nodes, n)
+		}
+		records = Build(e.cfg, nodes, e.opts.BuildOptions)
+	}
+	changed := !equal(records, e.records)
+	e.mu.Unlock()
+
+	if changed || force {
+		e.publish(records)
+	}
+}
+
+func (e *Exporter) publish(records []Record) {
+	e.mu.Lock()
+	e.records = records
+	e.mu.Unlock()
+	for _, s := range e.sinks {
+		if err := s.Update(records); err != nil && e.opts.OnError != nil {
+			e.opts.OnError(err)
+		}
+	}
+}
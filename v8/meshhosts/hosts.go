@@ -0,0 +1,107 @@
+package meshhosts
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"sync"
+	"syscall"
+
+	"github.com/NordSecurity/libtelio-go/v8/internal/atomicfile"
+)
+
+const (
+	// DefaultHostsPath is the system hosts file
+	DefaultHostsPath = "/etc/hosts"
+
+	blockBegin = "# BEGIN libtelio meshnet"
+	blockEnd   = "# END libtelio meshnet"
+)
+
+// HostsFile is a Sink writing the records to a block of a hosts file. The
+// rest of the file is left untouched.
+type HostsFile struct {
+	path string
+	mu   sync.Mutex
+}
+
+// NewHostsFile creates a sink managing the hosts file at path.
+func NewHostsFile(path string) *HostsFile {
+	return &HostsFile{path: path}
+}
+
+// Update implements Sink. The block is removed when records is empty.
+func (h *HostsFile) Update(records []Record) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	data, err := os.ReadFile(h.path)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	mode := fs.FileMode(0o644)
+	if fi, err := os.Stat(h.path); err == nil {
+		mode = fi.Mode().Perm()
+	}
+
+	out := stripBlock(data)
+	if len(records) > 0 {
+		if len(out) > 0 && out[len(out)-1] != '\n' {
+			out = append(out, '\n')
+		}
+		out = append(out, formatBlock(records)...)
+	}
+	if bytes.Equal(out, data) {
+		return nil
+	}
+	err = atomicfile.WriteFile(h.path, out, mode)
+	if errors.Is(err, syscall.EBUSY) {
+		// Bind mounted, e.g. in containers, it can only be written in place
+		err = os.WriteFile(h.path, out, mode)
+	}
+	if err != nil {
+		return fmt.Errorf("writing %s: %w", h.path, err)
+	}
+	return nil
+}
+
+// Remove removes the block from the file, e.g. after a crash.
+func (h *HostsFile) Remove() error {
+	return h.Update(nil)
+}
+
+func stripBlock(data []byte) []byte {
+	var out []byte
+	inside := false
+	for _, line := range bytes.SplitAfter(data, []byte("\n")) {
+		trimmed := string(bytes.TrimSpace(line))
+		switch {
+		case trimmed == blockBegin:
+			inside = true
+		case trimmed == blockEnd && inside:
+			inside = false
+		case !inside:
+			out = append(out, line...)
+		}
+	}
+	return out
+}
+
+func formatBlock(records []Record) []byte {
+	var b bytes.Buffer
+	b.WriteString(blockBegin + "\n")
+	for _, r := range records {
+		// Records may come from callers other than Build, never let a
+		// name break out of its line
+		if !validName(r.Name) {
+			continue
+		}
+		for _, addr := range r.Addrs {
+			fmt.Fprintf(&b, "%s\t%s\n", addr, r.Name)
+		}
+	}
+	b.WriteString(blockEnd + "\n")
+	return b.Bytes()
+}
@@ -0,0 +1,213 @@
+// Package meshhosts exports the names of meshnet peers to applications which
+// do not use magic DNS, through a managed block in a hosts file or a small
+// DNS responder.
+package meshhosts
+
+import (
+	"net/netip"
+	"sort"
+	"strings"
+
+	telio "github.com/NordSecurity/libtelio-go/v8"
+	"golang.org/x/net/idna"
+)
+
+const (
+	maxNameLength  = 253
+	maxLabelLength = 63
+)
+
+// Record maps a name to the addresses of a peer.
+type Record struct {
+	// Lower case ASCII name without trailing dot
+	Name  string
+	Addrs []netip.Addr
+}
+
+// BuildOptions control how records are derived. Only names below
+// HostnameDomain or NicknameDomain are exported, so a peer cannot take over a
+// name outside meshnet, e.g. by calling itself "bank.example.com".
+type BuildOptions struct {
+	// Domain of the peer hostnames given by the meshnet map [default "nord"]
+	HostnameDomain string
+	// Domain appended to nicknames, which are single labels [default "nord"]
+	NicknameDomain string
+	// Leave out the local peer, described by Config.This
+	SkipSelf bool
+}
+
+func (o *BuildOptions) setDefaults() {
+	if o.HostnameDomain == "" {
+		o.HostnameDomain = "nord"
+	}
+	if o.NicknameDomain == "" {
+		o.NicknameDomain = "nord"
+	}
+}
+
+// Build derives the records from the meshnet config and the nodes of the
+// status map. Nodes add names and addresses to the peers of cfg; when cfg is
+// nil every node is used. Records are sorted by name.
+func Build(cfg *telio.Config, nodes []telio.TelioNode, opts BuildOptions) []Record {
+	opts.setDefaults()
+	b := builder{
+		opts:  opts,
+		zones: []string{normalize(opts.HostnameDomain), normalize(opts.NicknameDomain)},
+		names: make(map[string]map[netip.Addr]bool),
+	}
+
+	known := make(map[telio.PublicKey]bool)
+	if cfg != nil {
+		if !opts.SkipSelf {
+			b.addPeer(cfg.This)
+		}
+		if cfg.Peers != nil {
+			for _, p := range *cfg.Peers {
+				known[p.Base.PublicKey] = true
+				b.addPeer(p.Base)
+			}
+		}
+	}
+	for _, n := range nodes {
+		if n.IsVpn || (cfg != nil && !known[n.PublicKey]) {
+			continue
+		}
+		var names []string
+		if n.Hostname != nil {
+			names = append(names, *n.Hostname)
+		}
+		if n.Nickname != nil {
+			names = append(names, b.nickname(*n.Nickname))
+		}
+		b.add(names, n.IpAddresses)
+	}
+	return b.records()
+}
+
+type builder struct {
+	opts  BuildOptions
+	zones []string
+	names map[string]map[netip.Addr]bool
+}
+
+func (b *builder) addPeer(p telio.PeerBase) {
+	names := []string{p.Hostname}
+	if p.Nickname != nil {
+		names = append(names, b.nickname(*p.Nickname))
+	}
+	var addrs []telio.IpAddr
+	if p.IpAddresses != nil {
+		addrs = *p.IpAddresses
+	}
+	b.add(names, addrs)
+}
+
+// nickname returns the name of a nickname in NicknameDomain, or "" for
+// nicknames which are not a single label.
+func (b *builder) nickname(n string) string {
+	n = strings.TrimSpace(n)
+	if n == "" || strings.Contains(n, ".") {
+		return ""
+	}
+	return n + "." + b.opts.NicknameDomain
+}
+
+func (b *builder) add(names []string, addrs []telio.IpAddr) {
+	var parsed []netip.Addr
+	for _, a := range addrs {
+		if addr, err := netip.ParseAddr(a); err == nil {
+			parsed = append(parsed, addr.Unmap())
+		}
+	}
+	if len(parsed) == 0 {
+		return
+	}
+	for _, name := range names {
+		name = normalize(name)
+		if name == "" || !inZone(name, b.zones) {
+			continue
+		}
+		set := b.names[name]
+		if set == nil {
+			set = make(map[netip.Addr]bool)
+			b.names[name] = set
+		}
+		for _, addr := range parsed {
+			set[addr] = true
+		}
+	}
+}
+
+func (b *builder) records() []Record {
+	out := make([]Record, 0, len(b.names))
+	for name, set := range b.names {
+		r := Record{Name: name}
+		for addr := range set {
+			r.Addrs = append(r.Addrs, addr)
+		}
+		sort.Slice(r.Addrs, func(i, j int) bool { return r.Addrs[i].Less(r.Addrs[j]) })
+		out = append(out, r)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
+
+// normalize returns the lower case ASCII form of name without a trailing
+// dot, or "" when name is not a valid DNS name. Names come from the meshnet
+// map of remote peers and are written verbatim to hosts files, so anything
+// but letters, digits, hyphens and dots is rejected.
+func normalize(name string) string {
+	name = strings.TrimSuffix(strings.TrimSpace(name), ".")
+	ascii, err := idna.Lookup.ToASCII(name)
+	if err != nil || !validName(ascii) {
+		return ""
+	}
+	return strings.ToLower(ascii)
+}
+
+// validName reports whether name is made of letter-digit-hyphen labels
+// within the length limits of RFC 1035.
+func validName(name string) bool {
+	if name == "" || len(name) > maxNameLength {
+		return false
+	}
+	for _, label := range strings.Split(name, ".") {
+		if label == "" || len(label) > maxLabelLength || label[0] == '-' || label[len(label)-1] == '-' {
+			return false
+		}
+		for i := 0; i < len(label); i++ {
+			c := label[i]
+			if !('a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' || c == '-') {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// inZone reports whether name is strictly below one of zones.
+func inZone(name string, zones []string) bool {
+	for _, z := range zones {
+		if z != "" && strings.HasSuffix(name, "."+z) {
+			return true
+		}
+	}
+	return false
+}
+
+func equal(a, b []Record) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i].Name != b[i].Name || len(a[i].Addrs) != len(b[i].Addrs) {
+			return false
+		}
+		for j := range a[i].Addrs {
+			if a[i].Addrs[j] != b[i].Addrs[j] {
+				return false
+			}
+		}
+	}
+	return true
+}
@@ -0,0 +1,95 @@
+package meshhosts
+
+import (
+	"net/netip"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	telio "github.com/NordSecurity/libtelio-go/v8"
+)
+
+func TestNormalize(t *testing.T) {
+	for name, want := range map[string]string{
+		"Peer-1.Nord.":                        "peer-1.nord",
+		" laptop.nord ":                       "laptop.nord",
+		"bücher.nord":                         "xn--bcher-kva.nord",
+		"evil.nord\n1.2.3.4 bank.example.com": "",
+		"evil\r.nord":                         "",
+		"evil\x00.nord":                       "",
+		"two words.nord":                      "",
+		"tab\t.nord":                          "",
+		"hash#.nord":                          "",
+		"under_score.nord":                    "",
+		"-leading.nord":                       "",
+		"trailing-.nord":                      "",
+		"empty..nord":                         "",
+		"":                                    "",
+		strings.Repeat("a", 63) + ".nord":     strings.Repeat("a", 63) + ".nord",
+		strings.Repeat("a", 64) + ".nord":     "",
+		strings.Repeat("a.", 127) + "nord":    "",
+	} {
+		if got := normalize(name); got != want {
+			t.Errorf("normalize(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
+
+func TestBuildDropsInjectedNames(t *testing.T) {
+	for _, tc := range []struct{ hostname, nickname string }{
+		{"evil.nord\n8.8.8.8 bank.example.com", "x\rmallory"},
+		// Valid names outside meshnet would override real DNS
+		{"bank.example.com", "www.bank.com"},
+		{"nord", "bank.com."},
+	} {
+		nickname := tc.nickname
+		addrs := []telio.IpAddr{"100.64.0.5"}
+		cfg := &telio.Config{
+			This: telio.PeerBase{Hostname: "self.nord", IpAddresses: &[]telio.IpAddr{"100.64.0.1"}},
+			Peers: &[]telio.Peer{{Base: telio.PeerBase{
+				PublicKey:   "peer",
+				Hostname:    tc.hostname,
+				Nickname:    &nickname,
+				IpAddresses: &addrs,
+			}}},
+		}
+		records := Build(cfg, nil, BuildOptions{})
+		if len(records) != 1 || records[0].Name != "self.nord" {
+			t.Errorf("%q, %q: records %+v, want only self.nord", tc.hostname, tc.nickname, records)
+		}
+	}
+}
+
+func TestBuildZones(t *testing.T) {
+	nickname := "Laptop"
+	cfg := &telio.Config{
+		This: telio.PeerBase{Hostname: "self.mesh.example", Nickname: &nickname, IpAddresses: &[]telio.IpAddr{"100.64.0.1"}},
+	}
+	records := Build(cfg, nil, BuildOptions{HostnameDomain: "mesh.example", NicknameDomain: "nick"})
+	if len(records) != 2 || records[0].Name != "laptop.nick" || records[1].Name != "self.mesh.example" {
+		t.Fatalf("records %+v", records)
+	}
+}
+
+func TestHostsFileSkipsInvalidRecords(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "hosts")
+	if err := os.WriteFile(path, []byte("127.0.0.1 localhost\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	addr := []netip.Addr{netip.MustParseAddr("100.64.0.5")}
+	err := NewHostsFile(path).Update([]Record{
+		{Name: "peer.nord", Addrs: addr},
+		{Name: "evil.nord\n8.8.8.8 bank.example.com", Addrs: addr},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "bank.example.com") || !strings.Contains(string(data), "100.64.0.5\tpeer.nord\n") {
+		t.Fatalf("hosts file:\n%s", data)
+	}
+}
@@ -0,0 +1,85 @@
+package migrate
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+
+	telio "github.com/NordSecurity/libtelio-go/v8"
+)
+
+// featureRenames are the features which moved since earlier versions.
+var featureRenames = []rename{
+	{from: "hide_ips", to: "hide_user_data", reason: "it also covers domains"},
+	{from: "exit_dns", to: "dns.exit_dns", reason: "moved into the DNS features"},
+}
+
+// Features upgrades a features JSON to the current Features. Fields which
+// moved are renamed, fields unknown to Features are dropped. Fields added
+// since, like error_notification_service, post_quantum_vpn.version or
+// multicast, are left out so libtelio uses its defaults.
+func Features(data []byte) ([]byte, []Warning, error) {
+	doc, err := decode(data)
+	if err != nil {
+		return nil, nil, fmt.Errorf("parsing features: %w", err)
+	}
+	var warnings []Warning
+	warn := func(w Warning) { warnings = append(warnings, w) }
+	for _, r := range featureRenames {
+		r.apply(doc, warn)
+	}
+	prune(doc, reflect.TypeOf(telio.Features{}), "", warn)
+
+	out, err := json.Marshal(doc)
+	if err != nil {
+		return nil, nil, err
+	}
+	return out, warnings, nil
+}
+
+// LoadFeatures upgrades a features JSON and deserializes it with libtelio.
+func LoadFeatures(data []byte) (telio.Features, []Warning, error) {
+	migrated, warnings, err := Features(data)
+	if err != nil {
+		return telio.Features{}, warnings, err
+	}
+	features, err := telio.DeserializeFeatureConfig(string(migrated))
+	if err != nil {
+		return telio.Features{}, warnings, fmt.Errorf("deserializing features: %w", err)
+	}
+	return features, warnings, nil
+}
+
+// prune drops the fields of obj which t has no field for, descending into
+// nested objects.
+func prune(obj map[string]any, t reflect.Type, path string, warn func(Warning)) {
+	fields := make(map[string]reflect.Type)
+	for i := 0; i < t.NumField(); i++ {
+		fields[telio.JSONName(t.Field(i))] = t.Field(i).Type
+	}
+
+	keys := make([]string, 0, len(obj))
+	for k := range obj {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		p := k
+		if path != "" {
+			p = path + "." + k
+		}
+		ft, ok := fields[k]
+		if !ok {
+			delete(obj, k)
+			warn(Warning{Kind: Removed, Path: p, Reason: fmt.Sprintf("unknown to libtelio-go v%d", Version)})
+			continue
+		}
+		if ft.Kind() == reflect.Pointer {
+			ft = ft.Elem()
+		}
+		if nested, ok := obj[k].(map[string]any); ok && ft.Kind() == reflect.Struct {
+			prune(nested, ft, p, warn)
+		}
+	}
+}
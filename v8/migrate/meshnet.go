@@ -0,0 +1,68 @@
+package migrate
+
+import (
+	"encoding/json"
+	"fmt"
+
+	telio "github.com/NordSecurity/libtelio-go/v8"
+)
+
+// peerDefaults are the peer permissions added since earlier versions, with
+// the value matching the behavior before they existed.
+var peerDefaults = []struct {
+	name   string
+	value  bool
+	reason string
+}{
+	{name: "allow_peer_send_files", value: false, reason: "added with file sharing permissions"},
+	{name: "allow_multicast", value: false, reason: "added with multicast support"},
+	{name: "peer_allows_multicast", value: false, reason: "added with multicast support"},
+}
+
+// Meshnet upgrades a meshnet map to the current Config by adding the peer
+// permissions it lacks. Unknown fields are kept, maps usually carry more than
+// libtelio reads.
+func Meshnet(data []byte) ([]byte, []Warning, error) {
+	doc, err := decode(data)
+	if err != nil {
+		return nil, nil, fmt.Errorf("parsing meshnet map: %w", err)
+	}
+	var warnings []Warning
+	peers, _ := doc["peers"].([]any)
+	for i, p := range peers {
+		peer, ok := p.(map[string]any)
+		if !ok {
+			continue
+		}
+		for _, d := range peerDefaults {
+			if _, ok := peer[d.name]; ok {
+				continue
+			}
+			peer[d.name] = d.value
+			warnings = append(warnings, Warning{
+				Kind:   Defaulted,
+				Path:   fmt.Sprintf("peers[%d].%s", i, d.name),
+				Reason: d.reason,
+			})
+		}
+	}
+
+	out, err := json.Marshal(doc)
+	if err != nil {
+		return nil, nil, err
+	}
+	return out, warnings, nil
+}
+
+// LoadMeshnet upgrades a meshnet map and deserializes it with libtelio.
+func LoadMeshnet(data []byte) (telio.Config, []Warning, error) {
+	migrated, warnings, err := Meshnet(data)
+	if err != nil {
+		return telio.Config{}, warnings, err
+	}
+	cfg, err := telio.DeserializeMeshnetConfig(string(migrated))
+	if err != nil {
+		return telio.Config{}, warnings, fmt.Errorf("deserializing meshnet map: %w", err)
+	}
+	return cfg, warnings, nil
+}
@@ -0,0 +1,152 @@
+// Package migrate upgrades persisted features JSON and meshnet maps written
+// for earlier major versions of libtelio-go, so that they still deserialize
+// after an upgrade.
+//
+// Persisted documents carry no version, so every known change is recognized
+// by the fields it touches and applying a migration to an up to date
+// document changes nothing. Each change is reported as a Warning, e.g. to be
+// logged once and the migrated document persisted in place of the old one.
+//
+// Meshnet maps had no fields renamed or removed, they only gained peer
+// permissions, so Meshnet only adds those with their defaults.
+package migrate
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Version is the major version the migrated documents are for.
+const Version = 8
+
+// Kind is the kind of a change.
+type Kind int
+
+const (
+	// A field was moved to NewPath
+	Renamed Kind = iota
+	// A field was dropped, as it is no longer known
+	Removed
+	// A missing field was added with its default
+	Defaulted
+)
+
+func (k Kind) String() string {
+	switch k {
+	case Renamed:
+		return "renamed"
+	case Removed:
+		return "removed"
+	case Defaulted:
+		return "defaulted"
+	}
+	return fmt.Sprintf("Kind(%d)", int(k))
+}
+
+// Warning describes a change made to a document.
+type Warning struct {
+	Kind Kind
+	// Dotted path of the field in the input, e.g. "exit_dns.auto_switch_dns_ips"
+	Path string
+	// Path of the field in the output for Renamed
+	NewPath string
+	// Why the change was needed
+	Reason string
+}
+
+func (w Warning) String() string {
+	s := w.Path + ": " + w.Kind.String()
+	if w.Kind == Renamed {
+		s += " to " + w.NewPath
+	}
+	if w.Reason != "" {
+		s += " (" + w.Reason + ")"
+	}
+	return s
+}
+
+// rename moves a field. The old value is dropped when the new field is set
+// already.
+type rename struct {
+	from, to, reason string
+}
+
+func (r rename) apply(doc map[string]any, warn func(Warning)) {
+	v, ok := lookup(doc, r.from)
+	if !ok {
+		return
+	}
+	remove(doc, r.from)
+	if _, ok := lookup(doc, r.to); ok {
+		warn(Warning{Kind: Removed, Path: r.from, Reason: fmt.Sprintf("%s is set, %s", r.to, r.reason)})
+		return
+	}
+	// A section set to null or a scalar stays as it is, e.g. DNS turned off
+	if !store(doc, r.to, v) {
+		warn(Warning{Kind: Removed, Path: r.from, Reason: fmt.Sprintf("the parent of %s is not an object, %s", r.to, r.reason)})
+		return
+	}
+	warn(Warning{Kind: Renamed, Path: r.from, NewPath: r.to, Reason: r.reason})
+}
+
+// decode parses a JSON object keeping numbers as written.
+func decode(data []byte) (map[string]any, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var doc map[string]any
+	if err := dec.Decode(&doc); err != nil {
+		return nil, err
+	}
+	if doc == nil {
+		return nil, fmt.Errorf("not a JSON object")
+	}
+	return doc, nil
+}
+
+func lookup(doc map[string]any, path string) (any, bool) {
+	parent, key := walk(doc, path, false)
+	if parent == nil {
+		return nil, false
+	}
+	v, ok := parent[key]
+	return v, ok
+}
+
+func remove(doc map[string]any, path string) {
+	if parent, key := walk(doc, path, false); parent != nil {
+		delete(parent, key)
+	}
+}
+
+// store sets the field at path and reports whether it could. It fails when
+// a value on the way is not an object.
+func store(doc map[string]any, path string, v any) bool {
+	parent, key := walk(doc, path, true)
+	if parent == nil {
+		return false
+	}
+	parent[key] = v
+	return true
+}
+
+// walk returns the object holding the last element of path, or nil when a
+// value on the way is not an object. Missing objects are created when create
+// is set, values of other types are never replaced.
+func walk(doc map[string]any, path string, create bool) (map[string]any, string) {
+	keys := strings.Split(path, ".")
+	for _, k := range keys[:len(keys)-1] {
+		x, present := doc[k]
+		next, ok := x.(map[string]any)
+		if !ok {
+			if present || !create {
+				return nil, ""
+			}
+			next = make(map[string]any)
+			doc[k] = next
+		}
+		doc = next
+	}
+	return doc, keys[len(keys)-1]
+}
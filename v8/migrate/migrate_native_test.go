@@ -0,0 +1,31 @@
+//go:build native
+
+// Run against the real libtelio with: go test -tags native ./migrate
+
+package migrate
+
+import "testing"
+
+func TestLoadFeatures(t *testing.T) {
+	f, _, err := LoadFeatures([]byte(oldFeatures))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !f.HideUserData || f.Dns.ExitDns == nil || !f.Firewall.BoringtunResetConns {
+		t.Fatalf("values lost: %+v", f)
+	}
+}
+
+func TestLoadMeshnet(t *testing.T) {
+	cfg, _, err := LoadMeshnet([]byte(`{
+		"identifier": "this", "public_key": "key", "hostname": "this.nord",
+		"peers": [{"identifier": "peer", "public_key": "peer-key", "hostname": "peer.nord", "is_local": false,
+			"allow_incoming_connections": true, "allow_peer_traffic_routing": false, "allow_peer_local_network_access": false}]
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Peers == nil || len(*cfg.Peers) != 1 || (*cfg.Peers)[0].AllowPeerSendFiles {
+		t.Fatalf("migrated to %+v", cfg)
+	}
+}
@@ -0,0 +1,122 @@
+package migrate
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	telio "github.com/NordSecurity/libtelio-go/v8"
+	"github.com/NordSecurity/libtelio-go/v8/schema"
+)
+
+// oldFeatures mixes fields of earlier versions with current ones.
+const oldFeatures = `{
+	"hide_ips": true,
+	"exit_dns": {"auto_switch_dns_ips": true},
+	"pmtu_discovery": {"response_wait_timeout_s": 5},
+	"firewall": {"boringtun_reset_conns": true},
+	"nurse": {"heartbeat_interval": 3600, "fingerprint": "x", "qos": {"rtt_interval": 300, "old": 1}},
+	"flush_events_on_stop_timeout_seconds": 18446744073709551615
+}`
+
+func TestFeatures(t *testing.T) {
+	out, warnings, err := Features([]byte(oldFeatures))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, w := range warnings {
+		got = append(got, w.String())
+	}
+	want := []string{
+		"hide_ips: renamed to hide_user_data (it also covers domains)",
+		"exit_dns: renamed to dns.exit_dns (moved into the DNS features)",
+		"nurse.fingerprint: removed (unknown to libtelio-go v8)",
+		"nurse.qos.old: removed (unknown to libtelio-go v8)",
+		"pmtu_discovery: removed (unknown to libtelio-go v8)",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("warnings:\n%q\nwant\n%q", got, want)
+	}
+	if err := schema.ValidateFeatures(out); err != nil {
+		t.Fatalf("migrated features are invalid: %v\n%s", err, out)
+	}
+
+	var f telio.Features
+	if err := json.Unmarshal(out, &f); err != nil {
+		t.Fatal(err)
+	}
+	// BoringtunResetConns is still a v8 field
+	if !f.HideUserData || f.Dns.ExitDns == nil || f.Firewall == nil || !f.Firewall.BoringtunResetConns {
+		t.Fatalf("values lost: %s", out)
+	}
+	if f.FlushEventsOnStopTimeoutSeconds == nil || *f.FlushEventsOnStopTimeoutSeconds != 18446744073709551615 {
+		t.Fatalf("large number changed: %s", out)
+	}
+
+	again, warnings, err := Features(out)
+	if err != nil || len(warnings) != 0 || string(again) != string(out) {
+		t.Fatalf("migrating twice changed the document: %v %v\n%s", warnings, err, again)
+	}
+}
+
+func TestFeaturesRenameConflict(t *testing.T) {
+	out, warnings, err := Features([]byte(`{"hide_ips": false, "hide_user_data": true}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(warnings) != 1 || warnings[0].Kind != Removed || string(out) != `{"hide_user_data":true}` {
+		t.Fatalf("got %s with %v", out, warnings)
+	}
+}
+
+func TestFeaturesRenameIntoNull(t *testing.T) {
+	for _, dns := range []string{`null`, `false`} {
+		out, warnings, err := Features([]byte(`{"dns": ` + dns + `, "exit_dns": {"auto_switch_dns_ips": true}}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(warnings) != 1 || warnings[0].Kind != Removed || warnings[0].Path != "exit_dns" {
+			t.Fatalf("dns %s: warnings %v", dns, warnings)
+		}
+		if string(out) != `{"dns":`+dns+`}` {
+			t.Fatalf("dns %s: migrated to %s", dns, out)
+		}
+	}
+}
+
+func TestMeshnet(t *testing.T) {
+	out, warnings, err := Meshnet([]byte(`{
+		"identifier": "this", "public_key": "key", "hostname": "this.nord",
+		"peers": [{"identifier": "peer", "public_key": "peer-key", "hostname": "peer.nord", "allow_multicast": true}],
+		"extra": 1
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(warnings) != 2 || warnings[0].Path != "peers[0].allow_peer_send_files" || warnings[1].Path != "peers[0].peer_allows_multicast" {
+		t.Fatalf("warnings %v", warnings)
+	}
+	var doc struct {
+		Peers []map[string]any `json:"peers"`
+		Extra int              `json:"extra"`
+	}
+	if err := json.Unmarshal(out, &doc); err != nil {
+		t.Fatal(err)
+	}
+	peer := doc.Peers[0]
+	if peer["allow_multicast"] != true || peer["allow_peer_send_files"] != false || doc.Extra != 1 {
+		t.Fatalf("migrated to %s", out)
+	}
+}
+
+func TestNotAnObject(t *testing.T) {
+	for _, doc := range []string{`null`, `[]`, `{`} {
+		if _, _, err := Features([]byte(doc)); err == nil {
+			t.Errorf("%s: no error", doc)
+		}
+		if _, _, err := Meshnet([]byte(doc)); err == nil {
+			t.Errorf("%s: no error", doc)
+		}
+	}
+}
@@ -0,0 +1,69 @@
+// Package netwatch notices changes of the host network and reports them to
+// libtelio with telio.NotifyNetworkChange.
+package netwatch
+
+import (
+	"encoding/json"
+	"net/netip"
+	"sort"
+)
+
+// NetworkInfo describes the network of the host, leaving out the tunnel and
+// the interfaces excluded by the ext-if filter. It is what NotifyNetworkChange
+// will carry once libtelio defines its payload.
+type NetworkInfo struct {
+	Interfaces    []Interface `json:"interfaces"`
+	DefaultRoutes []Route     `json:"default_routes"`
+}
+
+// Interface is a network interface and its addresses.
+type Interface struct {
+	Name  string         `json:"name"`
+	Index int            `json:"index"`
+	Up    bool           `json:"up"`
+	Addrs []netip.Prefix `json:"addrs"`
+}
+
+// Route is a default route of the main routing table.
+type Route struct {
+	Interface string `json:"interface"`
+	// Invalid for routes without a gateway, e.g. point to point links
+	Gateway netip.Addr `json:"gateway"`
+	IPv6    bool       `json:"ipv6"`
+	Metric  int        `json:"metric"`
+}
+
+// String returns the JSON encoding of the info.
+func (n NetworkInfo) String() string {
+	data, _ := json.Marshal(n)
+	return string(data)
+}
+
+// Equal reports whether n and o describe the same network.
+func (n NetworkInfo) Equal(o NetworkInfo) bool {
+	return n.String() == o.String()
+}
+
+// normalize sorts the info so equal networks have equal encodings.
+func (n *NetworkInfo) normalize() {
+	sort.Slice(n.Interfaces, func(i, j int) bool { return n.Interfaces[i].Index < n.Interfaces[j].Index })
+	for _, iface := range n.Interfaces {
+		sort.Slice(iface.Addrs, func(i, j int) bool {
+			a, b := iface.Addrs[i], iface.Addrs[j]
+			if a.Addr() != b.Addr() {
+				return a.Addr().Less(b.Addr())
+			}
+			return a.Bits() < b.Bits()
+		})
+	}
+	sort.Slice(n.DefaultRoutes, func(i, j int) bool {
+		a, b := n.DefaultRoutes[i], n.DefaultRoutes[j]
+		if a.IPv6 != b.IPv6 {
+			return b.IPv6
+		}
+		if a.Metric != b.Metric {
+			return a.Metric < b.Metric
+		}
+		return a.Interface < b.Interface
+	})
+}
@@ -0,0 +1,106 @@
+package netwatch
+
+import (
+	"sync"
+	"time"
+)
+
+const defaultDebounce = time.Second
+
+// Notifier is the part of telio.TelioInterface used by a Watcher.
+type Notifier interface {
+	NotifyNetworkChange(networkInfo string) error
+}
+
+// Options configure a Watcher.
+type Options struct {
+	// Name of the tunnel interface, whose changes are ignored
+	Tunnel string
+	// Only watch these interfaces, the list passed to telio.SetExtIfFilter
+	ExtIfFilter []string
+	// How long the network must stay unchanged before notifying [default 1s]
+	Debounce time.Duration
+	// Path of the network namespace to watch, e.g. /var/run/netns/test, the
+	// namespace of the calling process when empty
+	Netns string
+	// Pass NetworkInfo as JSON to NotifyNetworkChange. libtelio currently
+	// expects an empty string, so leave it unset until the format is defined
+	SendInfo bool
+	// Called after each notification with the new network
+	OnChange func(NetworkInfo)
+	// Called when notifying libtelio fails
+	OnError func(error)
+}
+
+// Watcher calls NotifyNetworkChange whenever links, addresses or default
+// routes change. Bursts of changes are reported once.
+type Watcher struct {
+	notifier Notifier
+	opts     Options
+
+	mu     sync.Mutex
+	filter map[string]bool
+	info   NetworkInfo
+}
+
+// New creates a watcher notifying n.
+func New(n Notifier, opts Options) *Watcher {
+	if opts.Debounce <= 0 {
+		opts.Debounce = defaultDebounce
+	}
+	w := &Watcher{notifier: n, opts: opts}
+	w.SetExtIfFilter(opts.ExtIfFilter)
+	return w
+}
+
+// SetExtIfFilter restricts the watched interfaces, call it together with
+// telio.SetExtIfFilter. An empty list watches every interface.
+func (w *Watcher) SetExtIfFilter(ifaces []string) {
+	filter := make(map[string]bool, len(ifaces))
+	for _, name := range ifaces {
+		filter[name] = true
+	}
+	w.mu.Lock()
+	w.filter = filter
+	w.mu.Unlock()
+}
+
+// Info returns the network as of the last notification.
+func (w *Watcher) Info() NetworkInfo {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.info
+}
+
+// ignored reports whether changes of the interface are ignored.
+func (w *Watcher) ignored(name string) bool {
+	if name == w.opts.Tunnel {
+		return true
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return len(w.filter) > 0 && !w.filter[name]
+}
+
+// update notifies libtelio when info differs from the last notified network.
+func (w *Watcher) update(info NetworkInfo, notify bool) {
+	info.normalize()
+	w.mu.Lock()
+	changed := !info.Equal(w.info)
+	w.info = info
+	w.mu.Unlock()
+	if !changed || !notify {
+		return
+	}
+
+	payload := ""
+	if w.opts.SendInfo {
+		payload = info.String()
+	}
+	if err := w.notifier.NotifyNetworkChange(payload); err != nil && w.opts.OnError != nil {
+		w.opts.OnError(err)
+	}
+	if w.opts.OnChange != nil {
+		w.opts.OnChange(info)
+	}
+}
@@ -0,0 +1,191 @@
+//go:build linux
+
+package netwatch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"time"
+
+	"github.com/vishvananda/netlink"
+	"github.com/vishvananda/netns"
+	"golang.org/x/sys/unix"
+)
+
+const updateQueueSize = 64
+
+// Run watches the network until ctx is done. It fails when the netlink
+// subscription breaks, in which case it may be restarted.
+func (w *Watcher) Run(ctx context.Context) error {
+	h, ns, err := w.handle()
+	if err != nil {
+		return err
+	}
+	defer h.Close()
+	if ns != nil {
+		defer ns.Close()
+	}
+
+	done := make(chan struct{})
+	defer close(done)
+	errs := make(chan error, 1)
+	onError := func(err error) {
+		select {
+		case errs <- err:
+		default:
+		}
+	}
+	links := make(chan netlink.LinkUpdate, updateQueueSize)
+	addrs := make(chan netlink.AddrUpdate, updateQueueSize)
+	routes := make(chan netlink.RouteUpdate, updateQueueSize)
+	if err := netlink.LinkSubscribeWithOptions(links, done, netlink.LinkSubscribeOptions{Namespace: ns, ErrorCallback: onError}); err != nil {
+		return fmt.Errorf("subscribing to links: %w", err)
+	}
+	if err := netlink.AddrSubscribeWithOptions(addrs, done, netlink.AddrSubscribeOptions{Namespace: ns, ErrorCallback: onError}); err != nil {
+		return fmt.Errorf("subscribing to addresses: %w", err)
+	}
+	if err := netlink.RouteSubscribeWithOptions(routes, done, netlink.RouteSubscribeOptions{Namespace: ns, ErrorCallback: onError}); err != nil {
+		return fmt.Errorf("subscribing to routes: %w", err)
+	}
+
+	// The starting point is not a change
+	info, err := w.snapshot(h)
+	if err != nil {
+		return err
+	}
+	w.update(info, false)
+
+	timer := time.NewTimer(w.opts.Debounce)
+	timer.Stop()
+	defer timer.Stop()
+	schedule := func() { timer.Reset(w.opts.Debounce) }
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case err := <-errs:
+			return fmt.Errorf("netlink subscription: %w", err)
+		case u, ok := <-links:
+			if !ok {
+				return errors.New("link subscription closed")
+			}
+			if !w.ignored(u.Link.Attrs().Name) {
+				schedule()
+			}
+		case u, ok := <-addrs:
+			if !ok {
+				return errors.New("address subscription closed")
+			}
+			if !w.ignoredIndex(h, u.LinkIndex) {
+				schedule()
+			}
+		case u, ok := <-routes:
+			if !ok {
+				return errors.New("route subscription closed")
+			}
+			if u.Table == unix.RT_TABLE_MAIN && !w.ignoredIndex(h, u.LinkIndex) {
+				schedule()
+			}
+		case <-timer.C:
+			info, err := w.snapshot(h)
+			if err != nil {
+				if w.opts.OnError != nil {
+					w.opts.OnError(err)
+				}
+				continue
+			}
+			w.update(info, true)
+		}
+	}
+}
+
+func (w *Watcher) handle() (*netlink.Handle, *netns.NsHandle, error) {
+	if w.opts.Netns == "" {
+		h, err := netlink.NewHandle()
+		return h, nil, err
+	}
+	ns, err := netns.GetFromPath(w.opts.Netns)
+	if err != nil {
+		return nil, nil, fmt.Errorf("opening network namespace %s: %w", w.opts.Netns, err)
+	}
+	h, err := netlink.NewHandleAt(ns)
+	if err != nil {
+		ns.Close()
+		return nil, nil, err
+	}
+	return h, &ns, nil
+}
+
+// ignoredIndex reports whether changes of the interface with the index are
+// ignored. Changes of interfaces which are already gone are kept.
+func (w *Watcher) ignoredIndex(h *netlink.Handle, index int) bool {
+	link, err := h.LinkByIndex(index)
+	if err != nil {
+		return false
+	}
+	return w.ignored(link.Attrs().Name)
+}
+
+// snapshot reads the current network.
+func (w *Watcher) snapshot(h *netlink.Handle) (NetworkInfo, error) {
+	links, err := h.LinkList()
+	if err != nil {
+		return NetworkInfo{}, fmt.Errorf("listing links: %w", err)
+	}
+	info := NetworkInfo{Interfaces: []Interface{}, DefaultRoutes: []Route{}}
+	names := make(map[int]string)
+	for _, link := range links {
+		attrs := link.Attrs()
+		if attrs.Flags&net.FlagLoopback != 0 || w.ignored(attrs.Name) {
+			continue
+		}
+		names[attrs.Index] = attrs.Name
+		iface := Interface{
+			Name:  attrs.Name,
+			Index: attrs.Index,
+			Up:    attrs.OperState == netlink.OperUp || (attrs.OperState == netlink.OperUnknown && attrs.Flags&net.FlagUp != 0),
+			Addrs: []netip.Prefix{},
+		}
+		addrs, err := h.AddrList(link, netlink.FAMILY_ALL)
+		if err != nil {
+			return NetworkInfo{}, fmt.Errorf("listing addresses of %s: %w", attrs.Name, err)
+		}
+		for _, a := range addrs {
+			addr, ok := netip.AddrFromSlice(a.IP)
+			if !ok {
+				continue
+			}
+			ones, _ := a.Mask.Size()
+			iface.Addrs = append(iface.Addrs, netip.PrefixFrom(addr.Unmap(), ones))
+		}
+		info.Interfaces = append(info.Interfaces, iface)
+	}
+
+	for _, family := range []int{netlink.FAMILY_V4, netlink.FAMILY_V6} {
+		routes, err := h.RouteListFiltered(family, &netlink.Route{Table: unix.RT_TABLE_MAIN}, netlink.RT_FILTER_TABLE)
+		if err != nil {
+			return NetworkInfo{}, fmt.Errorf("listing routes: %w", err)
+		}
+		for _, r := range routes {
+			if r.Dst != nil {
+				if ones, _ := r.Dst.Mask.Size(); ones != 0 {
+					continue
+				}
+			}
+			name, ok := names[r.LinkIndex]
+			if !ok {
+				continue
+			}
+			route := Route{Interface: name, IPv6: family == netlink.FAMILY_V6, Metric: r.Priority}
+			if gw, ok := netip.AddrFromSlice(r.Gw); ok {
+				route.Gateway = gw.Unmap()
+			}
+			info.DefaultRoutes = append(info.DefaultRoutes, route)
+		}
+	}
+	return info, nil
+}
@@ -0,0 +1,14 @@
+//go:build !linux
+
+package netwatch
+
+import (
+	"context"
+	"errors"
+)
+
+// Run always fails with errors.ErrUnsupported, the watcher is only
+// implemented on Linux.
+func (w *Watcher) Run(ctx context.Context) error {
+	return errors.ErrUnsupported
+}
@@ -0,0 +1,286 @@
+// Package overlay overrides single features from environment variables and
+// command line flags, e.g. for debugging without editing the features JSON:
+//
+//	TELIO_FEATURES_DIRECT_ENDPOINT_INTERVAL_SECS=5
+//	--telio.nurse.heartbeat-interval=30
+//
+// Features are addressed by the dotted path of their JSON names,
+// "direct.endpoint_interval_secs" for the examples above. Setting a field of
+// an optional section which is off, like "nurse", turns the section on.
+package overlay
+
+import (
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	telio "github.com/NordSecurity/libtelio-go/v8"
+)
+
+const (
+	defaultEnvPrefix  = "TELIO_FEATURES_"
+	defaultFlagPrefix = "telio."
+)
+
+var textUnmarshaler = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+
+// Field is a feature which can be overridden.
+type Field struct {
+	// Dotted path of JSON names, e.g. "nurse.heartbeat_interval"
+	Path string
+	Type reflect.Type
+
+	index []int
+}
+
+var fields = sync.OnceValue(func() []Field {
+	var out []Field
+	collect(reflect.TypeOf(telio.Features{}), "", nil, &out)
+	sort.Slice(out, func(i, j int) bool { return out[i].Path < out[j].Path })
+	return out
+})
+
+// collect adds the leaves below t, descending into structs and optional
+// structs.
+func collect(t reflect.Type, path string, index []int, out *[]Field) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name := telio.JSONName(f)
+		if path != "" {
+			name = path + "." + name
+		}
+		idx := append(append([]int(nil), index...), i)
+		ft := f.Type
+		if ft.Kind() == reflect.Pointer && ft.Elem().Kind() == reflect.Struct {
+			ft = ft.Elem()
+		}
+		if ft.Kind() == reflect.Struct {
+			collect(ft, name, idx, out)
+			continue
+		}
+		*out = append(*out, Field{Path: name, Type: f.Type, index: idx})
+	}
+}
+
+// Fields returns every feature which can be overridden, sorted by path.
+func Fields() []Field {
+	return fields()
+}
+
+func lookup(path string) (Field, bool) {
+	all := fields()
+	i := sort.Search(len(all), func(i int) bool { return all[i].Path >= path })
+	if i < len(all) && all[i].Path == path {
+		return all[i], true
+	}
+	return Field{}, false
+}
+
+// Override describes an overridden feature.
+type Override struct {
+	Path string
+	// Where the value came from, e.g. "$TELIO_FEATURES_IPV6" or "-telio.ipv6"
+	Source string
+	// Old and new value in JSON
+	Old, New string
+}
+
+func (o Override) String() string {
+	return fmt.Sprintf("%s = %s (was %s) from %s", o.Path, o.New, o.Old, o.Source)
+}
+
+// Overlay applies overrides to features.
+type Overlay struct {
+	// Prefix of the environment variables [default "TELIO_FEATURES_"]
+	EnvPrefix string
+	// Prefix of the flags [default "telio."]
+	FlagPrefix string
+	// Initial values of optional sections turned on by an override, e.g.
+	// built with telio.FeaturesDefaultsBuilder. Their fields are zero when
+	// nil or when the section is nil in Enabled.
+	Enabled *telio.Features
+}
+
+func (o *Overlay) envPrefix() string {
+	if o.EnvPrefix == "" {
+		return defaultEnvPrefix
+	}
+	return o.EnvPrefix
+}
+
+func (o *Overlay) flagPrefix() string {
+	if o.FlagPrefix == "" {
+		return defaultFlagPrefix
+	}
+	return o.FlagPrefix
+}
+
+// EnvName returns the environment variable of a path.
+func (o *Overlay) EnvName(path string) string {
+	return o.envPrefix() + strings.ToUpper(strings.ReplaceAll(path, ".", "_"))
+}
+
+// FlagName returns the flag of a path.
+func (o *Overlay) FlagName(path string) string {
+	return o.flagPrefix() + strings.ReplaceAll(path, "_", "-")
+}
+
+// Set parses value according to the type of the feature at path and stores it
+// in f.
+func (o *Overlay) Set(f *telio.Features, path, value string) (Override, error) {
+	field, ok := lookup(path)
+	if !ok {
+		return Override{}, fmt.Errorf("unknown feature %q", path)
+	}
+	parsed, err := parse(field.Type, value)
+	if err != nil {
+		return Override{}, fmt.Errorf("%s: %w", path, err)
+	}
+
+	dst := o.field(reflect.ValueOf(f).Elem(), field.index)
+	ov := Override{Path: path, Old: encode(dst), New: encode(parsed)}
+	dst.Set(parsed)
+	return ov, nil
+}
+
+// field returns the field at index, turning on optional sections on the way.
+func (o *Overlay) field(v reflect.Value, index []int) reflect.Value {
+	var enabled reflect.Value
+	if o.Enabled != nil {
+		enabled = reflect.ValueOf(o.Enabled).Elem()
+	}
+	for n, i := range index {
+		v = v.Field(i)
+		if enabled.IsValid() {
+			enabled = enabled.Field(i)
+		}
+		if n == len(index)-1 || v.Kind() != reflect.Pointer {
+			continue
+		}
+		if enabled.IsValid() && enabled.IsNil() {
+			enabled = reflect.Value{}
+		}
+		if v.IsNil() {
+			section := reflect.New(v.Type().Elem())
+			if enabled.IsValid() {
+				section.Elem().Set(deepCopy(enabled.Elem()))
+			}
+			v.Set(section)
+		}
+		v = v.Elem()
+		if enabled.IsValid() {
+			enabled = enabled.Elem()
+		}
+	}
+	return v
+}
+
+// deepCopy copies v through JSON, so that the copy shares no pointers.
+func deepCopy(v reflect.Value) reflect.Value {
+	c := reflect.New(v.Type())
+	data, err := json.Marshal(v.Interface())
+	if err == nil {
+		err = json.Unmarshal(data, c.Interface())
+	}
+	if err != nil {
+		return reflect.Zero(v.Type())
+	}
+	return c.Elem()
+}
+
+// parse converts value to t. Optional values are cleared with "null", lists
+// are comma separated or JSON arrays.
+func parse(t reflect.Type, value string) (reflect.Value, error) {
+	if t.Kind() == reflect.Pointer {
+		if value == "null" {
+			return reflect.Zero(t), nil
+		}
+		elem, err := parse(t.Elem(), value)
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		p := reflect.New(t.Elem())
+		p.Elem().Set(elem)
+		return p, nil
+	}
+
+	v := reflect.New(t).Elem()
+	if reflect.PointerTo(t).Implements(textUnmarshaler) {
+		err := v.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(value))
+		return v, err
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return reflect.Value{}, fmt.Errorf("invalid bool %q", value)
+		}
+		v.SetBool(b)
+	case reflect.String:
+		v.SetString(value)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(value, 0, t.Bits())
+		if err != nil {
+			return reflect.Value{}, fmt.Errorf("invalid %s %q", t, value)
+		}
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(value, 0, t.Bits())
+		if err != nil {
+			return reflect.Value{}, fmt.Errorf("invalid %s %q", t, value)
+		}
+		v.SetUint(n)
+	case reflect.Slice:
+		if strings.HasPrefix(strings.TrimSpace(value), "[") {
+			if err := json.Unmarshal([]byte(value), v.Addr().Interface()); err != nil {
+				return reflect.Value{}, fmt.Errorf("invalid list: %w", err)
+			}
+			return v, nil
+		}
+		v.Set(reflect.MakeSlice(t, 0, 0))
+		if value == "" {
+			return v, nil
+		}
+		for _, item := range strings.Split(value, ",") {
+			elem, err := parse(t.Elem(), strings.TrimSpace(item))
+			if err != nil {
+				return reflect.Value{}, err
+			}
+			v.Set(reflect.Append(v, elem))
+		}
+	default:
+		return reflect.Value{}, fmt.Errorf("unsupported type %s", t)
+	}
+	return v, nil
+}
+
+func encode(v reflect.Value) string {
+	x := v.Interface()
+	// libtelio writes byte slices as numbers, encoding/json as base64
+	switch b := x.(type) {
+	case *[]byte:
+		if b != nil {
+			x = bytesAsInts(*b)
+		}
+	case []byte:
+		x = bytesAsInts(b)
+	}
+	data, err := json.Marshal(x)
+	if err != nil {
+		return fmt.Sprint(v.Interface())
+	}
+	return string(data)
+}
+
+func bytesAsInts(b []byte) []int {
+	ints := make([]int, len(b))
+	for i := range b {
+		ints[i] = int(b[i])
+	}
+	return ints
+}
@@ -0,0 +1,123 @@
+package overlay
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"reflect"
+	"slices"
+	"sort"
+	"strings"
+
+	telio "github.com/NordSecurity/libtelio-go/v8"
+)
+
+// ApplyEnv applies the variables of environ, as returned by os.Environ, which
+// start with the prefix. Unknown variables with the prefix are errors, so
+// typos don't go unnoticed.
+func (o *Overlay) ApplyEnv(f *telio.Features, environ []string) ([]Override, error) {
+	byName := make(map[string]string)
+	for _, field := range Fields() {
+		byName[o.EnvName(field.Path)] = field.Path
+	}
+
+	prefix := o.envPrefix()
+	var (
+		overrides []Override
+		errs      []error
+	)
+	environ = slices.Clone(environ)
+	sort.Strings(environ)
+	for _, kv := range environ {
+		name, value, _ := strings.Cut(kv, "=")
+		if !strings.HasPrefix(name, prefix) {
+			continue
+		}
+		path, ok := byName[name]
+		if !ok {
+			errs = append(errs, fmt.Errorf("$%s: unknown feature", name))
+			continue
+		}
+		ov, err := o.Set(f, path, value)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("$%s: %w", name, err))
+			continue
+		}
+		ov.Source = "$" + name
+		overrides = append(overrides, ov)
+	}
+	return overrides, errors.Join(errs...)
+}
+
+// Flags holds the values of the flags registered by RegisterFlags.
+type Flags struct {
+	o   *Overlay
+	set []pendingFlag
+}
+
+type pendingFlag struct {
+	name, path, value string
+}
+
+// RegisterFlags defines a flag on fs for every feature. Values are checked
+// when fs is parsed and applied by Flags.Apply.
+func (o *Overlay) RegisterFlags(fs *flag.FlagSet) *Flags {
+	fl := &Flags{o: o}
+	for _, field := range Fields() {
+		name, path, t := o.FlagName(field.Path), field.Path, field.Type
+		set := func(value string) error {
+			if _, err := parse(t, value); err != nil {
+				return err
+			}
+			fl.set = append(fl.set, pendingFlag{name: name, path: path, value: value})
+			return nil
+		}
+		usage := fmt.Sprintf("override features %s (%s)", path, describe(t))
+		if t.Kind() == reflect.Bool || t.Kind() == reflect.Pointer && t.Elem().Kind() == reflect.Bool {
+			fs.BoolFunc(name, usage, set)
+		} else {
+			fs.Func(name, usage, set)
+		}
+	}
+	return fl
+}
+
+// Apply applies the flags given on the command line, in their order.
+func (fl *Flags) Apply(f *telio.Features) ([]Override, error) {
+	overrides := make([]Override, 0, len(fl.set))
+	for _, p := range fl.set {
+		ov, err := fl.o.Set(f, p.path, p.value)
+		if err != nil {
+			return overrides, fmt.Errorf("-%s: %w", p.name, err)
+		}
+		ov.Source = "-" + p.name
+		overrides = append(overrides, ov)
+	}
+	return overrides, nil
+}
+
+// describe names the accepted values of t for flag usage.
+func describe(t reflect.Type) string {
+	optional := ""
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+		optional = ", null to unset"
+	}
+	if reflect.PointerTo(t).Implements(textUnmarshaler) && t.Kind() == reflect.Uint {
+		var names []string
+		for i := uint64(1); ; i++ {
+			v := reflect.New(t).Elem()
+			v.SetUint(i)
+			text, err := v.Interface().(interface{ MarshalText() ([]byte, error) }).MarshalText()
+			if err != nil {
+				break
+			}
+			names = append(names, string(text))
+		}
+		return strings.Join(names, "|") + optional
+	}
+	if t.Kind() == reflect.Slice {
+		return "comma separated " + describe(t.Elem()) + " or JSON array" + optional
+	}
+	return t.Kind().String() + optional
+}
@@ -0,0 +1,83 @@
+// Package routing installs the policy routing needed to send traffic through
+// a VPN exit node on Linux.
+//
+// libtelio leaves routing to the application. A Manager mirrors what wg-quick
+// does: the exit node's allowed IPs are routed through the tunnel interface in
+// a dedicated table, which is looked up by every packet not carrying the
+// fwmark passed to telio.SetFwmark, so libtelio's own sockets keep using the
+// physical interface. A second rule lets more specific routes of the main
+// table, e.g. the LAN, take precedence over the tunnel.
+//
+// Everything a Manager installs is identified by its table and rule
+// priorities, so state left behind by a crashed process is removed by calling
+// Revert on a Manager with the same Config.
+package routing
+
+import (
+	"fmt"
+	"net/netip"
+
+	telio "github.com/NordSecurity/libtelio-go/v8"
+)
+
+const (
+	defaultTable    = 51820
+	defaultPriority = 32000
+)
+
+// Config describes the routing of a Manager.
+type Config struct {
+	// Name of the tunnel interface
+	Interface string
+	// Value passed to telio.SetFwmark
+	Fwmark uint32
+	// Routing table holding the tunnel routes [default 51820]
+	Table int
+	// Priority of the fwmark rule, the rule suppressing the default route of
+	// the main table uses Priority-1 [default 32000]
+	Priority int
+	// Mirror the routing for IPv6, set when Features.Ipv6 is enabled
+	IPv6 bool
+	// Path of the network namespace to configure, e.g. /var/run/netns/test,
+	// the namespace of the calling process when empty
+	Netns string
+}
+
+func (c *Config) setDefaults() error {
+	if c.Interface == "" {
+		return fmt.Errorf("interface is required")
+	}
+	if c.Fwmark == 0 {
+		return fmt.Errorf("fwmark is required")
+	}
+	if c.Table <= 0 {
+		c.Table = defaultTable
+	}
+	if c.Priority <= 1 {
+		c.Priority = defaultPriority
+	}
+	return nil
+}
+
+// prefixes parses allowedIps, dropping IPv6 prefixes unless enabled. Nil
+// allowedIps route everything, as in telio.ConnectToExitNode.
+func (c *Config) prefixes(allowedIps []telio.IpNet) ([]netip.Prefix, error) {
+	if allowedIps == nil {
+		allowedIps = []telio.IpNet{"0.0.0.0/0", "::/0"}
+	}
+	var out []netip.Prefix
+	seen := make(map[netip.Prefix]bool)
+	for _, s := range allowedIps {
+		p, err := netip.ParsePrefix(s)
+		if err != nil {
+			return nil, fmt.Errorf("allowed IP %q: %w", s, err)
+		}
+		p = p.Masked()
+		if (p.Addr().Is6() && !c.IPv6) || seen[p] {
+			continue
+		}
+		seen[p] = true
+		out = append(out, p)
+	}
+	return out, nil
+}
@@ -0,0 +1,239 @@
+//go:build linux
+
+package routing
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"sync"
+
+	telio "github.com/NordSecurity/libtelio-go/v8"
+	"github.com/vishvananda/netlink"
+	"github.com/vishvananda/netns"
+	"golang.org/x/sys/unix"
+)
+
+// Manager installs and removes the policy routing for an exit node.
+type Manager struct {
+	cfg Config
+	mu  sync.Mutex
+}
+
+// NewManager creates a manager for cfg. Nothing is changed until Apply.
+func NewManager(cfg Config) (*Manager, error) {
+	if err := cfg.setDefaults(); err != nil {
+		return nil, err
+	}
+	return &Manager{cfg: cfg}, nil
+}
+
+// Apply routes allowedIps through the tunnel, as passed to
+// telio.ConnectToExitNode or reported in TelioNode.AllowedIps. Apply is
+// idempotent: routes and rules already in place are kept and stale ones are
+// removed, so it can be called again whenever the exit node changes.
+func (m *Manager) Apply(allowedIps []telio.IpNet) error {
+	prefixes, err := m.cfg.prefixes(allowedIps)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	h, err := m.handle()
+	if err != nil {
+		return err
+	}
+	defer h.Close()
+
+	link, err := h.LinkByName(m.cfg.Interface)
+	if err != nil {
+		return fmt.Errorf("looking up %s: %w", m.cfg.Interface, err)
+	}
+
+	for _, family := range []int{netlink.FAMILY_V4, netlink.FAMILY_V6} {
+		var want []netip.Prefix
+		for _, p := range prefixes {
+			if familyOf(p.Addr()) == family {
+				want = append(want, p)
+			}
+		}
+		if err := m.syncRoutes(h, family, link.Attrs().Index, want); err != nil {
+			return err
+		}
+		if err := m.syncRules(h, family, len(want) > 0); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Revert removes every route and rule installed by a manager with the same
+// Config, including ones left behind by a previous process.
+func (m *Manager) Revert() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	h, err := m.handle()
+	if err != nil {
+		return err
+	}
+	defer h.Close()
+
+	var errs []error
+	for _, family := range []int{netlink.FAMILY_V4, netlink.FAMILY_V6} {
+		if err := m.syncRoutes(h, family, 0, nil); err != nil {
+			errs = append(errs, err)
+		}
+		if err := m.syncRules(h, family, false); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (m *Manager) handle() (*netlink.Handle, error) {
+	if m.cfg.Netns == "" {
+		return netlink.NewHandle()
+	}
+	ns, err := netns.GetFromPath(m.cfg.Netns)
+	if err != nil {
+		return nil, fmt.Errorf("opening network namespace %s: %w", m.cfg.Netns, err)
+	}
+	defer ns.Close()
+	return netlink.NewHandleAt(ns)
+}
+
+// syncRoutes makes the routing table contain exactly the routes to want
+// through link.
+func (m *Manager) syncRoutes(h *netlink.Handle, family, link int, want []netip.Prefix) error {
+	existing, err := h.RouteListFiltered(family, &netlink.Route{Table: m.cfg.Table}, netlink.RT_FILTER_TABLE)
+	if err != nil {
+		return fmt.Errorf("listing routes of table %d: %w", m.cfg.Table, err)
+	}
+
+	missing := make(map[netip.Prefix]bool, len(want))
+	for _, p := range want {
+		missing[p] = true
+	}
+	for _, r := range existing {
+		dst := routeDst(r, family)
+		if missing[dst] && r.LinkIndex == link {
+			delete(missing, dst)
+			continue
+		}
+		r := r
+		if err := h.RouteDel(&r); err != nil && !errors.Is(err, unix.ESRCH) {
+			return fmt.Errorf("removing route %s: %w", dst, err)
+		}
+	}
+
+	for _, p := range want {
+		if !missing[p] {
+			continue
+		}
+		route := &netlink.Route{
+			LinkIndex: link,
+			Dst:       toIPNet(p),
+			Table:     m.cfg.Table,
+			Scope:     netlink.SCOPE_LINK,
+		}
+		if family == netlink.FAMILY_V6 {
+			route.Scope = netlink.SCOPE_UNIVERSE
+		}
+		if err := h.RouteReplace(route); err != nil {
+			return fmt.Errorf("adding route %s: %w", p, err)
+		}
+	}
+	return nil
+}
+
+// syncRules installs the rules of family when enabled and removes them
+// otherwise.
+func (m *Manager) syncRules(h *netlink.Handle, family int, enabled bool) error {
+	rules, err := h.RuleList(family)
+	if err != nil {
+		return fmt.Errorf("listing rules: %w", err)
+	}
+
+	hasMark, hasSuppress := false, false
+	for _, r := range rules {
+		var ok bool
+		switch {
+		case r.Priority == m.cfg.Priority && r.Table == m.cfg.Table:
+			ok = enabled && !hasMark && r.Invert && r.Mark == m.cfg.Fwmark
+			hasMark = hasMark || ok
+		case r.Priority == m.cfg.Priority-1 && r.Table == unix.RT_TABLE_MAIN && r.SuppressPrefixlen == 0:
+			ok = enabled && !hasSuppress
+			hasSuppress = hasSuppress || ok
+		default:
+			continue
+		}
+		if ok {
+			continue
+		}
+		r := r
+		if err := h.RuleDel(&r); err != nil && !errors.Is(err, unix.ENOENT) {
+			return fmt.Errorf("removing rule %d: %w", r.Priority, err)
+		}
+	}
+	if !enabled {
+		return nil
+	}
+
+	if !hasSuppress {
+		rule := netlink.NewRule()
+		rule.Family = family
+		rule.Priority = m.cfg.Priority - 1
+		rule.Table = unix.RT_TABLE_MAIN
+		rule.SuppressPrefixlen = 0
+		if err := h.RuleAdd(rule); err != nil {
+			return fmt.Errorf("adding suppress_prefixlength rule: %w", err)
+		}
+	}
+	if !hasMark {
+		rule := netlink.NewRule()
+		rule.Family = family
+		rule.Priority = m.cfg.Priority
+		rule.Table = m.cfg.Table
+		rule.Mark = m.cfg.Fwmark
+		rule.Invert = true
+		if err := h.RuleAdd(rule); err != nil {
+			return fmt.Errorf("adding fwmark rule: %w", err)
+		}
+	}
+	return nil
+}
+
+func familyOf(addr netip.Addr) int {
+	if addr.Is4() {
+		return netlink.FAMILY_V4
+	}
+	return netlink.FAMILY_V6
+}
+
+// routeDst returns the destination of r, which is nil for default routes.
+func routeDst(r netlink.Route, family int) netip.Prefix {
+	if r.Dst == nil {
+		if family == netlink.FAMILY_V4 {
+			return netip.PrefixFrom(netip.IPv4Unspecified(), 0)
+		}
+		return netip.PrefixFrom(netip.IPv6Unspecified(), 0)
+	}
+	addr, _ := netip.AddrFromSlice(r.Dst.IP)
+	if family == netlink.FAMILY_V4 {
+		addr = addr.Unmap()
+	}
+	ones, _ := r.Dst.Mask.Size()
+	return netip.PrefixFrom(addr, ones)
+}
+
+func toIPNet(p netip.Prefix) *net.IPNet {
+	bits := 32
+	if p.Addr().Is6() {
+		bits = 128
+	}
+	return &net.IPNet{IP: p.Addr().AsSlice(), Mask: net.CIDRMask(p.Bits(), bits)}
+}
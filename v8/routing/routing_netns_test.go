@@ -0,0 +1,215 @@
+//go:build linux && netns
+
+// Run as root with: go test -tags netns ./routing
+
+package routing
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"testing"
+
+	telio "github.com/NordSecurity/libtelio-go/v8"
+	"github.com/vishvananda/netlink"
+	"github.com/vishvananda/netns"
+	"golang.org/x/sys/unix"
+)
+
+const testInterface = "telio-test"
+
+// testNetns creates a network namespace holding an up veth pair standing in
+// for the tunnel interface and returns its path and a handle into it.
+func testNetns(t *testing.T) (string, *netlink.Handle) {
+	t.Helper()
+	if os.Geteuid() != 0 {
+		t.Skip("creating a network namespace requires root")
+	}
+
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	orig, err := netns.Get()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer orig.Close()
+
+	name := fmt.Sprintf("telio-routing-%d", os.Getpid())
+	ns, err := netns.NewNamed(name)
+	if err != nil {
+		t.Skipf("creating a network namespace: %v", err)
+	}
+	if err := netns.Set(orig); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		ns.Close()
+		netns.DeleteNamed(name)
+	})
+
+	h, err := netlink.NewHandleAt(ns)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(h.Close)
+	link := &netlink.Veth{LinkAttrs: netlink.LinkAttrs{Name: testInterface}, PeerName: testInterface + "-peer"}
+	if err := h.LinkAdd(link); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{testInterface, link.PeerName} {
+		l, err := h.LinkByName(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := h.LinkSetUp(l); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return "/var/run/netns/" + name, h
+}
+
+// tableRoutes returns the destinations in table.
+func tableRoutes(t *testing.T, h *netlink.Handle, table int) []string {
+	t.Helper()
+	var dsts []string
+	for _, family := range []int{netlink.FAMILY_V4, netlink.FAMILY_V6} {
+		routes, err := h.RouteListFiltered(family, &netlink.Route{Table: table}, netlink.RT_FILTER_TABLE)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, r := range routes {
+			dsts = append(dsts, routeDst(r, family).String())
+		}
+	}
+	return dsts
+}
+
+// managedRules counts the fwmark and suppress rules of cfg per family.
+func managedRules(t *testing.T, h *netlink.Handle, cfg Config) (mark, suppress map[int]int) {
+	t.Helper()
+	mark, suppress = make(map[int]int), make(map[int]int)
+	for _, family := range []int{netlink.FAMILY_V4, netlink.FAMILY_V6} {
+		rules, err := h.RuleList(family)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, r := range rules {
+			switch {
+			case r.Priority == cfg.Priority && r.Table == cfg.Table && r.Mark == cfg.Fwmark && r.Invert:
+				mark[family]++
+			case r.Priority == cfg.Priority-1 && r.Table == unix.RT_TABLE_MAIN && r.SuppressPrefixlen == 0:
+				suppress[family]++
+			}
+		}
+	}
+	return mark, suppress
+}
+
+func sameSet(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	seen := make(map[string]int)
+	for _, s := range a {
+		seen[s]++
+	}
+	for _, s := range b {
+		seen[s]--
+	}
+	for _, n := range seen {
+		if n != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func TestManagerNetns(t *testing.T) {
+	path, h := testNetns(t)
+	cfg := Config{Interface: testInterface, Fwmark: 11673110, IPv6: true, Netns: path}
+	m, err := NewManager(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg = m.cfg
+
+	check := func(step string, routes []string, rules int) {
+		t.Helper()
+		if got := tableRoutes(t, h, cfg.Table); !sameSet(got, routes) {
+			t.Fatalf("%s: routes %v, want %v", step, got, routes)
+		}
+		mark, suppress := managedRules(t, h, cfg)
+		for _, family := range []int{netlink.FAMILY_V4, netlink.FAMILY_V6} {
+			if mark[family] != rules || suppress[family] != rules {
+				t.Fatalf("%s: family %d has %d fwmark and %d suppress rules, want %d", step, family, mark[family], suppress[family], rules)
+			}
+		}
+	}
+
+	if err := m.Apply(nil); err != nil {
+		t.Fatal(err)
+	}
+	check("apply", []string{"0.0.0.0/0", "::/0"}, 1)
+
+	if err := m.Apply(nil); err != nil {
+		t.Fatal(err)
+	}
+	check("apply again", []string{"0.0.0.0/0", "::/0"}, 1)
+
+	if err := m.Apply([]telio.IpNet{"10.0.0.0/8", "10.1.2.3/8", "fd00::/8"}); err != nil {
+		t.Fatal(err)
+	}
+	check("change allowed IPs", []string{"10.0.0.0/8", "fd00::/8"}, 1)
+
+	if err := m.Revert(); err != nil {
+		t.Fatal(err)
+	}
+	check("revert", nil, 0)
+}
+
+func TestManagerNetnsRevertLeftovers(t *testing.T) {
+	path, h := testNetns(t)
+	cfg := Config{Interface: testInterface, Fwmark: 11673110, IPv6: true, Netns: path}
+	crashed, err := NewManager(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := crashed.Apply([]telio.IpNet{"0.0.0.0/0", "::/0"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// A new process only knows the Config
+	m, err := NewManager(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Revert(); err != nil {
+		t.Fatal(err)
+	}
+	if routes := tableRoutes(t, h, m.cfg.Table); len(routes) != 0 {
+		t.Fatalf("routes left behind: %v", routes)
+	}
+	mark, suppress := managedRules(t, h, m.cfg)
+	if len(mark)+len(suppress) != 0 {
+		t.Fatalf("rules left behind: %v %v", mark, suppress)
+	}
+}
+
+func TestManagerNetnsIPv4Only(t *testing.T) {
+	path, h := testNetns(t)
+	m, err := NewManager(Config{Interface: testInterface, Fwmark: 1, Netns: path})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Revert()
+	if err := m.Apply(nil); err != nil {
+		t.Fatal(err)
+	}
+	if got := tableRoutes(t, h, m.cfg.Table); !sameSet(got, []string{"0.0.0.0/0"}) {
+		t.Fatalf("routes %v, want only the IPv4 default route", got)
+	}
+	mark, _ := managedRules(t, h, m.cfg)
+	if mark[netlink.FAMILY_V6] != 0 {
+		t.Fatal("IPv6 rule installed with IPv6 disabled")
+	}
+}
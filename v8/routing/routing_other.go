@@ -0,0 +1,33 @@
+//go:build !linux
+
+package routing
+
+import (
+	"errors"
+
+	telio "github.com/NordSecurity/libtelio-go/v8"
+)
+
+// Manager installs and removes the policy routing for an exit node. It is
+// only implemented on Linux.
+type Manager struct {
+	cfg Config
+}
+
+// NewManager creates a manager for cfg. Nothing is changed until Apply.
+func NewManager(cfg Config) (*Manager, error) {
+	if err := cfg.setDefaults(); err != nil {
+		return nil, err
+	}
+	return &Manager{cfg: cfg}, nil
+}
+
+// Apply always fails with errors.ErrUnsupported.
+func (m *Manager) Apply(allowedIps []telio.IpNet) error {
+	return errors.ErrUnsupported
+}
+
+// Revert always fails with errors.ErrUnsupported.
+func (m *Manager) Revert() error {
+	return errors.ErrUnsupported
+}
@@ -0,0 +1,117 @@
+// Command gendesc extracts the doc comments of the types in telio.go into a
+// Go map, so schemas can carry them without the source at runtime.
+//
+//	gendesc -o descriptions.go ../telio.go
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"log"
+	"os"
+	"sort"
+	"strings"
+)
+
+func main() {
+	out := flag.String("o", "descriptions.go", "output file")
+	pkg := flag.String("p", "schema", "package of the output file")
+	flag.Parse()
+	if flag.NArg() != 1 {
+		log.Fatal("usage: gendesc [-o file] [-p package] telio.go")
+	}
+
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, flag.Arg(0), nil, parser.ParseComments)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	descs := make(map[string]string)
+	for _, decl := range file.Decls {
+		gen, ok := decl.(*ast.GenDecl)
+		if !ok || gen.Tok != token.TYPE {
+			continue
+		}
+		for _, spec := range gen.Specs {
+			ts := spec.(*ast.TypeSpec)
+			if !ts.Name.IsExported() {
+				continue
+			}
+			doc := ts.Doc
+			if doc == nil && len(gen.Specs) == 1 {
+				doc = gen.Doc
+			}
+			if text := commentText(doc); text != "" {
+				descs[ts.Name.Name] = text
+			}
+			st, ok := ts.Type.(*ast.StructType)
+			if !ok {
+				continue
+			}
+			for _, field := range st.Fields.List {
+				text := commentText(field.Doc)
+				if text == "" {
+					continue
+				}
+				for _, name := range field.Names {
+					descs[ts.Name.Name+"."+name.Name] = text
+				}
+			}
+		}
+	}
+
+	keys := make([]string, 0, len(descs))
+	for k := range descs {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "// Code generated by gendesc from %s; DO NOT EDIT.\n\n", flag.Arg(0))
+	fmt.Fprintf(&b, "package %s\n\n", *pkg)
+	b.WriteString("// descriptions holds the doc comments of telio types and of their fields,\n")
+	b.WriteString("// keyed by \"Type\" and \"Type.Field\".\n")
+	b.WriteString("var descriptions = map[string]string{\n")
+	for _, k := range keys {
+		fmt.Fprintf(&b, "%q: %q,\n", k, descs[k])
+	}
+	b.WriteString("}\n")
+
+	src, err := format.Source(b.Bytes())
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := os.WriteFile(*out, src, 0o644); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// commentText joins the lines of a comment, keeping paragraphs apart.
+func commentText(cg *ast.CommentGroup) string {
+	if cg == nil {
+		return ""
+	}
+	var paras []string
+	var cur []string
+	for _, line := range strings.Split(cg.Text(), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			if len(cur) > 0 {
+				paras = append(paras, strings.Join(cur, " "))
+				cur = nil
+			}
+			continue
+		}
+		cur = append(cur, line)
+	}
+	if len(cur) > 0 {
+		paras = append(paras, strings.Join(cur, " "))
+	}
+	return strings.Join(paras, "\n\n")
+}
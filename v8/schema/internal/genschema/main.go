@@ -0,0 +1,32 @@
+// Command genschema writes the schemas embedded in package schema:
+//
+//	genschema -d .
+package main
+
+import (
+	"flag"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/NordSecurity/libtelio-go/v8/schema"
+	"github.com/NordSecurity/libtelio-go/v8/schema/schemagen"
+)
+
+func main() {
+	dir := flag.String("d", ".", "output directory")
+	flag.Parse()
+
+	for name, s := range map[string]*schema.Schema{
+		"features.schema.json": schemagen.FeaturesWithoutDefaults(),
+		"meshnet.schema.json":  schemagen.Config(),
+	} {
+		data, err := s.MarshalIndent()
+		if err != nil {
+			log.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(*dir, name), append(data, '\n'), 0o644); err != nil {
+			log.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,41 @@
+// Package schema describes the JSON accepted by telio.DeserializeFeatureConfig
+// and telio.DeserializeMeshnetConfig as JSON Schema (draft 2020-12), and
+// validates payloads against it.
+//
+// The schemas are generated from the Go types by package schemagen and
+// embedded here, so config servers can validate documents without linking
+// libtelio. Services editing configs can publish the output of
+// schemagen.Features and schemagen.Config, which also carry libtelio's
+// defaults, while devices check what they receive with ValidateFeatures and
+// ValidateConfig.
+package schema
+
+//go:generate go run ./internal/genschema
+
+import "encoding/json"
+
+// Draft is the JSON Schema dialect of the generated schemas.
+const Draft = "https://json-schema.org/draft/2020-12/schema"
+
+// Schema is the subset of JSON Schema used to describe telio types.
+type Schema struct {
+	Schema      string `json:"$schema,omitempty"`
+	ID          string `json:"$id,omitempty"`
+	Title       string `json:"title,omitempty"`
+	Description string `json:"description,omitempty"`
+	// A type name or a list of them
+	Type                 any                `json:"type,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	AdditionalProperties *bool              `json:"additionalProperties,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	Enum                 []any              `json:"enum,omitempty"`
+	Minimum              *int64             `json:"minimum,omitempty"`
+	Maximum              *uint64            `json:"maximum,omitempty"`
+	Default              json.RawMessage    `json:"default,omitempty"`
+}
+
+// MarshalIndent returns s as indented JSON.
+func (s *Schema) MarshalIndent() ([]byte, error) {
+	return json.MarshalIndent(s, "", "  ")
+}
@@ -0,0 +1,217 @@
+// Code generated by gendesc from ../../telio.go; DO NOT EDIT.
+
+package schemagen
+
+// descriptions holds the doc comments of telio types and of their fields,
+// keyed by "Type" and "Type.Field".
+var descriptions = map[string]string{
+	"Backoff":                                "Exponential backoff bounds",
+	"Backoff.InitialS":                       "Initial bound\n\nUsed as the first backoff value after ExponentialBackoff creation or reset [default 2s]",
+	"Backoff.MaximalS":                       "Maximal bound\n\nA maximal backoff value which might be achieved during exponential backoff - if set to None/null there will be no upper bound for the penalty duration [default 120s]",
+	"BlockedDomain":                          "Information about a domain blocked by TP-Lite",
+	"BlockedDomain.Category":                 "The category, represented by the \"authority\" from the SOA record",
+	"BlockedDomain.DomainName":               "The domain name that was blocked",
+	"BlockedDomain.Timestamp":                "When the request occurred",
+	"Config":                                 "Rust representation of [meshnet map] A network map of all the Peers and the servers",
+	"Config.DerpServers":                     "List of available derp servers",
+	"Config.Dns":                             "Dns configuration",
+	"Config.Peers":                           "List of connected peers",
+	"Config.This":                            "Description of the local peer",
+	"DnsConfig":                              "Representation of DNS configuration",
+	"DnsConfig.DnsServers":                   "List of DNS servers",
+	"DnsMetrics":                             "Simple metrics about TP-Lite DNS activity",
+	"DnsMetrics.NumCacheHits":                "Number of DNS requests that were caught by libfirewall's cache of blocked domains",
+	"DnsMetrics.NumRequests":                 "Number of DNS requests that have been made",
+	"DnsMetrics.NumResponses":                "Number of received DNS responses",
+	"DnsRedirect":                            "Pair of DNS server endpoints describing how a single DNS-redirect rule should rewrite outbound DNS traffic.",
+	"DnsRedirect.Blocking":                   "DNS server that would otherwise drop non-whitelisted queries.",
+	"DnsRedirect.Standard":                   "DNS server to which whitelisted queries are redirected.",
+	"EndpointProvider":                       "Available Endpoint Providers for meshnet direct connections",
+	"EndpointProviders":                      "* * Typealias from the type name used in the UDL file to the builtin type.  This * is needed because the UDL type name is used in function/method signatures. * It's also what we have an external type that references a custom type.",
+	"ErrorCode":                              "Error code. Common error code representation (for statistics).",
+	"ErrorEvent":                             "Error event. Used to inform the upper layer about errors in `libtelio`.",
+	"ErrorEvent.Code":                        "The error code, used to denote the type of the error",
+	"ErrorEvent.Level":                       "The level of the error",
+	"ErrorEvent.Msg":                         "A more descriptive text of the error",
+	"ErrorLevel":                             "Error levels. Used for app to decide what to do with `telio` device when error happens.",
+	"Event":                                  "Main object of `Event`. See `Event::new()` for init options.",
+	"EventError":                             "Initialize an Error type event. Used to inform errors to the upper layers of libtelio",
+	"EventNode":                              "Used to report events related to the Node",
+	"EventRelay":                             "Used to report events related to the Relay",
+	"FeatureDerp":                            "Configure derp behaviour",
+	"FeatureDerp.DerpKeepalive":              "Derp will send empty messages after this many seconds of not sending/receiving any data [default 60s]",
+	"FeatureDerp.EnablePolling":              "Enable polling of remote peer states to reduce derp traffic",
+	"FeatureDerp.PollKeepalive":              "Poll Keepalive: Application level keepalives meant to replace the TCP keepalives They will reuse the derp_keepalive interval",
+	"FeatureDerp.TcpKeepalive":               "Tcp keepalive set on derp server's side [default 15s]",
+	"FeatureDerp.UseBuiltInRootCertificates": "Use Mozilla's root certificates instead of OS ones [default false]",
+	"FeatureDirect":                          "Enable meshent direct connection",
+	"FeatureDirect.EndpointIntervalSecs":     "Polling interval for endpoints [default 25s]",
+	"FeatureDirect.EndpointProvidersOptimization":                    "Parameters to optimize battery lifetime",
+	"FeatureDirect.Providers":                                        "Endpoint providers [default all]",
+	"FeatureDirect.SkipUnresponsivePeers":                            "Configuration options for skipping unresponsive peers",
+	"FeatureDirect.UpnpFeatures":                                     "Configurable features for UPNP endpoint provider",
+	"FeatureDns":                                                     "Feature configuration for DNS",
+	"FeatureDns.ExitDns":                                             "Configure options for exit dns [default None]",
+	"FeatureDns.TtlValue":                                            "TTL for SOA record and for A and AAAA records [default 60s]",
+	"FeatureDns.UseRawForwarder":                                     "Use the raw DNS forwarder instead of the old hickory-server",
+	"FeatureEndpointProvidersOptimization":                           "Control which battery optimizations are turned on",
+	"FeatureEndpointProvidersOptimization.OptimizeDirectUpgradeStun": "Controls whether Stun endpoint provider should be turned off when there are no proxying peers",
+	"FeatureEndpointProvidersOptimization.OptimizeDirectUpgradeUpnp": "Controls whether Upnp endpoint provider should be turned off when there are no proxying peers",
+	"FeatureErrorNotificationService":                                "Configuration for the Error Notification Service",
+	"FeatureErrorNotificationService.AllowOnlyPq":                    "Allow only post-quantum safe key exchange algorithm for the ENS HTTPS connection",
+	"FeatureErrorNotificationService.Backoff":                        "Configuration of the backoff algorithm used by ENS",
+	"FeatureErrorNotificationService.BufferSize":                     "Size of the internal queue of received and to-be-published vpn error notifications",
+	"FeatureErrorNotificationService.RootCertificateOverride":        "DER encoded root certificate to be used for verification of all TLS connections to gRPC ENS endpoint in place of the hardcoded one",
+	"FeatureExitDns":                                                 "Configurable features for exit Dns",
+	"FeatureExitDns.AutoSwitchDnsIps":                                "Controls if it is allowed to reconfigure DNS peer when exit node is (dis)connected.",
+	"FeatureFirewall":                                                "Feature config for firewall",
+	"FeatureFirewall.BoringtunResetConns":                            "Turns on connection resets upon VPN server change (Deprecated alias for neptun_reset_conns)",
+	"FeatureFirewall.ExcludePrivateIpRange":                          "Ip range from RFC1918 to exclude from firewall blocking",
+	"FeatureFirewall.NeptunResetConns":                               "Turns on connection resets upon VPN server change",
+	"FeatureFirewall.OutgoingBlacklist":                              "Blackist for outgoing connections",
+	"FeatureLana":                                                    "Configurable features for Lana module",
+	"FeatureLana.EventPath":                                          "Path of the file where events will be stored. If such file does not exist, it will be created, otherwise reused",
+	"FeatureLana.Prod":                                               "Whether the events should be sent to produciton or not",
+	"FeatureLinkDetection":                                           "Link detection mechanism",
+	"FeatureLinkDetection.RttSeconds":                                "Configurable rtt in seconds",
+	"FeatureLinkDetection.UseForDowngrade":                           "Use link detection for downgrade logic",
+	"FeatureNurse":                                                   "Configurable features for Nurse module",
+	"FeatureNurse.EnableNatTraversalConnData":                        "Enable/disable NAT-traversal connections data",
+	"FeatureNurse.EnableRelayConnData":                               "Enable/disable Relay connection data",
+	"FeatureNurse.HeartbeatInterval":                                 "Heartbeat interval in seconds. Default value is 3600.",
+	"FeatureNurse.InitialHeartbeatInterval":                          "Initial heartbeat interval in seconds. Default value is None.",
+	"FeatureNurse.Qos":                                               "QoS configuration for Nurse",
+	"FeatureNurse.StateDurationCap":                                  "How long a session can exist before it is forcibly reported, in seconds. Default value is 24h.",
+	"FeaturePaths":                                                   "Enable wanted paths for telio",
+	"FeaturePaths.Force":                                             "Force only one specific path to be used.",
+	"FeaturePaths.Priority":                                          "Enable paths in increasing priority: 0 is worse then 1 is worse then 2 ... [PathType::Relay] always assumed as -1",
+	"FeaturePersistentKeepalive":                                     "Configurable persistent keepalive periods for different types of peers",
+	"FeaturePersistentKeepalive.Direct":                              "Persistent keepalive period for direct peers (in seconds) [default 5s]",
+	"FeaturePersistentKeepalive.Proxying":                            "Persistent keepalive period for proxying peers (in seconds) [default 25s]",
+	"FeaturePersistentKeepalive.Stun":                                "Persistent keepalive period for stun peers (in seconds) [default 25s]",
+	"FeaturePersistentKeepalive.Vpn":                                 "Persistent keepalive period given for VPN peers (in seconds) [default 15s]",
+	"FeaturePolling":                                                 "Configurable WireGuard polling periods",
+	"FeaturePolling.WireguardPollingPeriod":                          "Wireguard state polling period (in milliseconds) [default 1000ms]",
+	"FeaturePolling.WireguardPollingPeriodAfterStateChange":          "Wireguard state polling period after state change (in milliseconds) [default 50ms]",
+	"FeaturePostQuantumVpn":                                          "Turns on post quantum VPN tunnel",
+	"FeaturePostQuantumVpn.HandshakeRetryIntervalS":                  "Initial handshake retry interval in seconds",
+	"FeaturePostQuantumVpn.RekeyIntervalS":                           "Rekey interval in seconds",
+	"FeaturePostQuantumVpn.Version":                                  "Post-quantum protocol version",
+	"FeatureQoS":                                                     "QoS configuration options",
+	"FeatureQoS.Buckets":                                             "Number of buckets used for rtt and throughput. Default value is 5.",
+	"FeatureQoS.RttInterval":                                         "How often to collect rtt data in seconds. Default value is 300.",
+	"FeatureQoS.RttTries":                                            "Number of tries for each node. Default value is 3.",
+	"FeatureQoS.RttTypes":                                            "Types of rtt analytics. Default is Ping.",
+	"FeatureSkipUnresponsivePeers":                                   "Avoid sending periodic messages to peers with no traffic reported by wireguard",
+	"FeatureSkipUnresponsivePeers.NoRxThresholdSecs":                 "Time after which peers is considered unresponsive if it didn't receive any packets",
+	"FeatureUpnp":                                                    "Configurable features for UPNP endpoint provider",
+	"FeatureUpnp.LeaseDurationS":                                     "The upnp lease_duration parameter, in seconds. A value of 0 is infinite.",
+	"FeatureValidateKeys":                                            "* * Typealias from the type name used in the UDL file to the builtin type.  This * is needed because the UDL type name is used in function/method signatures. * It's also what we have an external type that references a custom type.",
+	"FeatureWireguard":                                               "Configurable features for Wireguard peers",
+	"FeatureWireguard.EnableDynamicWgNtControl":                      "Configurable up/down behavior of WireGuard-NT adapter. See RFC LLT-0089 for details",
+	"FeatureWireguard.InterThreadChannelSize":                        "Configurable socket buffer size for NepTUN",
+	"FeatureWireguard.MaxInterThreadBatchedPkts":                     "Configurable socket buffer size for NepTUN",
+	"FeatureWireguard.PersistentKeepalive":                           "Configurable persistent keepalive periods for wireguard peers",
+	"FeatureWireguard.Polling":                                       "Configurable WireGuard polling periods",
+	"FeatureWireguard.SktBufferSize":                                 "Configurable socket buffer size for NepTUN",
+	"Features":                                                       "Encompasses all of the possible features that can be enabled",
+	"Features.Derp":                                                  "Derp server specific configuration",
+	"Features.Direct":                                                "Configure options for direct WG connections",
+	"Features.Dns":                                                   "Feature configuration for DNS",
+	"Features.Firewall":                                              "Feature config for firewall. When null, the firewall is disabled.",
+	"Features.FlushEventsOnStopTimeoutSeconds":                       "If and for how long to flush events when stopping telio. Setting to Some(0) means waiting until all events have been flushed, regardless of how long it takes",
+	"Features.HideThreadId":                                          "Control if thread IDs should be shown in the logs",
+	"Features.HideUserData":                                          "Control if IP addresses and domains should be hidden in logs",
+	"Features.Ipv6":                                                  "IPv6 support",
+	"Features.IsTestEnv":                                             "Should only be set for macos sideload",
+	"Features.Lana":                                                  "Event logging configurable features",
+	"Features.LinkDetection":                                         "Link detection mechanism",
+	"Features.Multicast":                                             "Multicast support",
+	"Features.Nicknames":                                             "Nicknames support",
+	"Features.Nurse":                                                 "Nurse features that can be configured for QoS",
+	"Features.Paths":                                                 "Deprecated by direct since 4.0.0",
+	"Features.PostQuantumVpn":                                        "Post quantum VPN tunnel configuration",
+	"Features.ValidateKeys":                                          "Flag to specify if keys should be validated",
+	"Features.Wireguard":                                             "Additional wireguard configuration",
+	"FirewallBlacklistTuple":                                         "Tuple used to blacklist outgoing connections in Telio firewall",
+	"FirewallBlacklistTuple.Ip":                                      "Destination IP address of the packet",
+	"FirewallBlacklistTuple.Port":                                    "Destination port of the packet",
+	"FirewallBlacklistTuple.Protocol":                                "Protocol of the packet to be blacklisted",
+	"GoRustBuffer":                                                   "This is needed, because as of go 1.24 type RustBuffer C.RustBuffer cannot have methods, RustBuffer is treated as non-local type",
+	"HiddenString":                                                   "* * Typealias from the type name used in the UDL file to the builtin type.  This * is needed because the UDL type name is used in function/method signatures. * It's also what we have an external type that references a custom type.",
+	"IpAddr":                                                         "* * Typealias from the type name used in the UDL file to the builtin type.  This * is needed because the UDL type name is used in function/method signatures. * It's also what we have an external type that references a custom type.",
+	"IpNet":                                                          "* * Typealias from the type name used in the UDL file to the builtin type.  This * is needed because the UDL type name is used in function/method signatures. * It's also what we have an external type that references a custom type.",
+	"IpProtocol":                                                     "Next layer protocol for IP packet",
+	"Ipv4Addr":                                                       "* * Typealias from the type name used in the UDL file to the builtin type.  This * is needed because the UDL type name is used in function/method signatures. * It's also what we have an external type that references a custom type.",
+	"Ipv4Net":                                                        "* * Typealias from the type name used in the UDL file to the builtin type.  This * is needed because the UDL type name is used in function/method signatures. * It's also what we have an external type that references a custom type.",
+	"LinkState":                                                      "Link state hint",
+	"NatType":                                                        "Available NAT types",
+	"NodeState":                                                      "Connection state of the node",
+	"PathType":                                                       "Mesh connection path type",
+	"Peer":                                                           "Description of a peer",
+	"Peer.AllowIncomingConnections":                                  "Flag to control whether the peer allows incoming connections",
+	"Peer.AllowMulticast":                                            "Flag to control whether we allow multicast messages from the peer",
+	"Peer.AllowPeerLocalNetworkAccess":                               "Flag to control whether the Node allows incoming local area access",
+	"Peer.AllowPeerSendFiles":                                        "Flag to control whether the peer allows incoming files",
+	"Peer.AllowPeerTrafficRouting":                                   "Flag to control whether the Node allows routing through",
+	"Peer.Base":                                                      "The base object describing a peer",
+	"Peer.IsLocal":                                                   "The peer is local, when the flag is set",
+	"Peer.PeerAllowsMulticast":                                       "Flag to control whether the peer allows multicast messages from us",
+	"PeerBase":                                                       "Characterstics describing a peer",
+	"PeerBase.Hostname":                                              "Hostname of the peer",
+	"PeerBase.Identifier":                                            "32-character identifier of the peer",
+	"PeerBase.IpAddresses":                                           "Ip address of peer",
+	"PeerBase.Nickname":                                              "Nickname for the peer",
+	"PeerBase.PublicKey":                                             "Public key of the peer",
+	"PublicKey":                                                      "* * Typealias from the type name used in the UDL file to the builtin type.  This * is needed because the UDL type name is used in function/method signatures. * It's also what we have an external type that references a custom type.",
+	"RelayState":                                                     "The currrent state of our connection to derp server",
+	"RttType":                                                        "Available ways to calculate RTT",
+	"SecretKey":                                                      "* * Typealias from the type name used in the UDL file to the builtin type.  This * is needed because the UDL type name is used in function/method signatures. * It's also what we have an external type that references a custom type.",
+	"Server":                                                         "Representation of a server, which might be used both as a Relay server and Stun Server",
+	"Server.ConnState":                                               "Status of the connection with the server",
+	"Server.Hostname":                                                "Hostname of the server",
+	"Server.Ipv4":                                                    "IP address of the server",
+	"Server.Name":                                                    "Short name for the server",
+	"Server.PublicKey":                                               "Server public key",
+	"Server.RegionCode":                                              "Server region code",
+	"Server.RelayPort":                                               "Port on which server listens to relay requests",
+	"Server.StunPlaintextPort":                                       "Port on which server listens for unencrypted stun requests",
+	"Server.StunPort":                                                "Port on which server listens to stun requests",
+	"Server.UsePlainText":                                            "When enabled the connection to servers is not encrypted",
+	"Server.Weight":                                                  "Determines in which order the client tries to connect to the derp servers",
+	"SocketAddr":                                                     "* * Typealias from the type name used in the UDL file to the builtin type.  This * is needed because the UDL type name is used in function/method signatures. * It's also what we have an external type that references a custom type.",
+	"SocketAddrV4":                                                   "* * Typealias from the type name used in the UDL file to the builtin type.  This * is needed because the UDL type name is used in function/method signatures. * It's also what we have an external type that references a custom type.",
+	"TelioAdapterType":                                               "Possible adapters.",
+	"TelioErrorUnknownError":                                         "Variant structs",
+	"TelioLogLevel":                                                  "Possible log levels.",
+	"TelioNode":                                                      "Description of a Node",
+	"TelioNode.AllowIncomingConnections":                             "Flag to control whether the Node allows incoming connections",
+	"TelioNode.AllowMulticast":                                       "Flag to control whether we allow multicast messages from the Node",
+	"TelioNode.AllowPeerLocalNetworkAccess":                          "Flag to control whether the Node allows incoming local area access",
+	"TelioNode.AllowPeerSendFiles":                                   "Flag to control whether the Node allows incoming files",
+	"TelioNode.AllowPeerTrafficRouting":                              "Flag to control whether the Node allows routing through",
+	"TelioNode.AllowedIps":                                           "List of IP's which can connect to the node",
+	"TelioNode.Endpoint":                                             "Endpoint used by node",
+	"TelioNode.Hostname":                                             "Hostname of the node",
+	"TelioNode.Identifier":                                           "An identifier for a node Makes it possible to distinguish different nodes in the presence of key reuse",
+	"TelioNode.IpAddresses":                                          "IP addresses of the node",
+	"TelioNode.IsExit":                                               "Is the node exit node",
+	"TelioNode.IsVpn":                                                "Is the node is a vpn server.",
+	"TelioNode.LinkState":                                            "Link state hint (Down, Up)",
+	"TelioNode.Nickname":                                             "Nickname for the peer",
+	"TelioNode.Path":                                                 "Connection type in the network mesh (through Relay or hole punched directly)",
+	"TelioNode.PeerAllowsMulticast":                                  "Flag to control whether the Node allows multicast messages from us",
+	"TelioNode.PublicKey":                                            "Public key of the Node",
+	"TelioNode.State":                                                "State of the node (Connecting, connected, or disconnected)",
+	"TelioNode.VpnConnectionError":                                   "Configuration for the Error Notification Service",
+	"TpLiteStatsCallback":                                            "A callback for getting TP-Lite stats from libfirewall",
+	"TpLiteStatsOptions":                                             "Config options for the collection of TP-Lite stats",
+	"TpLiteStatsOptions.BlockedDomainsBufferSize":                    "How many blocked domains libfirewall can store between passing them through the callback If the buffer fills up and new blocked domains arrive, data will be lost\n\nDefault value: 100",
+	"TpLiteStatsOptions.CacheSize":                                   "libfirewall disables OS/client-level caching of blocked domains when stats collection is enabled To not make extra DNS requests libfirewall has it's own DNS cache for blocked domains\n\nHow many entries the libfirewall-specific DNS cache can hold\n\nDefault value: 512",
+	"TpLiteStatsOptions.CallbackIntervalS":                           "After how long stats will be passed to the callback, in seconds\n\nDefault value: 5",
+	"TpLiteStatsOptions.DnsServerIps":                                "The IP addresses of the TP-Lite DNS servers",
+	"TpLiteStatsOptions.ForcePlaintextDns":                           "The stats collection can only operate on plaintext DNS packets Setting this flag will block DoT and DoH packets, causing the client to fallback to plaintext Note: Some clients can be configured with no plaintext fallback, which would then break if this flag is set\n\nDefault value: false",
+	"TpLiteStatsOptions.MaxOpenRequests":                             "When TP-Lite stats collection is enabled libfirewall keeps track of open DNS requests\n\nHow many requests libfirewall can keep track of\n\nDefault value: same as blocked_domains_buffer_size",
+	"TtlValue":                                                       "* * Typealias from the type name used in the UDL file to the builtin type.  This * is needed because the UDL type name is used in function/method signatures. * It's also what we have an external type that references a custom type.",
+	"VpnConnectionError":                                             "Possible VPN errors received from the Error Notification Service",
+}
@@ -0,0 +1,248 @@
+// Package schemagen derives the JSON Schemas of package schema from the Go
+// types, carrying their doc comments as descriptions, libtelio's defaults and
+// the names of enum values. Unlike package schema it links libtelio.
+package schemagen
+
+//go:generate go run ../internal/gendesc -o descriptions.go -p schemagen ../../telio.go
+
+import (
+	"encoding"
+	"encoding/json"
+	"math"
+	"reflect"
+
+	telio "github.com/NordSecurity/libtelio-go/v8"
+	"github.com/NordSecurity/libtelio-go/v8/schema"
+)
+
+var (
+	textMarshaler   = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+	textUnmarshaler = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+// flattened fields have their properties inlined into the enclosing object,
+// like serde(flatten) does in libtelio.
+var flattened = map[string]bool{
+	"Config.This": true,
+	"Peer.Base":   true,
+}
+
+// reportOnly fields are set by libtelio and optional in meshnet configs.
+var reportOnly = map[string]bool{
+	"Server.ConnState": true,
+}
+
+// Features returns the schema of telio.Features with the defaults of
+// telio.GetDefaultFeatureConfig. The fields of sections absent by default
+// carry the defaults they get when enabled with telio.FeaturesDefaultsBuilder.
+func Features() *schema.Schema {
+	b := telio.NewFeaturesDefaultsBuilder()
+	for _, enable := range []func(*telio.FeaturesDefaultsBuilder) *telio.FeaturesDefaultsBuilder{
+		(*telio.FeaturesDefaultsBuilder).EnableNurse,
+		(*telio.FeaturesDefaultsBuilder).EnableDirect,
+		(*telio.FeaturesDefaultsBuilder).EnableLinkDetection,
+		(*telio.FeaturesDefaultsBuilder).EnableErrorNotificationService,
+		(*telio.FeaturesDefaultsBuilder).EnableFlushEventsOnStopTimeoutSeconds,
+	} {
+		next := enable(b)
+		b.Destroy()
+		b = next
+	}
+	defer b.Destroy()
+	return FeaturesWithDefaults(telio.GetDefaultFeatureConfig(), b.Build())
+}
+
+// FeaturesWithoutDefaults returns the schema of telio.Features without
+// defaults. It does not call into libtelio and is the one embedded in package
+// schema for validation.
+func FeaturesWithoutDefaults() *schema.Schema {
+	return features(reflect.Value{}, reflect.Value{})
+}
+
+// FeaturesWithDefaults returns the schema of telio.Features with defaults.
+// Fields of optional sections which are nil in defaults take their defaults
+// from enabled instead.
+func FeaturesWithDefaults(defaults, enabled telio.Features) *schema.Schema {
+	return features(reflect.ValueOf(defaults), reflect.ValueOf(enabled))
+}
+
+func features(defaults, enabled reflect.Value) *schema.Schema {
+	g := generator{}
+	s := g.schema(reflect.TypeOf(telio.Features{}), "", defaults, enabled)
+	s.Schema = schema.Draft
+	s.Title = "Features"
+	return s
+}
+
+// Config returns the schema of the meshnet config, telio.Config. Fields which
+// are not optional in the Go types are required, except for booleans.
+func Config() *schema.Schema {
+	g := generator{config: true}
+	s := g.schema(reflect.TypeOf(telio.Config{}), "", reflect.Value{}, reflect.Value{})
+	s.Schema = schema.Draft
+	s.Title = "Config"
+	return s
+}
+
+type generator struct {
+	// Generating the meshnet config, which has required fields
+	config bool
+}
+
+// schema describes t. The description is taken from field, "Type.Field", or
+// from the type. def is the default of this location, alt the default used
+// when def is a nil pointer; both may be invalid.
+func (g *generator) schema(t reflect.Type, field string, def, alt reflect.Value) *schema.Schema {
+	var s *schema.Schema
+	switch {
+	case t.Kind() == reflect.Pointer:
+		inner, innerAlt := reflect.Value{}, reflect.Value{}
+		switch {
+		case def.IsValid() && !def.IsNil():
+			inner = def.Elem()
+			if alt.IsValid() && !alt.IsNil() {
+				innerAlt = alt.Elem()
+			}
+		case alt.IsValid() && !alt.IsNil():
+			inner = alt.Elem()
+		}
+		s = g.schema(t.Elem(), field, inner, innerAlt)
+		nullable(s)
+		if def.IsValid() && def.IsNil() {
+			s.Default = json.RawMessage("null")
+		}
+		return s
+	case t.Kind() == reflect.Struct:
+		s = g.object(t, def, alt)
+	case isEnum(t):
+		s = &schema.Schema{Type: "string", Enum: enumValues(t)}
+	case t.Kind() == reflect.Bool:
+		s = &schema.Schema{Type: "boolean"}
+	case t.Kind() == reflect.String:
+		s = &schema.Schema{Type: "string"}
+	case t.Kind() >= reflect.Int && t.Kind() <= reflect.Uint64:
+		s = integer(t)
+	case t.Kind() == reflect.Slice:
+		// Nested defaults are the default of the whole array
+		s = &schema.Schema{Type: "array", Items: g.schema(t.Elem(), "", reflect.Value{}, reflect.Value{})}
+	default:
+		panic("schema: unsupported type " + t.String())
+	}
+
+	if desc := descriptions[field]; desc != "" {
+		s.Description = desc
+	} else if desc := descriptions[t.Name()]; desc != "" && t.PkgPath() != "" {
+		s.Description = desc
+	}
+	if def.IsValid() && t.Kind() != reflect.Struct {
+		s.Default = defaultJSON(def)
+	}
+	return s
+}
+
+// object describes a struct, with the defaults of its fields taken from def
+// and alt.
+func (g *generator) object(t reflect.Type, def, alt reflect.Value) *schema.Schema {
+	no := false
+	s := &schema.Schema{Type: "object", Properties: make(map[string]*schema.Schema), AdditionalProperties: &no}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		name := t.Name() + "." + f.Name
+		var fdef, falt reflect.Value
+		if def.IsValid() {
+			fdef = def.Field(i)
+		}
+		if alt.IsValid() {
+			falt = alt.Field(i)
+		}
+		fs := g.schema(f.Type, name, fdef, falt)
+
+		if flattened[name] {
+			for k, v := range fs.Properties {
+				s.Properties[k] = v
+			}
+			s.Required = append(s.Required, fs.Required...)
+			continue
+		}
+		key := telio.JSONName(f)
+		s.Properties[key] = fs
+		if g.config && f.Type.Kind() != reflect.Pointer && f.Type.Kind() != reflect.Bool && !reportOnly[name] {
+			s.Required = append(s.Required, key)
+		}
+	}
+	return s
+}
+
+// nullable allows null in place of the value described by s.
+func nullable(s *schema.Schema) {
+	if t, ok := s.Type.(string); ok {
+		s.Type = []string{t, "null"}
+	}
+	if s.Enum != nil {
+		s.Enum = append(s.Enum, nil)
+	}
+}
+
+func isEnum(t reflect.Type) bool {
+	return t.Kind() == reflect.Uint && t.Implements(textMarshaler) && reflect.PointerTo(t).Implements(textUnmarshaler)
+}
+
+// enumValues lists the names of an enum, whose values start at 1.
+func enumValues(t reflect.Type) []any {
+	var values []any
+	for i := uint64(1); ; i++ {
+		v := reflect.New(t).Elem()
+		v.SetUint(i)
+		text, err := v.Interface().(encoding.TextMarshaler).MarshalText()
+		if err != nil {
+			return values
+		}
+		values = append(values, string(text))
+	}
+}
+
+func integer(t reflect.Type) *schema.Schema {
+	s := &schema.Schema{Type: "integer"}
+	bits := t.Bits()
+	if t.Kind() >= reflect.Uint {
+		zero := int64(0)
+		s.Minimum = &zero
+		if bits < 64 {
+			max := uint64(1)<<bits - 1
+			s.Maximum = &max
+		}
+		return s
+	}
+	if bits < 64 {
+		min, max := int64(-1)<<(bits-1), uint64(1)<<(bits-1)-1
+		s.Minimum, s.Maximum = &min, &max
+	} else {
+		min, max := int64(math.MinInt64), uint64(math.MaxInt64)
+		s.Minimum, s.Maximum = &min, &max
+	}
+	return s
+}
+
+// defaultJSON encodes a default the way libtelio does.
+func defaultJSON(v reflect.Value) json.RawMessage {
+	if v.Kind() == reflect.Slice && v.IsNil() {
+		return json.RawMessage("[]")
+	}
+	x := v.Interface()
+	// encoding/json writes byte slices as base64, libtelio as numbers
+	if b, ok := x.([]byte); ok {
+		ints := make([]int, len(b))
+		for i := range b {
+			ints[i] = int(b[i])
+		}
+		x = ints
+	}
+	data, err := json.Marshal(x)
+	if err != nil {
+		return nil
+	}
+	return data
+}
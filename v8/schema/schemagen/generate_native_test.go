@@ -0,0 +1,25 @@
+//go:build native
+
+// Run against the real libtelio with: go test -tags native ./schema/schemagen
+
+package schemagen
+
+import (
+	"testing"
+
+	telio "github.com/NordSecurity/libtelio-go/v8"
+	"github.com/NordSecurity/libtelio-go/v8/schema"
+)
+
+func TestDefaultsValid(t *testing.T) {
+	data, err := telio.SerializeFeatureConfig(telio.GetDefaultFeatureConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := schema.ValidateFeatures([]byte(data)); err != nil {
+		t.Fatalf("embedded schema rejects libtelio's defaults: %v\n%s", err, data)
+	}
+	if err := Features().Validate([]byte(data)); err != nil {
+		t.Fatalf("generated schema rejects libtelio's defaults: %v\n%s", err, data)
+	}
+}
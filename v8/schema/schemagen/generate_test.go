@@ -0,0 +1,29 @@
+package schemagen
+
+import (
+	"bytes"
+	"os"
+	"testing"
+
+	"github.com/NordSecurity/libtelio-go/v8/schema"
+)
+
+// The schemas embedded in package schema must match the Go types.
+func TestEmbeddedUpToDate(t *testing.T) {
+	for file, s := range map[string]*schema.Schema{
+		"../features.schema.json": FeaturesWithoutDefaults(),
+		"../meshnet.schema.json":  Config(),
+	} {
+		want, err := s.MarshalIndent()
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, append(want, '\n')) {
+			t.Errorf("%s is out of date, run go generate in package schema", file)
+		}
+	}
+}
@@ -0,0 +1,210 @@
+package schema
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ValidationError is a violation of a schema.
+type ValidationError struct {
+	// JSON pointer to the offending value, "" for the document
+	Path string
+	Msg  string
+}
+
+func (e *ValidationError) Error() string {
+	path := e.Path
+	if path == "" {
+		path = "/"
+	}
+	return path + ": " + e.Msg
+}
+
+var (
+	//go:embed features.schema.json
+	featuresJSON []byte
+	//go:embed meshnet.schema.json
+	configJSON []byte
+
+	featuresSchema = sync.OnceValue(func() *Schema { return mustParse(featuresJSON) })
+	configSchema   = sync.OnceValue(func() *Schema { return mustParse(configJSON) })
+)
+
+func mustParse(data []byte) *Schema {
+	var s Schema
+	if err := json.Unmarshal(data, &s); err != nil {
+		panic("schema: parsing embedded schema: " + err.Error())
+	}
+	return &s
+}
+
+// ValidateFeatures checks a features JSON document against the schema of
+// telio.Features in features.schema.json.
+func ValidateFeatures(data []byte) error {
+	return featuresSchema().Validate(data)
+}
+
+// ValidateConfig checks a meshnet config JSON document against the schema of
+// telio.Config in meshnet.schema.json.
+func ValidateConfig(data []byte) error {
+	return configSchema().Validate(data)
+}
+
+// Validate checks the JSON document data against s. It returns every
+// violation found as a *ValidationError, joined with errors.Join.
+func (s *Schema) Validate(data []byte) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return fmt.Errorf("parsing JSON: %w", err)
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return errors.New("parsing JSON: data after the document")
+	}
+	var errs []error
+	s.validate("", v, &errs)
+	return errors.Join(errs...)
+}
+
+func (s *Schema) validate(path string, v any, errs *[]error) {
+	fail := func(format string, args ...any) {
+		*errs = append(*errs, &ValidationError{Path: path, Msg: fmt.Sprintf(format, args...)})
+	}
+
+	if !s.typeMatches(v) {
+		fail("expected %s, got %s", strings.Join(s.types(), " or "), jsonType(v))
+		return
+	}
+	if s.Enum != nil && !s.inEnum(v) {
+		fail("%s is not one of %s", formatValue(v), formatEnum(s.Enum))
+	}
+
+	switch v := v.(type) {
+	case json.Number:
+		n, _, _ := big.ParseFloat(string(v), 10, 256, big.ToNearestEven)
+		if s.Minimum != nil && n.Cmp(new(big.Float).SetInt64(*s.Minimum)) < 0 {
+			fail("%s is less than %d", v, *s.Minimum)
+		}
+		if s.Maximum != nil && n.Cmp(new(big.Float).SetUint64(*s.Maximum)) > 0 {
+			fail("%s is greater than %d", v, *s.Maximum)
+		}
+	case []any:
+		if s.Items != nil {
+			for i, item := range v {
+				s.Items.validate(path+"/"+strconv.Itoa(i), item, errs)
+			}
+		}
+	case map[string]any:
+		for _, name := range s.Required {
+			if _, ok := v[name]; !ok {
+				fail("missing required property %q", name)
+			}
+		}
+		names := make([]string, 0, len(v))
+		for name := range v {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			sub := path + "/" + escapePointer(name)
+			if ps, ok := s.Properties[name]; ok {
+				ps.validate(sub, v[name], errs)
+			} else if s.AdditionalProperties != nil && !*s.AdditionalProperties {
+				*errs = append(*errs, &ValidationError{Path: sub, Msg: "unknown property"})
+			}
+		}
+	}
+}
+
+func (s *Schema) types() []string {
+	switch t := s.Type.(type) {
+	case string:
+		return []string{t}
+	case []string:
+		return t
+	case []any:
+		// As decoded from JSON
+		types := make([]string, 0, len(t))
+		for _, x := range t {
+			if x, ok := x.(string); ok {
+				types = append(types, x)
+			}
+		}
+		return types
+	}
+	return nil
+}
+
+func (s *Schema) typeMatches(v any) bool {
+	types := s.types()
+	if types == nil {
+		return true
+	}
+	actual := jsonType(v)
+	for _, t := range types {
+		if t == actual || t == "number" && actual == "integer" {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Schema) inEnum(v any) bool {
+	for _, e := range s.Enum {
+		if e == v {
+			return true
+		}
+	}
+	return false
+}
+
+// jsonType returns the JSON Schema type of a decoded value. Numbers without
+// a fractional part are integers, as in draft 2020-12.
+func jsonType(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case string:
+		return "string"
+	case json.Number:
+		if n, _, err := big.ParseFloat(string(v), 10, 256, big.ToNearestEven); err == nil && n.IsInt() {
+			return "integer"
+		}
+		return "number"
+	case []any:
+		return "array"
+	case map[string]any:
+		return "object"
+	}
+	return fmt.Sprintf("%T", v)
+}
+
+func formatValue(v any) string {
+	data, _ := json.Marshal(v)
+	return string(data)
+}
+
+func formatEnum(enum []any) string {
+	values := make([]string, len(enum))
+	for i, e := range enum {
+		values[i] = formatValue(e)
+	}
+	return "[" + strings.Join(values, ", ") + "]"
+}
+
+// escapePointer escapes a property name for a JSON pointer.
+func escapePointer(name string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(name, "~", "~0"), "/", "~1")
+}
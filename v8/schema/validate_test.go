@@ -0,0 +1,71 @@
+package schema
+
+import (
+	"errors"
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+func TestValidateFeatures(t *testing.T) {
+	valid := `{
+		"wireguard": {"persistent_keepalive": {"vpn": 25, "direct": 5, "proxying": 25, "stun": 50}},
+		"paths": {"priority": ["relay", "direct"], "force": null},
+		"direct": {"providers": ["local", "stun"]},
+		"lana": null,
+		"ipv6": true,
+		"firewall": {"outgoing_blacklist": [{"protocol": "udp", "ip": "10.0.0.1", "port": 53}]},
+		"error_notification_service": {"buffer_size": 5, "root_certificate_override": [48, 130, 1]}
+	}`
+	if err := ValidateFeatures([]byte(valid)); err != nil {
+		t.Fatal(err)
+	}
+
+	for doc, path := range map[string]string{
+		`{"direct": {"providers": ["carrier_pigeon"]}}`: "/direct/providers/0",
+		`{"ipv6": "yes"}`:                                                      "/ipv6",
+		`{"no_such_feature": true}`:                                            "/no_such_feature",
+		`{"wireguard": {"persistent_keepalive": {"vpn": -1}}}`:                 "/wireguard/persistent_keepalive/vpn",
+		`{"error_notification_service": {"root_certificate_override": [256]}}`: "/error_notification_service/root_certificate_override/0",
+	} {
+		err := ValidateFeatures([]byte(doc))
+		var verr *ValidationError
+		if !errors.As(err, &verr) || verr.Path != path {
+			t.Errorf("%s: got %v, want an error at %s", doc, err, path)
+		}
+	}
+}
+
+func TestValidateConfig(t *testing.T) {
+	valid := `{
+		"identifier": "this", "public_key": "key", "hostname": "this.nord",
+		"peers": [{"identifier": "peer", "public_key": "peer-key", "hostname": "peer.nord", "is_local": true}],
+		"derp_servers": null,
+		"dns": {"dns_servers": ["100.64.0.2"]}
+	}`
+	if err := ValidateConfig([]byte(valid)); err != nil {
+		t.Fatal(err)
+	}
+	err := ValidateConfig([]byte(`{"identifier": "this", "hostname": "this.nord", "peers": [{}]}`))
+	if err == nil || !strings.Contains(err.Error(), `/: missing required property "public_key"`) ||
+		!strings.Contains(err.Error(), `/peers/0: missing required property "hostname"`) {
+		t.Fatalf("got %v, want missing properties reported", err)
+	}
+}
+
+// The package must stay usable by config servers which do not link libtelio.
+func TestNoLibtelio(t *testing.T) {
+	gocmd, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("go command not found")
+	}
+	out, err := exec.Command(gocmd, "list", "-deps", ".").Output()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, pkg := range strings.Fields(string(out)) {
+		if pkg == "github.com/NordSecurity/libtelio-go/v8" || pkg == "runtime/cgo" {
+			t.Fatalf("schema depends on %s", pkg)
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package sleepwake
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/godbus/dbus/v5"
+)
+
+const (
+	logindDest      = "org.freedesktop.login1"
+	logindPath      = "/org/freedesktop/login1"
+	logindInterface = "org.freedesktop.login1.Manager"
+)
+
+// Logind is the part of systemd-logind used by a Monitor. It is implemented
+// over the system bus by ConnectLogind and may be faked in tests.
+type Logind interface {
+	// Inhibit takes a delay inhibitor lock on sleep, released by closing it
+	Inhibit(who, why string) (io.Closer, error)
+	// PrepareForSleep delivers the argument of every PrepareForSleep signal
+	// until ctx is done
+	PrepareForSleep(ctx context.Context) (<-chan bool, error)
+}
+
+// LogindBus implements Logind over D-Bus.
+type LogindBus struct {
+	conn *dbus.Conn
+}
+
+// ConnectLogind connects to systemd-logind on the system bus.
+func ConnectLogind() (*LogindBus, error) {
+	conn, err := dbus.ConnectSystemBus()
+	if err != nil {
+		return nil, fmt.Errorf("connecting to the system bus: %w", err)
+	}
+	var owner string
+	err = conn.BusObject().Call("org.freedesktop.DBus.GetNameOwner", 0, logindDest).Store(&owner)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("logind is not running: %w", err)
+	}
+	return NewLogindBus(conn), nil
+}
+
+// NewLogindBus talks to logind over conn, e.g. a connection to a private bus
+// running a fake logind.
+func NewLogindBus(conn *dbus.Conn) *LogindBus {
+	return &LogindBus{conn: conn}
+}
+
+// Close closes the bus connection.
+func (l *LogindBus) Close() error {
+	return l.conn.Close()
+}
+
+// Inhibit implements Logind.
+func (l *LogindBus) Inhibit(who, why string) (io.Closer, error) {
+	var fd dbus.UnixFD
+	err := l.conn.Object(logindDest, logindPath).
+		Call(logindInterface+".Inhibit", 0, "sleep", who, why, "delay").
+		Store(&fd)
+	if err != nil {
+		return nil, fmt.Errorf("taking inhibitor lock: %w", err)
+	}
+	return os.NewFile(uintptr(fd), "logind-inhibitor"), nil
+}
+
+// PrepareForSleep implements Logind.
+func (l *LogindBus) PrepareForSleep(ctx context.Context) (<-chan bool, error) {
+	match := []dbus.MatchOption{
+		dbus.WithMatchObjectPath(logindPath),
+		dbus.WithMatchInterface(logindInterface),
+		dbus.WithMatchMember("PrepareForSleep"),
+	}
+	if err := l.conn.AddMatchSignal(match...); err != nil {
+		return nil, fmt.Errorf("subscribing to PrepareForSleep: %w", err)
+	}
+	signals := make(chan *dbus.Signal, 8)
+	l.conn.Signal(signals)
+
+	out := make(chan bool)
+	go func() {
+		defer close(out)
+		defer l.conn.RemoveSignal(signals)
+		defer l.conn.RemoveMatchSignal(match...)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case s, ok := <-signals:
+				if !ok {
+					return
+				}
+				if s.Name != logindInterface+".PrepareForSleep" || len(s.Body) != 1 {
+					continue
+				}
+				start, ok := s.Body[0].(bool)
+				if !ok {
+					continue
+				}
+				select {
+				case out <- start:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+	return out, nil
+}
@@ -0,0 +1,180 @@
+package sleepwake
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+)
+
+const busConfig = `<!DOCTYPE busconfig PUBLIC "-//freedesktop//DTD D-Bus Bus Configuration 1.0//EN"
+ "http://www.freedesktop.org/standards/dbus/1.0/busconfig.dtd">
+<busconfig>
+  <type>session</type>
+  <listen>unix:dir=DIR</listen>
+  <auth>EXTERNAL</auth>
+  <policy context="default">
+    <allow send_destination="*" eavesdrop="true"/>
+    <allow eavesdrop="true"/>
+    <allow own="*"/>
+  </policy>
+</busconfig>
+`
+
+// privateBus starts a dbus-daemon for the test and returns its address.
+func privateBus(t *testing.T) string {
+	t.Helper()
+	daemon, err := exec.LookPath("dbus-daemon")
+	if err != nil {
+		t.Skip("dbus-daemon is not installed")
+	}
+	dir := t.TempDir()
+	config := filepath.Join(dir, "bus.conf")
+	if err := os.WriteFile(config, []byte(strings.ReplaceAll(busConfig, "DIR", dir)), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	cmd := exec.Command(daemon, "--config-file="+config, "--nofork", "--print-address")
+	out, err := cmd.StdoutPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		cmd.Process.Kill()
+		cmd.Wait()
+	})
+	addr, err := bufio.NewReader(out).ReadString('\n')
+	if err != nil {
+		t.Fatalf("reading the bus address: %v", err)
+	}
+	return strings.TrimSpace(addr)
+}
+
+func connect(t *testing.T, addr string) *dbus.Conn {
+	t.Helper()
+	conn, err := dbus.Connect(addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+// pipeLock is an inhibitor lock handed out by busLogind. Its read end sees
+// EOF once every copy of the write end is closed.
+type pipeLock struct {
+	r, w *os.File
+}
+
+// busLogind serves the logind methods used by LogindBus, handing out the
+// write end of a pipe as the inhibitor lock.
+type busLogind struct {
+	conn  *dbus.Conn
+	locks chan pipeLock
+}
+
+func (l *busLogind) Inhibit(what, who, why, mode string) (dbus.UnixFD, *dbus.Error) {
+	if what != "sleep" || mode != "delay" {
+		return 0, dbus.MakeFailedError(errors.New("unexpected lock " + what + " " + mode))
+	}
+	r, w, err := os.Pipe()
+	if err != nil {
+		return 0, dbus.MakeFailedError(err)
+	}
+	fd := dbus.UnixFD(w.Fd())
+	l.locks <- pipeLock{r: r, w: w}
+	return fd, nil
+}
+
+func (l *busLogind) prepareForSleep(start bool) error {
+	return l.conn.Emit(logindPath, logindInterface+".PrepareForSleep", start)
+}
+
+// startLogind registers a fake logind on the bus at addr.
+func startLogind(t *testing.T, addr string) *busLogind {
+	t.Helper()
+	conn := connect(t, addr)
+	l := &busLogind{conn: conn, locks: make(chan pipeLock, 4)}
+	if err := conn.Export(l, logindPath, logindInterface); err != nil {
+		t.Fatal(err)
+	}
+	reply, err := conn.RequestName(logindDest, dbus.NameFlagDoNotQueue)
+	if err != nil || reply != dbus.RequestNameReplyPrimaryOwner {
+		t.Fatalf("owning %s: %v", logindDest, err)
+	}
+	return l
+}
+
+// released reports whether the client closed its copy of the lock. It must
+// be called after the reply carrying the lock was sent.
+func released(lock pipeLock) bool {
+	lock.w.Close()
+	done := make(chan struct{})
+	go func() {
+		var b [1]byte
+		lock.r.Read(b[:])
+		close(done)
+	}()
+	select {
+	case <-done:
+		return true
+	case <-time.After(5 * time.Second):
+		return false
+	}
+}
+
+func nextLock(t *testing.T, l *busLogind) pipeLock {
+	t.Helper()
+	select {
+	case lock := <-l.locks:
+		t.Cleanup(func() {
+			lock.r.Close()
+			lock.w.Close()
+		})
+		return lock
+	case <-time.After(5 * time.Second):
+		t.Fatal("no inhibitor lock taken")
+		return pipeLock{}
+	}
+}
+
+func TestLogindBus(t *testing.T) {
+	addr := privateBus(t)
+	fake := startLogind(t, addr)
+
+	n := newFakeNotifier()
+	m := NewMonitor(n, NewLogindBus(connect(t, addr)), Options{})
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- m.Run(ctx) }()
+
+	lock := nextLock(t, fake)
+	if err := fake.prepareForSleep(true); err != nil {
+		t.Fatal(err)
+	}
+	n.wait(t, "sleep")
+	if !released(lock) {
+		t.Fatal("inhibitor lock kept while going to sleep")
+	}
+
+	if err := fake.prepareForSleep(false); err != nil {
+		t.Fatal(err)
+	}
+	n.wait(t, "wakeup")
+	lock = nextLock(t, fake)
+
+	cancel()
+	<-done
+	if !released(lock) {
+		t.Fatal("inhibitor lock kept after Run returned")
+	}
+}
@@ -0,0 +1,159 @@
+// Package sleepwake tells libtelio when the host suspends and resumes, so
+// connections are re-established right after wakeup instead of timing out.
+//
+// On systems running systemd-logind the Monitor delays suspend until
+// telio.NotifySleep returned. Elsewhere it detects resumes from jumps of the
+// wall clock relative to the monotonic clock, which stops while suspended.
+package sleepwake
+
+import (
+	"context"
+	"errors"
+	"io"
+	"time"
+)
+
+const (
+	defaultWho           = "libtelio"
+	defaultWhy           = "Preparing VPN connections for sleep"
+	defaultCheckInterval = 5 * time.Second
+	defaultJumpThreshold = 10 * time.Second
+)
+
+// Notifier is the part of telio.TelioInterface used by a Monitor.
+type Notifier interface {
+	NotifySleep() error
+	NotifyWakeup() error
+}
+
+// Options configure a Monitor.
+type Options struct {
+	// Who and why of the inhibitor lock shown by systemd-inhibit
+	// [default "libtelio" and "Preparing VPN connections for sleep"]
+	Who, Why string
+	// How often the clocks are compared without logind [default 5s]
+	CheckInterval time.Duration
+	// How far the wall clock must run ahead of the monotonic clock to be
+	// considered a resume [default 10s]
+	JumpThreshold time.Duration
+	// Called when notifying libtelio or logind fails
+	OnError func(error)
+}
+
+// Monitor notifies libtelio of suspend and resume.
+type Monitor struct {
+	notifier Notifier
+	logind   Logind
+	opts     Options
+	now      func() time.Time
+}
+
+// NewMonitor creates a monitor notifying n. With a nil logind it only detects
+// resumes from clock jumps.
+func NewMonitor(n Notifier, logind Logind, opts Options) *Monitor {
+	if opts.Who == "" {
+		opts.Who = defaultWho
+	}
+	if opts.Why == "" {
+		opts.Why = defaultWhy
+	}
+	if opts.CheckInterval <= 0 {
+		opts.CheckInterval = defaultCheckInterval
+	}
+	if opts.JumpThreshold <= 0 {
+		opts.JumpThreshold = defaultJumpThreshold
+	}
+	return &Monitor{notifier: n, logind: logind, opts: opts, now: time.Now}
+}
+
+// Run notifies libtelio until ctx is done. When the logind subscription ends
+// early, Run falls back to clock jump detection.
+func (m *Monitor) Run(ctx context.Context) error {
+	if m.logind != nil {
+		err := m.runLogind(ctx)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		m.report(err)
+	}
+	return m.runClock(ctx)
+}
+
+func (m *Monitor) runLogind(ctx context.Context) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	signals, err := m.logind.PrepareForSleep(ctx)
+	if err != nil {
+		return err
+	}
+
+	lock := m.inhibit()
+	defer func() {
+		if lock != nil {
+			lock.Close()
+		}
+	}()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case sleeping, ok := <-signals:
+			if !ok {
+				return errors.New("logind signal subscription closed")
+			}
+			if sleeping {
+				m.report(m.notifier.NotifySleep())
+				// Let the system suspend
+				if lock != nil {
+					lock.Close()
+					lock = nil
+				}
+				continue
+			}
+			m.report(m.notifier.NotifyWakeup())
+			if lock == nil {
+				lock = m.inhibit()
+			}
+		}
+	}
+}
+
+func (m *Monitor) inhibit() io.Closer {
+	lock, err := m.logind.Inhibit(m.opts.Who, m.opts.Why)
+	if err != nil {
+		// Still notify, only without delaying the suspend
+		m.report(err)
+		return nil
+	}
+	return lock
+}
+
+// runClock calls NotifyWakeup when the wall clock advanced more than the
+// monotonic clock, which does not count time spent suspended.
+func (m *Monitor) runClock(ctx context.Context) error {
+	ticker := time.NewTicker(m.opts.CheckInterval)
+	defer ticker.Stop()
+
+	last := m.now()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+			now := m.now()
+			mono := now.Sub(last)
+			wall := now.Round(0).Sub(last.Round(0))
+			last = now
+			if wall-mono > m.opts.JumpThreshold {
+				m.report(m.notifier.NotifyWakeup())
+			}
+		}
+	}
+}
+
+func (m *Monitor) report(err error) {
+	if err != nil && m.opts.OnError != nil {
+		m.opts.OnError(err)
+	}
+}
@@ -0,0 +1,171 @@
+package sleepwake
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeNotifier records the notifications in order.
+type fakeNotifier struct {
+	mu    sync.Mutex
+	calls []string
+	// Receives every notification
+	notified chan string
+}
+
+func newFakeNotifier() *fakeNotifier {
+	return &fakeNotifier{notified: make(chan string, 16)}
+}
+
+func (n *fakeNotifier) NotifySleep() error  { return n.record("sleep") }
+func (n *fakeNotifier) NotifyWakeup() error { return n.record("wakeup") }
+
+func (n *fakeNotifier) record(call string) error {
+	n.mu.Lock()
+	n.calls = append(n.calls, call)
+	n.mu.Unlock()
+	n.notified <- call
+	return nil
+}
+
+func (n *fakeNotifier) wait(t *testing.T, want string) {
+	t.Helper()
+	select {
+	case got := <-n.notified:
+		if got != want {
+			t.Fatalf("got %s, want %s", got, want)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("no %s notification", want)
+	}
+}
+
+// fakeLock is an inhibitor lock of fakeLogind.
+type fakeLock struct {
+	l *fakeLogind
+}
+
+func (f fakeLock) Close() error {
+	f.l.mu.Lock()
+	defer f.l.mu.Unlock()
+	f.l.held--
+	return nil
+}
+
+// fakeLogind is an in-memory Logind.
+type fakeLogind struct {
+	mu      sync.Mutex
+	held    int
+	taken   int
+	failErr error
+	signals chan bool
+}
+
+func (l *fakeLogind) Inhibit(who, why string) (io.Closer, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.failErr != nil {
+		return nil, l.failErr
+	}
+	l.held++
+	l.taken++
+	return fakeLock{l: l}, nil
+}
+
+func (l *fakeLogind) PrepareForSleep(ctx context.Context) (<-chan bool, error) {
+	return l.signals, nil
+}
+
+func (l *fakeLogind) locks() (held, taken int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.held, l.taken
+}
+
+func TestMonitorLogind(t *testing.T) {
+	n := newFakeNotifier()
+	l := &fakeLogind{signals: make(chan bool)}
+	m := NewMonitor(n, l, Options{})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- m.Run(ctx) }()
+
+	l.signals <- true
+	n.wait(t, "sleep")
+	// The signal channel is unbuffered, so the lock taken at start is
+	// released before the wakeup signal is received
+	l.signals <- false
+	n.wait(t, "wakeup")
+	if held, _ := l.locks(); held > 1 {
+		t.Fatalf("%d locks held after wakeup", held)
+	}
+
+	cancel()
+	if err := <-done; !errors.Is(err, context.Canceled) {
+		t.Fatalf("Run returned %v", err)
+	}
+	if held, taken := l.locks(); held != 0 || taken != 2 {
+		t.Fatalf("%d locks held and %d taken after Run returned, want 0 and 2", held, taken)
+	}
+}
+
+func TestMonitorInhibitFailure(t *testing.T) {
+	n := newFakeNotifier()
+	l := &fakeLogind{signals: make(chan bool), failErr: errors.New("access denied")}
+	var mu sync.Mutex
+	var errs []error
+	m := NewMonitor(n, l, Options{OnError: func(err error) {
+		mu.Lock()
+		errs = append(errs, err)
+		mu.Unlock()
+	}})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go m.Run(ctx)
+
+	// Notifications are still sent without the lock
+	l.signals <- true
+	n.wait(t, "sleep")
+	l.signals <- false
+	n.wait(t, "wakeup")
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(errs) == 0 {
+		t.Fatal("inhibit failure not reported")
+	}
+}
+
+func TestMonitorSubscriptionClosed(t *testing.T) {
+	l := &fakeLogind{signals: make(chan bool)}
+	reported := make(chan error, 1)
+	m := NewMonitor(newFakeNotifier(), l, Options{OnError: func(err error) { reported <- err }})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- m.Run(ctx) }()
+	close(l.signals)
+
+	select {
+	case <-reported:
+	case <-time.After(5 * time.Second):
+		t.Fatal("closed subscription not reported")
+	}
+	// Run keeps going with clock jump detection
+	select {
+	case err := <-done:
+		t.Fatalf("Run returned %v", err)
+	case <-time.After(20 * time.Millisecond):
+	}
+	cancel()
+	<-done
+	if held, _ := l.locks(); held != 0 {
+		t.Fatalf("%d locks held after the subscription closed", held)
+	}
+}
@@ -0,0 +1,82 @@
+package sysdns
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+
+	"github.com/NordSecurity/libtelio-go/v8/internal/atomicfile"
+)
+
+const (
+	resolvConfPath = "/etc/resolv.conf"
+	backupSuffix   = ".telio-backup"
+	fileHeader     = "# Generated by libtelio, the original is kept in "
+)
+
+// File replaces a resolv.conf file, keeping the original next to it until
+// Revert puts it back. A backup left by a crashed process is never
+// overwritten, so Revert restores the configuration from before the first
+// Apply.
+type File struct {
+	path string
+}
+
+// NewFile creates a backend managing the resolv.conf file at path.
+func NewFile(path string) *File {
+	return &File{path: path}
+}
+
+// Name implements Backend.
+func (f *File) Name() string {
+	return "resolv.conf"
+}
+
+func (f *File) backup() string {
+	return f.path + backupSuffix
+}
+
+// Apply implements Backend.
+func (f *File) Apply(cfg Config) error {
+	servers, err := cfg.servers()
+	if err != nil {
+		return err
+	}
+
+	if _, err := os.Lstat(f.backup()); errors.Is(err, fs.ErrNotExist) {
+		// Renaming keeps a symlink, e.g. to a resolver stub, intact
+		if err := os.Rename(f.path, f.backup()); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("backing up %s: %w", f.path, err)
+		}
+	} else if err != nil {
+		return err
+	}
+
+	var b bytes.Buffer
+	b.WriteString(fileHeader + f.backup() + "\n")
+	for _, s := range servers {
+		fmt.Fprintf(&b, "nameserver %s\n", s)
+	}
+	if len(cfg.Domains) > 0 {
+		b.WriteString("search")
+		for _, d := range cfg.Domains {
+			b.WriteString(" " + d)
+		}
+		b.WriteString("\n")
+	}
+	return atomicfile.WriteFile(f.path, b.Bytes(), 0o644)
+}
+
+// Revert implements Backend.
+func (f *File) Revert(cfg Config) error {
+	err := os.Rename(f.backup(), f.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("restoring %s: %w", f.path, err)
+	}
+	return nil
+}
@@ -0,0 +1,63 @@
+package sysdns
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+)
+
+// Resolvconf registers the magic DNS servers with resolvconf under the name
+// of the tunnel interface. resolvconf has no notion of routing domains, so
+// Config.Domains become search domains and every query goes to magic DNS.
+type Resolvconf struct {
+	path string
+	// Runs resolvconf with args and stdin, exec.Command when nil
+	Run func(path string, args []string, stdin []byte) error
+}
+
+// NewResolvconf creates a backend running the resolvconf binary at path.
+func NewResolvconf(path string) *Resolvconf {
+	return &Resolvconf{path: path}
+}
+
+// Name implements Backend.
+func (r *Resolvconf) Name() string {
+	return "resolvconf"
+}
+
+// Apply implements Backend.
+func (r *Resolvconf) Apply(cfg Config) error {
+	servers, err := cfg.servers()
+	if err != nil {
+		return err
+	}
+	var b bytes.Buffer
+	for _, s := range servers {
+		fmt.Fprintf(&b, "nameserver %s\n", s)
+	}
+	if len(cfg.Domains) > 0 {
+		b.WriteString("search")
+		for _, d := range cfg.Domains {
+			b.WriteString(" " + d)
+		}
+		b.WriteString("\n")
+	}
+	return r.run([]string{"-a", cfg.Interface}, b.Bytes())
+}
+
+// Revert implements Backend.
+func (r *Resolvconf) Revert(cfg Config) error {
+	return r.run([]string{"-d", cfg.Interface, "-f"}, nil)
+}
+
+func (r *Resolvconf) run(args []string, stdin []byte) error {
+	if r.Run != nil {
+		return r.Run(r.path, args, stdin)
+	}
+	cmd := exec.Command(r.path, args...)
+	cmd.Stdin = bytes.NewReader(stdin)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("%s %v: %w: %s", r.path, args, err, bytes.TrimSpace(out))
+	}
+	return nil
+}
@@ -0,0 +1,141 @@
+package sysdns
+
+import (
+	"fmt"
+	"net"
+
+	"github.com/godbus/dbus/v5"
+)
+
+const (
+	resolvedDest   = "org.freedesktop.resolve1"
+	resolvedPath   = "/org/freedesktop/resolve1"
+	resolvedPrefix = "org.freedesktop.resolve1.Manager."
+
+	// Address families on Linux, where resolved runs
+	afInet  = 2
+	afInet6 = 10
+)
+
+// Caller invokes D-Bus methods. It is implemented on top of a dbus.BusObject
+// by NewResolved and may be faked in tests.
+type Caller interface {
+	Call(method string, args ...any) error
+}
+
+type busCaller struct {
+	obj dbus.BusObject
+}
+
+func (b busCaller) Call(method string, args ...any) error {
+	return b.obj.Call(method, 0, args...).Err
+}
+
+// Resolved configures systemd-resolved over D-Bus. The tunnel gets its own
+// DNS servers and routing domains, so only names under Config.Domains are
+// resolved by magic DNS unless Config.DefaultRoute is set.
+type Resolved struct {
+	bus Caller
+	// Returns the index of an interface, net.InterfaceByName when nil
+	IfIndex func(name string) (int, error)
+}
+
+// NewResolved connects to systemd-resolved on the system bus.
+func NewResolved() (*Resolved, error) {
+	conn, err := dbus.SystemBus()
+	if err != nil {
+		return nil, fmt.Errorf("connecting to the system bus: %w", err)
+	}
+	return NewResolvedWith(busCaller{obj: conn.Object(resolvedDest, resolvedPath)}), nil
+}
+
+// NewResolvedWith creates a backend calling the resolve1 Manager methods
+// through bus.
+func NewResolvedWith(bus Caller) *Resolved {
+	return &Resolved{bus: bus}
+}
+
+// Name implements Backend.
+func (r *Resolved) Name() string {
+	return "systemd-resolved"
+}
+
+type resolvedAddress struct {
+	Family  int32
+	Address []byte
+}
+
+type resolvedDomain struct {
+	Domain      string
+	RoutingOnly bool
+}
+
+// Apply implements Backend.
+func (r *Resolved) Apply(cfg Config) error {
+	index, err := r.ifIndex(cfg.Interface)
+	if err != nil {
+		return err
+	}
+	servers, err := cfg.servers()
+	if err != nil {
+		return err
+	}
+
+	addrs := make([]resolvedAddress, len(servers))
+	for i, s := range servers {
+		family := int32(afInet6)
+		if s.Is4() {
+			family = afInet
+		}
+		addrs[i] = resolvedAddress{Family: family, Address: s.AsSlice()}
+	}
+	domains := make([]resolvedDomain, len(cfg.Domains))
+	for i, d := range cfg.Domains {
+		domains[i] = resolvedDomain{Domain: d, RoutingOnly: true}
+	}
+	if cfg.DefaultRoute {
+		domains = append(domains, resolvedDomain{Domain: ".", RoutingOnly: true})
+	}
+
+	if err := r.bus.Call(resolvedPrefix+"SetLinkDNS", index, addrs); err != nil {
+		return fmt.Errorf("setting DNS servers: %w", err)
+	}
+	if err := r.bus.Call(resolvedPrefix+"SetLinkDomains", index, domains); err != nil {
+		return fmt.Errorf("setting DNS domains: %w", err)
+	}
+	if err := r.bus.Call(resolvedPrefix+"SetLinkDefaultRoute", index, cfg.DefaultRoute); err != nil {
+		return fmt.Errorf("setting DNS default route: %w", err)
+	}
+	return nil
+}
+
+// Revert implements Backend. Nothing needs to be done when the interface is
+// gone, resolved forgets its configuration with it.
+func (r *Resolved) Revert(cfg Config) error {
+	index, err := r.ifIndex(cfg.Interface)
+	if err != nil {
+		return nil
+	}
+	if err := r.bus.Call(resolvedPrefix+"RevertLink", index); err != nil {
+		return fmt.Errorf("reverting link: %w", err)
+	}
+	return nil
+}
+
+func (r *Resolved) ifIndex(name string) (int32, error) {
+	lookup := r.IfIndex
+	if lookup == nil {
+		lookup = func(name string) (int, error) {
+			iface, err := net.InterfaceByName(name)
+			if err != nil {
+				return 0, err
+			}
+			return iface.Index, nil
+		}
+	}
+	index, err := lookup(name)
+	if err != nil {
+		return 0, fmt.Errorf("looking up %s: %w", name, err)
+	}
+	return int32(index), nil
+}
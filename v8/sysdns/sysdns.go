@@ -0,0 +1,148 @@
+// Package sysdns points the Linux system resolver at libtelio's magic DNS.
+//
+// telio.EnableMagicDns starts the resolver inside the tunnel, but the system
+// keeps using its own DNS servers until told otherwise. A Configurator
+// enables magic DNS and configures the system through one of the Backends:
+// systemd-resolved, resolvconf or /etc/resolv.conf itself.
+package sysdns
+
+import (
+	"errors"
+	"fmt"
+	"net/netip"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	telio "github.com/NordSecurity/libtelio-go/v8"
+)
+
+// DefaultServer is the address of libtelio's magic DNS resolver.
+const DefaultServer = "100.64.0.2"
+
+// Config is the DNS configuration of the tunnel interface.
+type Config struct {
+	// Name of the tunnel interface
+	Interface string
+	// Addresses of the magic DNS resolver [default DefaultServer]
+	Servers []telio.IpAddr
+	// Domains resolved through the tunnel. Backends supporting split DNS
+	// route only these domains to Servers, others replace the system
+	// resolvers [default "meshnet"]
+	Domains []string
+	// Send every query to Servers, also with backends supporting split DNS
+	DefaultRoute bool
+}
+
+func (c *Config) setDefaults() error {
+	if c.Interface == "" {
+		return fmt.Errorf("interface is required")
+	}
+	if len(c.Servers) == 0 {
+		c.Servers = []telio.IpAddr{DefaultServer}
+	}
+	if len(c.Domains) == 0 {
+		c.Domains = []string{"meshnet"}
+	}
+	c.Domains = append([]string(nil), c.Domains...)
+	for i, d := range c.Domains {
+		c.Domains[i] = strings.TrimSuffix(strings.TrimPrefix(d, "~"), ".")
+	}
+	return nil
+}
+
+func (c *Config) servers() ([]netip.Addr, error) {
+	out := make([]netip.Addr, 0, len(c.Servers))
+	for _, s := range c.Servers {
+		addr, err := netip.ParseAddr(s)
+		if err != nil {
+			return nil, fmt.Errorf("DNS server %q: %w", s, err)
+		}
+		out = append(out, addr.Unmap())
+	}
+	return out, nil
+}
+
+// Backend configures the system resolver.
+type Backend interface {
+	// Name of the backend for logs
+	Name() string
+	// Apply makes the system use the configured servers, it may be called
+	// again to update the configuration
+	Apply(cfg Config) error
+	// Revert restores the configuration from before Apply
+	Revert(cfg Config) error
+}
+
+// Detect returns the backend managing the resolver of this system:
+// systemd-resolved when /etc/resolv.conf points to its stub, resolvconf when
+// installed and the file backend otherwise.
+func Detect() (Backend, error) {
+	if target, err := filepath.EvalSymlinks(resolvConfPath); err == nil && strings.HasPrefix(target, "/run/systemd/resolve/") {
+		return NewResolved()
+	}
+	if path, err := exec.LookPath("resolvconf"); err == nil {
+		return NewResolvconf(path), nil
+	}
+	if _, err := os.Stat(resolvConfPath); err != nil {
+		return nil, fmt.Errorf("no DNS backend found: %w", err)
+	}
+	return NewFile(resolvConfPath), nil
+}
+
+// MagicDNS is the part of telio.TelioInterface used by a Configurator.
+type MagicDNS interface {
+	EnableMagicDns(forwardServers []telio.IpAddr) error
+	DisableMagicDns() error
+}
+
+// Configurator enables magic DNS together with the system configuration.
+type Configurator struct {
+	dns     MagicDNS
+	backend Backend
+	cfg     Config
+}
+
+// NewConfigurator creates a configurator applying cfg through backend.
+func NewConfigurator(dns MagicDNS, backend Backend, cfg Config) (*Configurator, error) {
+	if err := cfg.setDefaults(); err != nil {
+		return nil, err
+	}
+	if _, err := cfg.servers(); err != nil {
+		return nil, err
+	}
+	return &Configurator{dns: dns, backend: backend, cfg: cfg}, nil
+}
+
+// Enable starts magic DNS forwarding to forwardServers and points the system
+// resolver at it. Magic DNS is disabled again when the system cannot be
+// configured.
+func (c *Configurator) Enable(forwardServers []telio.IpAddr) error {
+	if err := c.dns.EnableMagicDns(forwardServers); err != nil {
+		return err
+	}
+	if err := c.backend.Apply(c.cfg); err != nil {
+		_ = c.dns.DisableMagicDns()
+		return fmt.Errorf("configuring DNS with %s: %w", c.backend.Name(), err)
+	}
+	return nil
+}
+
+// Disable restores the system resolver and stops magic DNS.
+func (c *Configurator) Disable() error {
+	var errs []error
+	if err := c.backend.Revert(c.cfg); err != nil {
+		errs = append(errs, fmt.Errorf("reverting DNS with %s: %w", c.backend.Name(), err))
+	}
+	if err := c.dns.DisableMagicDns(); err != nil {
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
+
+// Recover restores a configuration left behind by a previous process,
+// without touching libtelio.
+func (c *Configurator) Recover() error {
+	return c.backend.Revert(c.cfg)
+}
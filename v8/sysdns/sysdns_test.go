@@ -0,0 +1,224 @@
+package sysdns
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	telio "github.com/NordSecurity/libtelio-go/v8"
+	"github.com/godbus/dbus/v5"
+)
+
+type call struct {
+	method string
+	args   []any
+}
+
+// fakeBus records the calls made to resolved and fails the ones in fail.
+type fakeBus struct {
+	calls []call
+	fail  map[string]error
+}
+
+func (b *fakeBus) Call(method string, args ...any) error {
+	b.calls = append(b.calls, call{method: method, args: args})
+	return b.fail[method]
+}
+
+func (b *fakeBus) methods() []string {
+	var out []string
+	for _, c := range b.calls {
+		out = append(out, strings.TrimPrefix(c.method, resolvedPrefix))
+	}
+	return out
+}
+
+func ifIndex(name string) (int, error) {
+	if name != "nlx" {
+		return 0, errors.New("no such interface")
+	}
+	return 7, nil
+}
+
+func TestResolvedApply(t *testing.T) {
+	bus := &fakeBus{}
+	r := NewResolvedWith(bus)
+	r.IfIndex = ifIndex
+	cfg := Config{Interface: "nlx", Servers: []telio.IpAddr{"100.64.0.2", "fd74:656c:696f::2"}, Domains: []string{"~meshnet."}}
+	if err := cfg.setDefaults(); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Apply(cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := bus.methods(); !reflect.DeepEqual(got, []string{"SetLinkDNS", "SetLinkDomains", "SetLinkDefaultRoute"}) {
+		t.Fatalf("calls %v", got)
+	}
+	// The signatures of the resolve1 Manager methods
+	for i, want := range []string{"ia(iay)", "ia(sb)", "ib"} {
+		if sig := dbus.SignatureOf(bus.calls[i].args...).String(); sig != want {
+			t.Errorf("%s signature %s, want %s", bus.calls[i].method, sig, want)
+		}
+	}
+	addrs := bus.calls[0].args[1].([]resolvedAddress)
+	if addrs[0].Family != afInet || len(addrs[0].Address) != 4 || addrs[1].Family != afInet6 || len(addrs[1].Address) != 16 {
+		t.Errorf("addresses %+v", addrs)
+	}
+	if domains := bus.calls[1].args[1]; !reflect.DeepEqual(domains, []resolvedDomain{{Domain: "meshnet", RoutingOnly: true}}) {
+		t.Errorf("domains %+v", domains)
+	}
+	if bus.calls[0].args[0] != int32(7) || bus.calls[2].args[1] != false {
+		t.Errorf("calls %+v", bus.calls)
+	}
+}
+
+func TestResolvedDefaultRoute(t *testing.T) {
+	bus := &fakeBus{}
+	r := NewResolvedWith(bus)
+	r.IfIndex = ifIndex
+	cfg := Config{Interface: "nlx", DefaultRoute: true}
+	if err := cfg.setDefaults(); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Apply(cfg); err != nil {
+		t.Fatal(err)
+	}
+	domains := bus.calls[1].args[1].([]resolvedDomain)
+	if domains[len(domains)-1].Domain != "." || bus.calls[2].args[1] != true {
+		t.Fatalf("calls %+v", bus.calls)
+	}
+}
+
+func TestResolvedErrors(t *testing.T) {
+	bus := &fakeBus{fail: map[string]error{resolvedPrefix + "SetLinkDomains": errors.New("access denied")}}
+	r := NewResolvedWith(bus)
+	r.IfIndex = ifIndex
+	cfg := Config{Interface: "nlx"}
+	if err := cfg.setDefaults(); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Apply(cfg); err == nil || !strings.Contains(err.Error(), "access denied") {
+		t.Fatalf("got %v", err)
+	}
+	if err := r.Revert(cfg); err != nil {
+		t.Fatal(err)
+	}
+	if got := bus.methods(); got[len(got)-1] != "RevertLink" {
+		t.Fatalf("calls %v", got)
+	}
+
+	// The interface is gone, and with it the configuration
+	bus.calls = nil
+	if err := r.Revert(Config{Interface: "gone"}); err != nil || len(bus.calls) != 0 {
+		t.Fatalf("revert of a missing interface: %v, calls %v", err, bus.methods())
+	}
+}
+
+type fakeMagicDNS struct {
+	enabled bool
+}
+
+func (d *fakeMagicDNS) EnableMagicDns([]telio.IpAddr) error {
+	d.enabled = true
+	return nil
+}
+
+func (d *fakeMagicDNS) DisableMagicDns() error {
+	d.enabled = false
+	return nil
+}
+
+func TestConfigurator(t *testing.T) {
+	dns := &fakeMagicDNS{}
+	bus := &fakeBus{}
+	r := NewResolvedWith(bus)
+	r.IfIndex = ifIndex
+	c, err := NewConfigurator(dns, r, Config{Interface: "nlx"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Enable(nil); err != nil || !dns.enabled {
+		t.Fatalf("enable: %v, magic DNS enabled %v", err, dns.enabled)
+	}
+	if err := c.Disable(); err != nil || dns.enabled {
+		t.Fatalf("disable: %v, magic DNS enabled %v", err, dns.enabled)
+	}
+
+	bus.fail = map[string]error{resolvedPrefix + "SetLinkDNS": errors.New("no resolved")}
+	if err := c.Enable(nil); err == nil || dns.enabled {
+		t.Fatalf("enable: %v, magic DNS left enabled %v", err, dns.enabled)
+	}
+}
+
+func TestFileApplyRevert(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "resolv.conf")
+	stub := filepath.Join(dir, "stub-resolv.conf")
+	if err := os.WriteFile(stub, []byte("nameserver 127.0.0.53\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(stub, path); err != nil {
+		t.Fatal(err)
+	}
+
+	f := NewFile(path)
+	cfg := Config{Interface: "nlx"}
+	if err := cfg.setDefaults(); err != nil {
+		t.Fatal(err)
+	}
+	// Applying twice, or after a crash, keeps the original backup
+	for i := 0; i < 2; i++ {
+		if err := f.Apply(cfg); err != nil {
+			t.Fatal(err)
+		}
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), "nameserver 100.64.0.2\nsearch meshnet\n") {
+		t.Fatalf("resolv.conf:\n%s", data)
+	}
+
+	if err := f.Revert(cfg); err != nil {
+		t.Fatal(err)
+	}
+	if target, err := os.Readlink(path); err != nil || target != stub {
+		t.Fatalf("symlink not restored: %q, %v", target, err)
+	}
+	if err := f.Revert(cfg); err != nil {
+		t.Fatalf("second revert: %v", err)
+	}
+}
+
+func TestResolvconf(t *testing.T) {
+	var runs [][]string
+	var input string
+	r := NewResolvconf("/sbin/resolvconf")
+	r.Run = func(path string, args []string, stdin []byte) error {
+		runs = append(runs, append([]string{path}, args...))
+		input = string(stdin)
+		return nil
+	}
+	cfg := Config{Interface: "nlx", Domains: []string{"meshnet", "example.com"}}
+	if err := cfg.setDefaults(); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Apply(cfg); err != nil {
+		t.Fatal(err)
+	}
+	if input != "nameserver 100.64.0.2\nsearch meshnet example.com\n" {
+		t.Fatalf("stdin %q", input)
+	}
+	if err := r.Revert(cfg); err != nil {
+		t.Fatal(err)
+	}
+	want := [][]string{{"/sbin/resolvconf", "-a", "nlx"}, {"/sbin/resolvconf", "-d", "nlx", "-f"}}
+	if !reflect.DeepEqual(runs, want) {
+		t.Fatalf("runs %v, want %v", runs, want)
+	}
+}
@@ -111,27 +111,89 @@ type BufWriter[GoType any] interface {
 	Write(writer io.Writer, value GoType)
 }
 
-func LowerIntoRustBuffer[GoType any](bufWriter BufWriter[GoType], value GoType) C.RustBuffer {
-	// This might be not the most efficient way but it does not require knowing allocation size
-	// beforehand
-	var buffer bytes.Buffer
-	bufWriter.Write(&buffer, value)
+// rustBufWriter collects the encoding of a lowered value. The write helpers
+// append to it directly instead of going through binary.Write.
+type rustBufWriter struct {
+	buf []byte
+}
 
-	bytes, err := io.ReadAll(&buffer)
-	if err != nil {
-		panic(fmt.Errorf("reading written data: %w", err))
+func (w *rustBufWriter) Write(p []byte) (int, error) {
+	w.buf = append(w.buf, p...)
+	return len(p), nil
+}
+
+func (w *rustBufWriter) WriteString(s string) (int, error) {
+	w.buf = append(w.buf, s...)
+	return len(s), nil
+}
+
+const (
+	rustBufWriterInitialSize = 1024
+	// Larger buffers are left to the garbage collector
+	rustBufWriterMaxPooledSize = 1 << 20
+)
+
+// Writers keep their capacity, so after the first large value, like a
+// meshnet config with many peers, the following ones are written without
+// growing the buffer.
+var rustBufWriterPool = sync.Pool{
+	New: func() any {
+		return &rustBufWriter{buf: make([]byte, 0, rustBufWriterInitialSize)}
+	},
+}
+
+// rustBufReader decodes a RustBuffer in place. The read helpers take their
+// bytes straight from its slice instead of going through binary.Read.
+type rustBufReader struct {
+	buf []byte
+	pos int
+}
+
+func (r *rustBufReader) Read(p []byte) (int, error) {
+	if r.pos >= len(r.buf) {
+		if len(p) == 0 {
+			return 0, nil
+		}
+		return 0, io.EOF
 	}
-	return bytesToRustBuffer(bytes)
+	n := copy(p, r.buf[r.pos:])
+	r.pos += n
+	return n, nil
+}
+
+// Len returns the number of unread bytes.
+func (r *rustBufReader) Len() int {
+	return len(r.buf) - r.pos
+}
+
+// next returns the next n bytes without copying them.
+func (r *rustBufReader) next(n int) []byte {
+	if n < 0 || n > r.Len() {
+		panic(io.ErrUnexpectedEOF)
+	}
+	b := r.buf[r.pos : r.pos+n : r.pos+n]
+	r.pos += n
+	return b
+}
+
+func LowerIntoRustBuffer[GoType any](bufWriter BufWriter[GoType], value GoType) C.RustBuffer {
+	writer := rustBufWriterPool.Get().(*rustBufWriter)
+	bufWriter.Write(writer, value)
+	rbuf := bytesToRustBuffer(writer.buf)
+	if cap(writer.buf) <= rustBufWriterMaxPooledSize {
+		writer.buf = writer.buf[:0]
+		rustBufWriterPool.Put(writer)
+	}
+	return rbuf
 }
 
 func LiftFromRustBuffer[GoType any](bufReader BufReader[GoType], rbuf RustBufferI) GoType {
 	defer rbuf.Free()
-	reader := rbuf.AsReader()
+	reader := &rustBufReader{buf: unsafe.Slice((*byte)(rbuf.Data()), rbuf.Len())}
 	item := bufReader.Read(reader)
 	if reader.Len() > 0 {
 		// TODO: Remove this
-		leftover, _ := io.ReadAll(reader)
-		panic(fmt.Errorf("Junk remaining in buffer after lifting: %s", string(leftover)))
+		panic(fmt.Errorf("Junk remaining in buffer after lifting: %s", string(reader.next(reader.Len()))))
 	}
 	return item
 }
@@ -201,144 +263,134 @@ type NativeError interface {
 
 
 func writeInt8(writer io.Writer, value int8) {
-	if err := binary.Write(writer, binary.BigEndian, value); err != nil {
-		panic(err)
-	}
+	writeUint8(writer, uint8(value))
 }
 
 func writeUint8(writer io.Writer, value uint8) {
-	if err := binary.Write(writer, binary.BigEndian, value); err != nil {
-		panic(err)
+	if w, ok := writer.(*rustBufWriter); ok {
+		w.buf = append(w.buf, value)
+		return
 	}
+	writeFull(writer, []byte{value})
 }
 
 func writeInt16(writer io.Writer, value int16) {
-	if err := binary.Write(writer, binary.BigEndian, value); err != nil {
-		panic(err)
-	}
+	writeUint16(writer, uint16(value))
 }
 
 func writeUint16(writer io.Writer, value uint16) {
-	if err := binary.Write(writer, binary.BigEndian, value); err != nil {
-		panic(err)
+	if w, ok := writer.(*rustBufWriter); ok {
+		w.buf = binary.BigEndian.AppendUint16(w.buf, value)
+		return
 	}
+	b := make([]byte, 2)
+	binary.BigEndian.PutUint16(b, value)
+	writeFull(writer, b)
 }
 
 func writeInt32(writer io.Writer, value int32) {
-	if err := binary.Write(writer, binary.BigEndian, value); err != nil {
-		panic(err)
-	}
+	writeUint32(writer, uint32(value))
 }
 
 func writeUint32(writer io.Writer, value uint32) {
-	if err := binary.Write(writer, binary.BigEndian, value); err != nil {
-		panic(err)
+	if w, ok := writer.(*rustBufWriter); ok {
+		w.buf = binary.BigEndian.AppendUint32(w.buf, value)
+		return
 	}
+	b := make([]byte, 4)
+	binary.BigEndian.PutUint32(b, value)
+	writeFull(writer, b)
 }
 
 func writeInt64(writer io.Writer, value int64) {
-	if err := binary.Write(writer, binary.BigEndian, value); err != nil {
-		panic(err)
-	}
+	writeUint64(writer, uint64(value))
 }
 
 func writeUint64(writer io.Writer, value uint64) {
-	if err := binary.Write(writer, binary.BigEndian, value); err != nil {
-		panic(err)
+	if w, ok := writer.(*rustBufWriter); ok {
+		w.buf = binary.BigEndian.AppendUint64(w.buf, value)
+		return
 	}
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint64(b, value)
+	writeFull(writer, b)
 }
 
 func writeFloat32(writer io.Writer, value float32) {
-	if err := binary.Write(writer, binary.BigEndian, value); err != nil {
-		panic(err)
-	}
+	writeUint32(writer, math.Float32bits(value))
 }
 
 func writeFloat64(writer io.Writer, value float64) {
-	if err := binary.Write(writer, binary.BigEndian, value); err != nil {
+	writeUint64(writer, math.Float64bits(value))
+}
+
+// writeFull writes b to writers other than rustBufWriter.
+func writeFull(writer io.Writer, b []byte) {
+	if _, err := writer.Write(b); err != nil {
 		panic(err)
 	}
 }
 
 
 func readInt8(reader io.Reader) int8 {
-	var result int8
-	if err := binary.Read(reader, binary.BigEndian, &result); err != nil {
-		panic(err)
-	}
-	return result
+	return int8(readUint8(reader))
 }
 
 func readUint8(reader io.Reader) uint8 {
-	var result uint8
-	if err := binary.Read(reader, binary.BigEndian, &result); err != nil {
-		panic(err)
+	if r, ok := reader.(*rustBufReader); ok {
+		return r.next(1)[0]
 	}
-	return result
+	return readFull(reader, 1)[0]
 }
 
 func readInt16(reader io.Reader) int16 {
-	var result int16
-	if err := binary.Read(reader, binary.BigEndian, &result); err != nil {
-		panic(err)
-	}
-	return result
+	return int16(readUint16(reader))
 }
 
 func readUint16(reader io.Reader) uint16 {
-	var result uint16
-	if err := binary.Read(reader, binary.BigEndian, &result); err != nil {
-		panic(err)
+	if r, ok := reader.(*rustBufReader); ok {
+		return binary.BigEndian.Uint16(r.next(2))
 	}
-	return result
+	return binary.BigEndian.Uint16(readFull(reader, 2))
 }
 
 func readInt32(reader io.Reader) int32 {
-	var result int32
-	if err := binary.Read(reader, binary.BigEndian, &result); err != nil {
-		panic(err)
-	}
-	return result
+	return int32(readUint32(reader))
 }
 
 func readUint32(reader io.Reader) uint32 {
-	var result uint32
-	if err := binary.Read(reader, binary.BigEndian, &result); err != nil {
-		panic(err)
+	if r, ok := reader.(*rustBufReader); ok {
+		return binary.BigEndian.Uint32(r.next(4))
 	}
-	return result
+	return binary.BigEndian.Uint32(readFull(reader, 4))
 }
 
 func readInt64(reader io.Reader) int64 {
-	var result int64
-	if err := binary.Read(reader, binary.BigEndian, &result); err != nil {
-		panic(err)
-	}
-	return result
+	return int64(readUint64(reader))
 }
 
 func readUint64(reader io.Reader) uint64 {
-	var result uint64
-	if err := binary.Read(reader, binary.BigEndian, &result); err != nil {
-		panic(err)
+	if r, ok := reader.(*rustBufReader); ok {
+		return binary.BigEndian.Uint64(r.next(8))
 	}
-	return result
+	return binary.BigEndian.Uint64(readFull(reader, 8))
 }
 
 func readFloat32(reader io.Reader) float32 {
-	var result float32
-	if err := binary.Read(reader, binary.BigEndian, &result); err != nil {
-		panic(err)
-	}
-	return result
+	return math.Float32frombits(readUint32(reader))
 }
 
 func readFloat64(reader io.Reader) float64 {
-	var result float64
-	if err := binary.Read(reader, binary.BigEndian, &result); err != nil {
+	return math.Float64frombits(readUint64(reader))
+}
+
+// readFull reads n bytes from readers other than rustBufReader.
+func readFull(reader io.Reader, n int) []byte {
+	b := make([]byte, n)
+	if _, err := io.ReadFull(reader, b); err != nil {
 		panic(err)
 	}
-	return result
+	return b
 }
 
 func init() {
@@ -1199,19 +1251,17 @@ var FfiConverterStringINSTANCE = FfiConverterString{}
 
 func (FfiConverterString) Lift(rb RustBufferI) string {
 	defer rb.Free()
-	reader := rb.AsReader()
-	b, err := io.ReadAll(reader)
-	if err != nil {
-		panic(fmt.Errorf("reading reader: %w", err))
-	}
-	return string(b)
+	return string(unsafe.Slice((*byte)(rb.Data()), rb.Len()))
 }
 
 func (FfiConverterString) Read(reader io.Reader) string {
 	length := readInt32(reader)
+	if r, ok := reader.(*rustBufReader); ok {
+		return string(r.next(int(length)))
+	}
 	buffer := make([]byte, length)
-	read_length, err := reader.Read(buffer)
-	if err != nil && err != io.EOF {
+	read_length, err := io.ReadFull(reader, buffer)
+	if err != nil && err != io.ErrUnexpectedEOF {
 		panic(err)
 	}
 	if read_length != int(length) {
@@ -1273,9 +1323,14 @@ func (c FfiConverterBytes) Lift(rb RustBufferI) []byte {
 
 func (c FfiConverterBytes) Read(reader io.Reader) []byte {
 	length := readInt32(reader)
+	if r, ok := reader.(*rustBufReader); ok {
+		// The buffer is freed after lifting, so the bytes are copied
+		b := r.next(int(length))
+		return append(make([]byte, 0, len(b)), b...)
+	}
 	buffer := make([]byte, length)
-	read_length, err := reader.Read(buffer)
-	if err != nil && err != io.EOF {
+	read_length, err := io.ReadFull(reader, buffer)
+	if err != nil && err != io.ErrUnexpectedEOF {
 		panic(err)
 	}
 	if read_length != int(length) {
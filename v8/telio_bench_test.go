@@ -0,0 +1,127 @@
+package telio
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+	"unsafe"
+)
+
+// goRustBuffer is a RustBufferI backed by Go memory, so lifting runs without
+// the native library.
+type goRustBuffer []byte
+
+func (b goRustBuffer) Data() unsafe.Pointer {
+	if len(b) == 0 {
+		return nil
+	}
+	return unsafe.Pointer(&b[0])
+}
+
+func (b goRustBuffer) Len() uint64      { return uint64(len(b)) }
+func (b goRustBuffer) Capacity() uint64 { return uint64(cap(b)) }
+func (b goRustBuffer) Free()            {}
+
+func (b goRustBuffer) ToGoBytes() []byte { return append([]byte(nil), b...) }
+
+func (b goRustBuffer) AsReader() *bytes.Reader { return bytes.NewReader(b) }
+
+// lower encodes value like LowerIntoRustBuffer, short of copying the result
+// into a buffer owned by libtelio.
+func lower[T any](w BufWriter[T], value T) []byte {
+	writer := rustBufWriterPool.Get().(*rustBufWriter)
+	w.Write(writer, value)
+	data := append([]byte(nil), writer.buf...)
+	writer.buf = writer.buf[:0]
+	rustBufWriterPool.Put(writer)
+	return data
+}
+
+// lift decodes data with LiftFromRustBuffer.
+func lift[T any](r BufReader[T], data []byte) T {
+	return LiftFromRustBuffer[T](r, goRustBuffer(data))
+}
+
+const benchKey PublicKey = "QKyApX/ewza7QEbC03Yt8t2ghu6nV5/rve/ZJvsecXo="
+
+// benchConfig returns a meshnet config with 1000 peers.
+func benchConfig() Config {
+	peers := make([]Peer, 1000)
+	for i := range peers {
+		ips := []IpAddr{IpAddr(fmt.Sprintf("100.64.%d.%d", i/256, i%256)), "fd74:656c:696f::1"}
+		nick := fmt.Sprintf("nick-%d", i)
+		peers[i] = Peer{
+			Base: PeerBase{
+				Identifier:  fmt.Sprintf("id-%d", i),
+				PublicKey:   benchKey,
+				Hostname:    fmt.Sprintf("host-%d.nord", i),
+				IpAddresses: &ips,
+				Nickname:    &nick,
+			},
+			AllowIncomingConnections: true,
+			AllowMulticast:           true,
+		}
+	}
+	derp := []Server{{RegionCode: "nl", Name: "derp-1", Hostname: "derp.example", Ipv4: "192.0.2.1", RelayPort: 8765, PublicKey: benchKey}}
+	dns := []IpAddr{"100.64.0.2"}
+	return Config{
+		This:        PeerBase{Identifier: "this", PublicKey: benchKey, Hostname: "this.nord"},
+		Peers:       &peers,
+		DerpServers: &derp,
+		Dns:         &DnsConfig{DnsServers: &dns},
+	}
+}
+
+// benchNodes returns a status map with 1000 nodes.
+func benchNodes() []TelioNode {
+	nodes := make([]TelioNode, 1000)
+	for i := range nodes {
+		endpoint := SocketAddr(fmt.Sprintf("192.0.2.%d:51820", i%256))
+		nodes[i] = TelioNode{
+			Identifier:  fmt.Sprintf("id-%d", i),
+			PublicKey:   benchKey,
+			State:       NodeStateConnected,
+			IpAddresses: []IpAddr{IpAddr(fmt.Sprintf("100.64.%d.%d", i/256, i%256))},
+			AllowedIps:  []IpNet{IpNet(fmt.Sprintf("100.64.%d.%d/32", i/256, i%256))},
+			Endpoint:    &endpoint,
+			Path:        PathTypeDirect,
+		}
+	}
+	return nodes
+}
+
+func BenchmarkLowerConfig1k(b *testing.B) {
+	cfg := benchConfig()
+	b.SetBytes(int64(len(lower[Config](FfiConverterConfigINSTANCE, cfg))))
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		lower[Config](FfiConverterConfigINSTANCE, cfg)
+	}
+}
+
+func BenchmarkLiftConfig1k(b *testing.B) {
+	data := lower[Config](FfiConverterConfigINSTANCE, benchConfig())
+	b.SetBytes(int64(len(data)))
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		lift[Config](FfiConverterConfigINSTANCE, data)
+	}
+}
+
+func BenchmarkLowerStatusMap1k(b *testing.B) {
+	nodes := benchNodes()
+	b.SetBytes(int64(len(lower[[]TelioNode](FfiConverterSequenceTelioNodeINSTANCE, nodes))))
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		lower[[]TelioNode](FfiConverterSequenceTelioNodeINSTANCE, nodes)
+	}
+}
+
+func BenchmarkLiftStatusMap1k(b *testing.B) {
+	data := lower[[]TelioNode](FfiConverterSequenceTelioNodeINSTANCE, benchNodes())
+	b.SetBytes(int64(len(data)))
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		lift[[]TelioNode](FfiConverterSequenceTelioNodeINSTANCE, data)
+	}
+}
@@ -0,0 +1,133 @@
+package telio
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"math"
+	"reflect"
+	"testing"
+)
+
+// writeAll writes one value of every primitive to w.
+func writeAll(w io.Writer) {
+	writeInt8(w, math.MinInt8)
+	writeUint8(w, math.MaxUint8)
+	writeInt16(w, math.MinInt16)
+	writeUint16(w, math.MaxUint16)
+	writeInt32(w, math.MinInt32)
+	writeUint32(w, math.MaxUint32)
+	writeInt64(w, math.MinInt64)
+	writeUint64(w, math.MaxUint64)
+	writeFloat32(w, -1.5)
+	writeFloat64(w, math.Pi)
+	FfiConverterStringINSTANCE.Write(w, "telio")
+	FfiConverterBytesINSTANCE.Write(w, []byte{0, 1, 2})
+	FfiConverterBytesINSTANCE.Write(w, []byte{})
+}
+
+// readAll reads the values of writeAll from r and checks them.
+func readAll(t *testing.T, r io.Reader) {
+	t.Helper()
+	got := []any{
+		readInt8(r), readUint8(r), readInt16(r), readUint16(r),
+		readInt32(r), readUint32(r), readInt64(r), readUint64(r),
+		readFloat32(r), readFloat64(r),
+		FfiConverterStringINSTANCE.Read(r),
+		FfiConverterBytesINSTANCE.Read(r),
+		FfiConverterBytesINSTANCE.Read(r),
+	}
+	want := []any{
+		int8(math.MinInt8), uint8(math.MaxUint8), int16(math.MinInt16), uint16(math.MaxUint16),
+		int32(math.MinInt32), uint32(math.MaxUint32), int64(math.MinInt64), uint64(math.MaxUint64),
+		float32(-1.5), math.Pi,
+		"telio",
+		[]byte{0, 1, 2},
+		[]byte{},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("read %v, want %v", got, want)
+	}
+}
+
+func TestRustBufRoundTrip(t *testing.T) {
+	w := &rustBufWriter{}
+	writeAll(w)
+
+	// The fast paths encode like the generic ones
+	var generic bytes.Buffer
+	writeAll(&generic)
+	if !bytes.Equal(w.buf, generic.Bytes()) {
+		t.Fatalf("encoded %x, generic writer %x", w.buf, generic.Bytes())
+	}
+
+	r := &rustBufReader{buf: w.buf}
+	readAll(t, r)
+	if r.Len() != 0 {
+		t.Fatalf("%d bytes left", r.Len())
+	}
+	if n, err := r.Read(make([]byte, 1)); n != 0 || err != io.EOF {
+		t.Fatalf("read %d, %v at the end, want io.EOF", n, err)
+	}
+	readAll(t, bytes.NewReader(generic.Bytes()))
+}
+
+func TestRustBufBytesCopied(t *testing.T) {
+	data := lower[[]byte](FfiConverterBytesINSTANCE, []byte{1, 2, 3})
+	b := lift[[]byte](FfiConverterBytesINSTANCE, data)
+	// libtelio frees the buffer after lifting
+	data[len(data)-1] = 0
+	if !bytes.Equal(b, []byte{1, 2, 3}) {
+		t.Fatalf("lifted bytes share the buffer: %v", b)
+	}
+}
+
+func TestLiftLowerRoundTrip(t *testing.T) {
+	cfg := benchConfig()
+	if got := lift[Config](FfiConverterConfigINSTANCE, lower[Config](FfiConverterConfigINSTANCE, cfg)); !reflect.DeepEqual(got, cfg) {
+		t.Fatal("config changed in a round trip")
+	}
+	nodes := benchNodes()
+	if got := lift[[]TelioNode](FfiConverterSequenceTelioNodeINSTANCE, lower[[]TelioNode](FfiConverterSequenceTelioNodeINSTANCE, nodes)); !reflect.DeepEqual(got, nodes) {
+		t.Fatal("status map changed in a round trip")
+	}
+}
+
+// panicValue returns what f panics with.
+func panicValue(f func()) (v any) {
+	defer func() { v = recover() }()
+	f()
+	return nil
+}
+
+func TestRustBufShortRead(t *testing.T) {
+	// A length of 8 followed by 2 bytes
+	short := &rustBufWriter{}
+	writeInt32(short, 8)
+	short.buf = append(short.buf, 'a', 'b')
+	negative := &rustBufWriter{}
+	writeInt32(negative, -1)
+
+	for _, tc := range []struct {
+		name string
+		buf  []byte
+		read func(io.Reader)
+	}{
+		{"uint32", short.buf[:3], func(r io.Reader) { readUint32(r) }},
+		{"string", short.buf, func(r io.Reader) { FfiConverterStringINSTANCE.Read(r) }},
+		{"bytes", short.buf, func(r io.Reader) { FfiConverterBytesINSTANCE.Read(r) }},
+		{"negative length", negative.buf, func(r io.Reader) { FfiConverterBytesINSTANCE.Read(r) }},
+	} {
+		v := panicValue(func() { tc.read(&rustBufReader{buf: tc.buf}) })
+		if err, ok := v.(error); !ok || !errors.Is(err, io.ErrUnexpectedEOF) {
+			t.Errorf("%s: panicked with %v, want io.ErrUnexpectedEOF", tc.name, v)
+		}
+	}
+}
+
+func TestLiftJunk(t *testing.T) {
+	data := append(lower[uint32](FfiConverterUint32INSTANCE, 7), 0)
+	if v := panicValue(func() { lift[uint32](FfiConverterUint32INSTANCE, data) }); v == nil {
+		t.Fatal("lifting a buffer with junk left did not panic")
+	}
+}
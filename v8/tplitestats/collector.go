@@ -0,0 +1,175 @@
+package tplitestats
+
+import (
+	"sync"
+	"sync/atomic"
+
+	telio "github.com/NordSecurity/libtelio-go/v8"
+)
+
+const defaultMaxPending = 1 << 16
+
+// CollectorOptions configure a Collector. Zero values select the defaults.
+type CollectorOptions struct {
+	// Number of blocked domains held while the store catches up [default 65536]
+	MaxPending int
+	// The telio.DnsMetrics of each call count only what happened since the
+	// previous call, instead of being running totals
+	MetricsPerCall bool
+	// Called from the background goroutine when persisting fails
+	OnError func(error)
+}
+
+// Collector is a telio.TpLiteStatsCallback which never blocks libfirewall.
+//
+// Every call is merged into a pending batch which a background goroutine
+// appends to a Store, so a slow disk only makes the batches larger. Domains
+// are dropped and counted only when MaxPending of them are waiting already.
+type Collector struct {
+	store      *Store
+	onError    func(error)
+	maxPending int
+	perCall    bool
+	wake       chan struct{}
+	done       chan struct{}
+	dropped    atomic.Uint64
+
+	mu       sync.Mutex
+	closed   bool
+	pending  []telio.BlockedDomain
+	counters Counters
+	// Last metrics reported by libfirewall
+	last *telio.DnsMetrics
+}
+
+var _ telio.TpLiteStatsCallback = (*Collector)(nil)
+
+// NewCollector starts a collector persisting into store. Pass it to
+// Telio.EnableTpLiteStatsCollection and Close it after
+// Telio.DisableTpLiteStatsCollection.
+func NewCollector(store *Store, opts CollectorOptions) *Collector {
+	if opts.MaxPending <= 0 {
+		opts.MaxPending = defaultMaxPending
+	}
+	c := &Collector{
+		store:      store,
+		onError:    opts.OnError,
+		maxPending: opts.MaxPending,
+		perCall:    opts.MetricsPerCall,
+		wake:       make(chan struct{}, 1),
+		done:       make(chan struct{}),
+	}
+	go c.run()
+	return c
+}
+
+// Collect implements telio.TpLiteStatsCallback.
+func (c *Collector) Collect(domains []telio.BlockedDomain, metrics telio.DnsMetrics) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		c.dropped.Add(uint64(len(domains)))
+		return
+	}
+
+	if room := c.maxPending - len(c.pending); len(domains) > room {
+		c.dropped.Add(uint64(len(domains) - room))
+		domains = domains[:room]
+	}
+	c.pending = append(c.pending, domains...)
+	delta := c.metricsDelta(metrics)
+	c.counters.NumRequests += delta.NumRequests
+	c.counters.NumResponses += delta.NumResponses
+	c.counters.NumCacheHits += delta.NumCacheHits
+
+	select {
+	case c.wake <- struct{}{}:
+	default:
+		// The goroutine is woken already and picks these up as well
+	}
+}
+
+// Dropped returns the number of blocked domains which could not be kept.
+func (c *Collector) Dropped() uint64 {
+	return c.dropped.Load()
+}
+
+// Close persists the pending batch and stops the collector. The store is
+// left open.
+func (c *Collector) Close() {
+	c.mu.Lock()
+	if !c.closed {
+		c.closed = true
+		close(c.wake)
+	}
+	c.mu.Unlock()
+	<-c.done
+}
+
+func (c *Collector) run() {
+	defer close(c.done)
+	for range c.wake {
+		c.flush()
+	}
+	c.flush()
+}
+
+// flush persists everything collected since the previous flush.
+func (c *Collector) flush() {
+	c.mu.Lock()
+	domains, counters := c.pending, c.counters
+	c.pending, c.counters = nil, Counters{}
+	c.mu.Unlock()
+
+	if err := c.store.Append(domains); err != nil {
+		c.reportError(err)
+	}
+	if counters != (Counters{}) {
+		if err := c.store.AddMetrics(counters); err != nil {
+			c.reportError(err)
+		}
+	}
+}
+
+// metricsDelta turns the counters reported by libfirewall into increments of
+// the persisted ones. The bindings do not say whether telio.DnsMetrics are
+// running totals or counts since the previous call; the field docs ("Number
+// of DNS requests that have been made") read as totals, so by default only
+// the difference to the last report is new. A total lower than the previous
+// one means libfirewall started counting from zero again, e.g. after
+// Telio.DisableTpLiteStatsCollection and a new
+// Telio.EnableTpLiteStatsCollection. With MetricsPerCall every report is an
+// increment as it is.
+func (c *Collector) metricsDelta(m telio.DnsMetrics) Counters {
+	if c.perCall {
+		return Counters{
+			NumRequests:  uint64(m.NumRequests),
+			NumResponses: uint64(m.NumResponses),
+			NumCacheHits: uint64(m.NumCacheHits),
+		}
+	}
+	var prev telio.DnsMetrics
+	if c.last != nil {
+		prev = *c.last
+	}
+	c.last = &m
+	return Counters{
+		NumRequests:  counterDelta(prev.NumRequests, m.NumRequests),
+		NumResponses: counterDelta(prev.NumResponses, m.NumResponses),
+		NumCacheHits: counterDelta(prev.NumCacheHits, m.NumCacheHits),
+	}
+}
+
+func counterDelta(prev, cur uint32) uint64 {
+	if cur < prev {
+		// Counter was reset, everything since the reset is new
+		return uint64(cur)
+	}
+	return uint64(cur - prev)
+}
+
+func (c *Collector) reportError(err error) {
+	if c.onError != nil {
+		c.onError(err)
+	}
+}
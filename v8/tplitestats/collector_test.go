@@ -0,0 +1,102 @@
+package tplitestats
+
+import (
+	"fmt"
+	"testing"
+
+	telio "github.com/NordSecurity/libtelio-go/v8"
+)
+
+func openTestStore(t *testing.T) *Store {
+	t.Helper()
+	s, err := OpenStore(t.TempDir(), StoreOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+func TestCollectorKeepsEveryBatch(t *testing.T) {
+	s := openTestStore(t)
+	c := NewCollector(s, CollectorOptions{})
+	const calls = 1000
+	for i := 0; i < calls; i++ {
+		c.Collect([]telio.BlockedDomain{{DomainName: fmt.Sprintf("d%d.example", i), Timestamp: uint64(i), Category: "ads"}}, telio.DnsMetrics{})
+	}
+	c.Close()
+
+	got, err := s.Query(Query{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != calls || c.Dropped() != 0 {
+		t.Fatalf("stored %d domains, dropped %d, want %d and 0", len(got), c.Dropped(), calls)
+	}
+}
+
+func TestCollectorMaxPending(t *testing.T) {
+	s := openTestStore(t)
+	c := NewCollector(s, CollectorOptions{MaxPending: 2})
+	// Pending without waking the goroutine, so nothing is flushed in between
+	c.mu.Lock()
+	c.pending = append(c.pending, telio.BlockedDomain{DomainName: "a.example"})
+	c.mu.Unlock()
+	c.Collect([]telio.BlockedDomain{{DomainName: "b.example"}, {DomainName: "c.example"}}, telio.DnsMetrics{})
+	c.Close()
+
+	got, err := s.Query(Query{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || c.Dropped() != 1 {
+		t.Fatalf("stored %d domains, dropped %d", len(got), c.Dropped())
+	}
+}
+
+func TestCollectorCounterReset(t *testing.T) {
+	s := openTestStore(t)
+	c := NewCollector(s, CollectorOptions{})
+	for _, m := range []telio.DnsMetrics{
+		{NumRequests: 10, NumResponses: 8, NumCacheHits: 1},
+		{NumRequests: 15, NumResponses: 12, NumCacheHits: 2},
+		// New collection session, counting starts from zero
+		{NumRequests: 3, NumResponses: 3, NumCacheHits: 0},
+		{NumRequests: 8, NumResponses: 7, NumCacheHits: 4},
+	} {
+		c.Collect(nil, m)
+	}
+	c.Close()
+
+	want := Counters{NumRequests: 23, NumResponses: 19, NumCacheHits: 6}
+	if got := s.Counters(); got != want {
+		t.Fatalf("counters %+v, want %+v", got, want)
+	}
+}
+
+func TestCollectorMetricsPerCall(t *testing.T) {
+	s := openTestStore(t)
+	c := NewCollector(s, CollectorOptions{MetricsPerCall: true})
+	for _, m := range []telio.DnsMetrics{
+		{NumRequests: 10, NumResponses: 8, NumCacheHits: 1},
+		{NumRequests: 5, NumResponses: 4, NumCacheHits: 1},
+	} {
+		c.Collect(nil, m)
+	}
+	c.Close()
+
+	want := Counters{NumRequests: 15, NumResponses: 12, NumCacheHits: 2}
+	if got := s.Counters(); got != want {
+		t.Fatalf("counters %+v, want %+v", got, want)
+	}
+}
+
+func TestCollectorAfterClose(t *testing.T) {
+	c := NewCollector(openTestStore(t), CollectorOptions{})
+	c.Close()
+	c.Close()
+	c.Collect([]telio.BlockedDomain{{DomainName: "a.example"}}, telio.DnsMetrics{})
+	if c.Dropped() != 1 {
+		t.Fatalf("dropped %d, want 1", c.Dropped())
+	}
+}
@@ -0,0 +1,398 @@
+// Package tplitestats persists the TP-Lite statistics delivered through
+// telio.TpLiteStatsCallback.
+//
+// Blocked domains are appended to a rotating, append-only log on disk and DNS
+// metrics are folded into monotonically increasing counters, so reports such
+// as "blocked today" survive application restarts.
+package tplitestats
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	telio "github.com/NordSecurity/libtelio-go/v8"
+	"github.com/NordSecurity/libtelio-go/v8/internal/atomicfile"
+)
+
+const (
+	segmentPrefix = "blocked-"
+	segmentSuffix = ".log"
+	countersFile  = "counters.json"
+
+	defaultMaxSegmentBytes = 4 << 20
+	defaultMaxSegments     = 16
+)
+
+// ErrClosed is returned when using a Store after Close.
+var ErrClosed = errors.New("tplitestats: store is closed")
+
+// StoreOptions configure a Store. Zero values select the defaults.
+type StoreOptions struct {
+	// Size after which the active segment is rotated [default 4MiB]
+	MaxSegmentBytes int64
+	// Number of segments kept on disk, the oldest ones are removed first [default 16]
+	MaxSegments int
+	// Unit of telio.BlockedDomain.Timestamp [default time.Second]
+	TimestampUnit time.Duration
+	// Sync every appended batch to stable storage
+	Sync bool
+}
+
+// Counters are the DNS metrics accumulated over the whole lifetime of a Store.
+type Counters struct {
+	// Number of DNS requests that have been made
+	NumRequests uint64 `json:"num_requests"`
+	// Number of received DNS responses
+	NumResponses uint64 `json:"num_responses"`
+	// Number of DNS requests that were caught by libfirewall's cache of blocked domains
+	NumCacheHits uint64 `json:"num_cache_hits"`
+}
+
+// Query selects blocked domains from a Store. Zero values match everything.
+type Query struct {
+	// Only return domains of this category
+	Category string
+	// Only return domains blocked at or after this time
+	From time.Time
+	// Only return domains blocked before this time
+	To time.Time
+	// Maximum number of returned domains, the most recent ones are kept
+	Limit int
+}
+
+type record struct {
+	Domain    string `json:"domain"`
+	Category  string `json:"category"`
+	Timestamp uint64 `json:"ts"`
+}
+
+// Store is an append-only, size-rotated on-disk log of blocked domains.
+// It is safe for concurrent use.
+type Store struct {
+	dir  string
+	opts StoreOptions
+
+	mu         sync.Mutex
+	active     *os.File
+	activeSeq  uint64
+	activeSize int64
+	counters   Counters
+	closed     bool
+}
+
+// OpenStore opens, or creates, a store in dir.
+func OpenStore(dir string, opts StoreOptions) (*Store, error) {
+	if opts.MaxSegmentBytes <= 0 {
+		opts.MaxSegmentBytes = defaultMaxSegmentBytes
+	}
+	if opts.MaxSegments <= 0 {
+		opts.MaxSegments = defaultMaxSegments
+	}
+	if opts.TimestampUnit <= 0 {
+		opts.TimestampUnit = time.Second
+	}
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("creating store directory: %w", err)
+	}
+
+	s := &Store{dir: dir, opts: opts}
+	if err := s.loadCounters(); err != nil {
+		return nil, err
+	}
+
+	seqs, err := s.segments()
+	if err != nil {
+		return nil, err
+	}
+	seq := uint64(1)
+	if len(seqs) > 0 {
+		seq = seqs[len(seqs)-1]
+	}
+	if err := s.openSegment(seq); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Append adds blocked domains to the active segment, rotating it if needed.
+func (s *Store) Append(domains []telio.BlockedDomain) error {
+	if len(domains) == 0 {
+		return nil
+	}
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, d := range domains {
+		if err := enc.Encode(record{Domain: d.DomainName, Category: d.Category, Timestamp: d.Timestamp}); err != nil {
+			return fmt.Errorf("encoding blocked domain: %w", err)
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return ErrClosed
+	}
+
+	if s.activeSize > 0 && s.activeSize+int64(buf.Len()) > s.opts.MaxSegmentBytes {
+		if err := s.rotate(); err != nil {
+			return err
+		}
+	}
+
+	n, err := s.active.Write(buf.Bytes())
+	s.activeSize += int64(n)
+	if err != nil {
+		return fmt.Errorf("appending to segment: %w", err)
+	}
+	if s.opts.Sync {
+		if err := s.active.Sync(); err != nil {
+			return fmt.Errorf("syncing segment: %w", err)
+		}
+	}
+	return nil
+}
+
+// AddMetrics adds a metrics delta to the persisted counters.
+func (s *Store) AddMetrics(delta Counters) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return ErrClosed
+	}
+
+	s.counters.NumRequests += delta.NumRequests
+	s.counters.NumResponses += delta.NumResponses
+	s.counters.NumCacheHits += delta.NumCacheHits
+	return s.saveCounters()
+}
+
+// Counters returns the accumulated DNS metrics.
+func (s *Store) Counters() Counters {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.counters
+}
+
+// Query returns the stored domains matching q, oldest first.
+func (s *Store) Query(q Query) ([]telio.BlockedDomain, error) {
+	var out []telio.BlockedDomain
+	err := s.scan(q, func(r record) {
+		out = append(out, telio.BlockedDomain{DomainName: r.Domain, Timestamp: r.Timestamp, Category: r.Category})
+		if q.Limit > 0 && len(out) > q.Limit {
+			out = out[1:]
+		}
+	})
+	return out, err
+}
+
+// CountByCategory returns the number of blocked domains per category for q.
+// q.Limit is ignored.
+func (s *Store) CountByCategory(q Query) (map[string]uint64, error) {
+	counts := make(map[string]uint64)
+	err := s.scan(q, func(r record) {
+		counts[r.Category]++
+	})
+	return counts, err
+}
+
+// Close closes the active segment.
+func (s *Store) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return nil
+	}
+	s.closed = true
+	return s.active.Close()
+}
+
+func (s *Store) scan(q Query, fn func(record)) error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return ErrClosed
+	}
+	seqs, err := s.segments()
+	// Only bytes written so far are visible, a concurrent append is not torn
+	activeSeq, activeSize := s.activeSeq, s.activeSize
+	s.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	for _, seq := range seqs {
+		limit := int64(-1)
+		if seq == activeSeq {
+			limit = activeSize
+		}
+		if err := s.scanSegment(seq, limit, q, fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *Store) scanSegment(seq uint64, limit int64, q Query, fn func(record)) error {
+	f, err := os.Open(s.segmentPath(seq))
+	if errors.Is(err, os.ErrNotExist) {
+		// Pruned by a concurrent rotation
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("opening segment: %w", err)
+	}
+	defer f.Close()
+
+	var r io.Reader = f
+	if limit >= 0 {
+		r = io.LimitReader(f, limit)
+	}
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 4096), 1<<20)
+	for scanner.Scan() {
+		var rec record
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			// Torn write from a crash, the rest of the segment is still usable
+			continue
+		}
+		if q.Category != "" && rec.Category != q.Category {
+			continue
+		}
+		ts := s.timestamp(rec.Timestamp)
+		if !q.From.IsZero() && ts.Before(q.From) {
+			continue
+		}
+		if !q.To.IsZero() && !ts.Before(q.To) {
+			continue
+		}
+		fn(rec)
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("reading segment: %w", err)
+	}
+	return nil
+}
+
+func (s *Store) timestamp(ts uint64) time.Time {
+	return time.Unix(0, 0).Add(time.Duration(ts) * s.opts.TimestampUnit)
+}
+
+func (s *Store) rotate() error {
+	if err := s.active.Close(); err != nil {
+		return fmt.Errorf("closing segment: %w", err)
+	}
+	if err := s.openSegment(s.activeSeq + 1); err != nil {
+		return err
+	}
+
+	seqs, err := s.segments()
+	if err != nil {
+		return err
+	}
+	for len(seqs) > s.opts.MaxSegments {
+		if err := os.Remove(s.segmentPath(seqs[0])); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("pruning segment: %w", err)
+		}
+		seqs = seqs[1:]
+	}
+	return nil
+}
+
+func (s *Store) openSegment(seq uint64) error {
+	f, err := os.OpenFile(s.segmentPath(seq), os.O_CREATE|os.O_RDWR|os.O_APPEND, 0o600)
+	if err != nil {
+		return fmt.Errorf("opening segment: %w", err)
+	}
+	size, err := terminateSegment(f)
+	if err != nil {
+		f.Close()
+		return err
+	}
+	s.active, s.activeSeq, s.activeSize = f, seq, size
+	return nil
+}
+
+// terminateSegment makes sure that a record torn by a crash does not swallow
+// the first record appended after restart.
+func terminateSegment(f *os.File) (int64, error) {
+	info, err := f.Stat()
+	if err != nil {
+		return 0, fmt.Errorf("reading segment size: %w", err)
+	}
+	size := info.Size()
+	if size == 0 {
+		return 0, nil
+	}
+
+	last := make([]byte, 1)
+	if _, err := f.ReadAt(last, size-1); err != nil {
+		return 0, fmt.Errorf("reading segment tail: %w", err)
+	}
+	if last[0] == '\n' {
+		return size, nil
+	}
+	if _, err := f.Write([]byte{'\n'}); err != nil {
+		return 0, fmt.Errorf("terminating segment: %w", err)
+	}
+	return size + 1, nil
+}
+
+func (s *Store) segments() ([]uint64, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, fmt.Errorf("listing segments: %w", err)
+	}
+	var seqs []uint64
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || !strings.HasPrefix(name, segmentPrefix) || !strings.HasSuffix(name, segmentSuffix) {
+			continue
+		}
+		var seq uint64
+		if _, err := fmt.Sscanf(strings.TrimSuffix(strings.TrimPrefix(name, segmentPrefix), segmentSuffix), "%d", &seq); err != nil {
+			continue
+		}
+		seqs = append(seqs, seq)
+	}
+	sort.Slice(seqs, func(i, j int) bool { return seqs[i] < seqs[j] })
+	return seqs, nil
+}
+
+func (s *Store) segmentPath(seq uint64) string {
+	return filepath.Join(s.dir, fmt.Sprintf("%s%020d%s", segmentPrefix, seq, segmentSuffix))
+}
+
+func (s *Store) loadCounters() error {
+	data, err := os.ReadFile(filepath.Join(s.dir, countersFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("reading counters: %w", err)
+	}
+	if err := json.Unmarshal(data, &s.counters); err != nil {
+		return fmt.Errorf("parsing counters: %w", err)
+	}
+	return nil
+}
+
+func (s *Store) saveCounters() error {
+	data, err := json.Marshal(s.counters)
+	if err != nil {
+		return fmt.Errorf("encoding counters: %w", err)
+	}
+
+	if err := atomicfile.WriteFile(filepath.Join(s.dir, countersFile), data, 0o644); err != nil {
+		return fmt.Errorf("replacing counters: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,140 @@
+package tplitestats
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	telio "github.com/NordSecurity/libtelio-go/v8"
+)
+
+func names(domains []telio.BlockedDomain) []string {
+	out := make([]string, len(domains))
+	for i, d := range domains {
+		out[i] = d.DomainName
+	}
+	return out
+}
+
+func TestStoreRotation(t *testing.T) {
+	dir := t.TempDir()
+	s, err := OpenStore(dir, StoreOptions{MaxSegmentBytes: 100, MaxSegments: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	// Every record is about 50 bytes, two fit in a segment
+	for i := 0; i < 10; i++ {
+		if err := s.Append([]telio.BlockedDomain{{DomainName: fmt.Sprintf("d%d.example", i), Category: "ads", Timestamp: uint64(i)}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	seqs, err := s.segments()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(seqs) != 3 || seqs[2] != 5 {
+		t.Fatalf("segments %v, want the last 3 of 5", seqs)
+	}
+	got, err := s.Query(Query{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"d4.example", "d5.example", "d6.example", "d7.example", "d8.example", "d9.example"}
+	if !slices.Equal(names(got), want) {
+		t.Fatalf("domains %v, want %v", names(got), want)
+	}
+}
+
+func TestStoreQuery(t *testing.T) {
+	s := openTestStore(t)
+	err := s.Append([]telio.BlockedDomain{
+		{DomainName: "a.example", Category: "ads", Timestamp: 100},
+		{DomainName: "b.example", Category: "malware", Timestamp: 200},
+		{DomainName: "c.example", Category: "ads", Timestamp: 300},
+		{DomainName: "d.example", Category: "ads", Timestamp: 400},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, tc := range map[string]struct {
+		q    Query
+		want []string
+	}{
+		"all":      {Query{}, []string{"a.example", "b.example", "c.example", "d.example"}},
+		"category": {Query{Category: "ads"}, []string{"a.example", "c.example", "d.example"}},
+		"from":     {Query{From: time.Unix(200, 0)}, []string{"b.example", "c.example", "d.example"}},
+		"to":       {Query{To: time.Unix(300, 0)}, []string{"a.example", "b.example"}},
+		"limit":    {Query{Category: "ads", Limit: 2}, []string{"c.example", "d.example"}},
+	} {
+		got, err := s.Query(tc.q)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !slices.Equal(names(got), tc.want) {
+			t.Errorf("%s: %v, want %v", name, names(got), tc.want)
+		}
+	}
+
+	counts, err := s.CountByCategory(Query{To: time.Unix(400, 0)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(counts) != 2 || counts["ads"] != 2 || counts["malware"] != 1 {
+		t.Fatalf("counts %v", counts)
+	}
+}
+
+func TestStoreReopen(t *testing.T) {
+	dir := t.TempDir()
+	s, err := OpenStore(dir, StoreOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Append([]telio.BlockedDomain{{DomainName: "a.example"}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.AddMetrics(Counters{NumRequests: 3, NumResponses: 2, NumCacheHits: 1}); err != nil {
+		t.Fatal(err)
+	}
+	s.Close()
+	if err := s.Append([]telio.BlockedDomain{{DomainName: "b.example"}}); err != ErrClosed {
+		t.Fatalf("append after close: %v", err)
+	}
+
+	// A crash tore the last record
+	seg := filepath.Join(dir, fmt.Sprintf("%s%020d%s", segmentPrefix, 1, segmentSuffix))
+	f, err := os.OpenFile(seg, os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString(`{"domain":"torn`)
+	f.Close()
+
+	s, err = OpenStore(dir, StoreOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	if err := s.AddMetrics(Counters{NumRequests: 1}); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := s.Counters(), (Counters{NumRequests: 4, NumResponses: 2, NumCacheHits: 1}); got != want {
+		t.Fatalf("counters %+v, want %+v", got, want)
+	}
+	if err := s.Append([]telio.BlockedDomain{{DomainName: "c.example"}}); err != nil {
+		t.Fatal(err)
+	}
+	got, err := s.Query(Query{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(names(got), []string{"a.example", "c.example"}) {
+		t.Fatalf("domains %v", names(got))
+	}
+}
@@ -0,0 +1,216 @@
+package tplitewhitelist
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	telio "github.com/NordSecurity/libtelio-go/v8"
+)
+
+const defaultInterval = 30 * time.Second
+
+// Applier is the part of telio.TelioInterface used by a Manager.
+type Applier interface {
+	SetTpLiteDomainWhitelist(domains []string, redirects []telio.DnsRedirect) error
+}
+
+// Options configure a Manager.
+type Options struct {
+	// DNS redirect pairs applied together with the domains
+	Redirects []telio.DnsRedirect
+	// How often Run checks the sources for changes [default 30s]
+	Interval time.Duration
+	// Called after a changed whitelist was applied
+	OnChange func(Diff)
+	// Called by Run when a reload fails, the last good whitelist stays applied
+	OnError func(error)
+	// Called for every skipped entry of a changed source, see ParseList
+	OnWarning func(*ParseError)
+}
+
+// Diff describes how an applied whitelist differs from the previous one.
+type Diff struct {
+	Added   []string
+	Removed []string
+}
+
+// Empty reports whether the whitelist did not change.
+func (d Diff) Empty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0
+}
+
+type sourceState struct {
+	domains []string
+	err     error
+}
+
+// Manager merges the domains of its sources and keeps the TP-Lite whitelist
+// in sync with them. When a reload fails, the last successfully applied
+// whitelist is kept.
+type Manager struct {
+	applier Applier
+	sources []Source
+	opts    Options
+
+	// Serializes reloads, which own states
+	reloadMu sync.Mutex
+	states   []sourceState
+
+	// Guards the applied whitelist and opts.Redirects, it is not held while
+	// sources load or callbacks run
+	mu               sync.Mutex
+	applied          []string
+	appliedRedirects []telio.DnsRedirect
+	hasApplied       bool
+}
+
+// NewManager creates a manager for the given sources. Nothing is applied
+// until Reload or Run is called.
+func NewManager(applier Applier, sources []Source, opts Options) (*Manager, error) {
+	if err := ValidateRedirects(opts.Redirects); err != nil {
+		return nil, err
+	}
+	if opts.Interval <= 0 {
+		opts.Interval = defaultInterval
+	}
+	return &Manager{
+		applier: applier,
+		sources: sources,
+		opts:    opts,
+		states:  make([]sourceState, len(sources)),
+	}, nil
+}
+
+// Domains returns the currently applied whitelist.
+func (m *Manager) Domains() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return slices.Clone(m.applied)
+}
+
+// SetRedirects validates and applies new DNS redirect pairs together with the
+// current whitelist.
+func (m *Manager) SetRedirects(redirects []telio.DnsRedirect) error {
+	if err := ValidateRedirects(redirects); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.hasApplied {
+		if err := m.applier.SetTpLiteDomainWhitelist(m.applied, redirects); err != nil {
+			return fmt.Errorf("applying whitelist: %w", err)
+		}
+		m.appliedRedirects = slices.Clone(redirects)
+	}
+	m.opts.Redirects = slices.Clone(redirects)
+	return nil
+}
+
+// Reload loads all sources and applies the merged whitelist if it changed.
+// Reloads run one at a time; the callbacks are called once the whitelist is
+// applied and may use the Manager.
+func (m *Manager) Reload(ctx context.Context) (Diff, error) {
+	m.reloadMu.Lock()
+	defer m.reloadMu.Unlock()
+
+	var errs []error
+	var warnings []*ParseError
+	for i, src := range m.sources {
+		data, changed, err := src.Load(ctx)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("loading %s: %w", src.Name(), err))
+			continue
+		}
+		if changed {
+			domains, ws, err := ParseList(src.Name(), data)
+			m.states[i] = sourceState{domains: domains, err: err}
+			warnings = append(warnings, ws...)
+		}
+		if m.states[i].err != nil {
+			errs = append(errs, m.states[i].err)
+		}
+	}
+	if m.opts.OnWarning != nil {
+		for _, w := range warnings {
+			m.opts.OnWarning(w)
+		}
+	}
+	if len(errs) > 0 {
+		return Diff{}, errors.Join(errs...)
+	}
+
+	diff, err := m.apply(m.merge())
+	if err != nil {
+		return Diff{}, err
+	}
+	if m.opts.OnChange != nil && !diff.Empty() {
+		m.opts.OnChange(diff)
+	}
+	return diff, nil
+}
+
+// apply applies merged unless it is applied already with the current
+// redirects.
+func (m *Manager) apply(merged []string) (Diff, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.hasApplied && slices.Equal(merged, m.applied) && slices.Equal(m.opts.Redirects, m.appliedRedirects) {
+		return Diff{}, nil
+	}
+	if err := m.applier.SetTpLiteDomainWhitelist(merged, m.opts.Redirects); err != nil {
+		return Diff{}, fmt.Errorf("applying whitelist: %w", err)
+	}
+	diff := diffSorted(m.applied, merged)
+	m.applied, m.appliedRedirects, m.hasApplied = merged, slices.Clone(m.opts.Redirects), true
+	return diff, nil
+}
+
+// Run reloads the whitelist immediately and then every Options.Interval
+// until ctx is done.
+func (m *Manager) Run(ctx context.Context) error {
+	ticker := time.NewTicker(m.opts.Interval)
+	defer ticker.Stop()
+	for {
+		if _, err := m.Reload(ctx); err != nil && m.opts.OnError != nil && ctx.Err() == nil {
+			m.opts.OnError(err)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+func (m *Manager) merge() []string {
+	var merged []string
+	for _, st := range m.states {
+		merged = append(merged, st.domains...)
+	}
+	slices.Sort(merged)
+	return slices.Compact(merged)
+}
+
+func diffSorted(old, cur []string) Diff {
+	var d Diff
+	i, j := 0, 0
+	for i < len(old) || j < len(cur) {
+		switch {
+		case j == len(cur) || (i < len(old) && old[i] < cur[j]):
+			d.Removed = append(d.Removed, old[i])
+			i++
+		case i == len(old) || cur[j] < old[i]:
+			d.Added = append(d.Added, cur[j])
+			j++
+		default:
+			i++
+			j++
+		}
+	}
+	return d
+}
@@ -0,0 +1,187 @@
+package tplitewhitelist
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	telio "github.com/NordSecurity/libtelio-go/v8"
+)
+
+// memSource serves content set by the test. A non-nil block channel makes
+// Load wait until it is closed.
+type memSource struct {
+	mu      sync.Mutex
+	data    string
+	changed bool
+	err     error
+	block   chan struct{}
+}
+
+func (s *memSource) Name() string { return "mem" }
+
+func (s *memSource) set(data string, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.data, s.changed, s.err = data, true, err
+}
+
+func (s *memSource) Load(ctx context.Context) ([]byte, bool, error) {
+	s.mu.Lock()
+	block := s.block
+	s.mu.Unlock()
+	if block != nil {
+		<-block
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err != nil {
+		return nil, false, s.err
+	}
+	changed := s.changed
+	s.changed = false
+	return []byte(s.data), changed, nil
+}
+
+// recorder is an Applier keeping the applied whitelists.
+type recorder struct {
+	mu      sync.Mutex
+	applied [][]string
+}
+
+func (r *recorder) SetTpLiteDomainWhitelist(domains []string, _ []telio.DnsRedirect) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.applied = append(r.applied, slices.Clone(domains))
+	return nil
+}
+
+func (r *recorder) calls() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.applied)
+}
+
+func TestReloadDiff(t *testing.T) {
+	src := &memSource{}
+	src.set("a.com\nb.com\n", nil)
+	rec := &recorder{}
+	m, err := NewManager(rec, []Source{src}, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	diff, err := m.Reload(context.Background())
+	if err != nil || !slices.Equal(diff.Added, []string{"a.com", "b.com"}) || diff.Removed != nil {
+		t.Fatalf("first reload: %+v, %v", diff, err)
+	}
+	src.set("b.com\nc.com\n", nil)
+	diff, err = m.Reload(context.Background())
+	if err != nil || !slices.Equal(diff.Added, []string{"c.com"}) || !slices.Equal(diff.Removed, []string{"a.com"}) {
+		t.Fatalf("second reload: %+v, %v", diff, err)
+	}
+	// Unchanged sources apply nothing
+	if diff, err = m.Reload(context.Background()); err != nil || !diff.Empty() || rec.calls() != 2 {
+		t.Fatalf("third reload: %+v, %v, %d calls", diff, err, rec.calls())
+	}
+}
+
+func TestReloadKeepsLastGood(t *testing.T) {
+	src := &memSource{}
+	src.set("a.com\n", nil)
+	rec := &recorder{}
+	m, err := NewManager(rec, []Source{src}, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Reload(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		data string
+		err  error
+	}{
+		{"", errors.New("connection refused")},
+		{"exa_mple.com\n", nil},
+	} {
+		src.set(tc.data, tc.err)
+		if _, err := m.Reload(context.Background()); err == nil {
+			t.Fatalf("%q, %v: reload succeeded", tc.data, tc.err)
+		}
+		if !slices.Equal(m.Domains(), []string{"a.com"}) || rec.calls() != 1 {
+			t.Fatalf("%q, %v: applied %v", tc.data, tc.err, rec.applied)
+		}
+	}
+}
+
+func TestCallbacksMayUseManager(t *testing.T) {
+	src := &memSource{}
+	src.set("a.com\nlocalhost\n", nil)
+	var m *Manager
+	var seen []string
+	m, err := NewManager(&recorder{}, []Source{src}, Options{
+		OnChange: func(Diff) {
+			seen = m.Domains()
+			if err := m.SetRedirects(nil); err != nil {
+				t.Error(err)
+			}
+		},
+		OnWarning: func(*ParseError) { m.Domains() },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		m.Reload(context.Background())
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("callbacks deadlocked")
+	}
+	if !slices.Equal(seen, []string{"a.com"}) {
+		t.Fatalf("OnChange saw %v", seen)
+	}
+}
+
+func TestSlowSourceDoesNotBlockDomains(t *testing.T) {
+	src := &memSource{}
+	src.set("a.com\n", nil)
+	m, err := NewManager(&recorder{}, []Source{src}, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Reload(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	block := make(chan struct{})
+	src.mu.Lock()
+	src.block = block
+	src.mu.Unlock()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		m.Reload(context.Background())
+	}()
+
+	got := make(chan []string)
+	go func() { got <- m.Domains() }()
+	select {
+	case d := <-got:
+		if !slices.Equal(d, []string{"a.com"}) {
+			t.Fatalf("domains %v", d)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Domains blocked by a loading source")
+	}
+	close(block)
+	<-done
+}
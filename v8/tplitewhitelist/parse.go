@@ -0,0 +1,183 @@
+// Package tplitewhitelist manages the TP-Lite domain whitelist passed to
+// Telio.SetTpLiteDomainWhitelist.
+//
+// Whitelisted domains are loaded from lists in hosts or adblock syntax,
+// normalized to their punycode form and deduplicated. A Manager keeps watching
+// the lists and reapplies the whitelist whenever they change.
+package tplitewhitelist
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"net/netip"
+	"sort"
+	"strings"
+
+	telio "github.com/NordSecurity/libtelio-go/v8"
+	"golang.org/x/net/idna"
+)
+
+var domainProfile = idna.New(
+	idna.MapForLookup(),
+	idna.BidiRule(),
+	idna.StrictDomainName(true),
+	idna.ValidateLabels(true),
+	idna.VerifyDNSLength(true),
+	idna.Transitional(false),
+)
+
+// ErrSingleLabel is returned for names without a dot, like "localhost",
+// which cannot be whitelisted.
+var ErrSingleLabel = errors.New("single label name")
+
+// ParseError describes an invalid line of a whitelist.
+type ParseError struct {
+	Source string
+	Line   int
+	Text   string
+	Err    error
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("%s:%d: %q: %v", e.Source, e.Line, e.Text, e.Err)
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+// NormalizeDomain returns the lowercase ASCII (punycode) form of domain
+// without a trailing dot or a leading wildcard label.
+func NormalizeDomain(domain string) (string, error) {
+	d := strings.TrimSpace(domain)
+	d = strings.TrimPrefix(d, "*.")
+	d = strings.TrimSuffix(d, ".")
+	if d == "" {
+		return "", fmt.Errorf("empty domain")
+	}
+	ascii, err := domainProfile.ToASCII(d)
+	if err != nil {
+		return "", fmt.Errorf("invalid domain: %w", err)
+	}
+	if !strings.Contains(ascii, ".") {
+		return "", fmt.Errorf("invalid domain %q: %w", ascii, ErrSingleLabel)
+	}
+	return strings.ToLower(ascii), nil
+}
+
+// ParseList extracts the domains of a list in hosts or adblock syntax. Both
+// syntaxes may be mixed, the format is detected line by line:
+//
+//	# hosts style, every name after the address is taken
+//	0.0.0.0 example.com www.example.com
+//	! adblock style, only plain domain rules are supported
+//	||example.org^
+//	@@||example.net^
+//	example.info
+//
+// Adblock rules which are not plain domains (cosmetic filters, paths,
+// regular expressions and rules with options) are skipped. The result is
+// normalized, deduplicated and sorted.
+//
+// Single label names, which hosts lists commonly carry for entries like
+// "127.0.0.1 localhost", are skipped and returned as warnings. Any other
+// invalid name fails the whole list.
+func ParseList(source string, data []byte) (domains []string, warnings []*ParseError, err error) {
+	set := make(map[string]struct{})
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 0, 4096), 1<<20)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimSpace(scanner.Text())
+		if i := strings.IndexByte(line, '#'); i >= 0 && !strings.Contains(line, "##") {
+			line = strings.TrimSpace(line[:i])
+		}
+		if line == "" || strings.HasPrefix(line, "!") || strings.HasPrefix(line, "[") {
+			continue
+		}
+
+		for _, name := range lineDomains(line) {
+			d, err := NormalizeDomain(name)
+			if errors.Is(err, ErrSingleLabel) {
+				warnings = append(warnings, &ParseError{Source: source, Line: lineNo, Text: line, Err: err})
+				continue
+			}
+			if err != nil {
+				return nil, nil, &ParseError{Source: source, Line: lineNo, Text: line, Err: err}
+			}
+			set[d] = struct{}{}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, nil, fmt.Errorf("reading %s: %w", source, err)
+	}
+
+	domains = make([]string, 0, len(set))
+	for d := range set {
+		domains = append(domains, d)
+	}
+	sort.Strings(domains)
+	return domains, warnings, nil
+}
+
+func lineDomains(line string) []string {
+	fields := strings.Fields(line)
+	if len(fields) > 1 {
+		if _, err := netip.ParseAddr(fields[0]); err == nil {
+			return fields[1:]
+		}
+	}
+	if len(fields) != 1 {
+		return nil
+	}
+
+	rule := strings.TrimPrefix(fields[0], "@@")
+	if !strings.HasPrefix(rule, "||") {
+		if strings.ContainsAny(rule, "|^$/*#") {
+			return nil
+		}
+		return []string{rule}
+	}
+	rule = strings.TrimPrefix(rule, "||")
+	rule = strings.TrimSuffix(rule, "^")
+	if strings.ContainsAny(rule, "|^$/*#") {
+		return nil
+	}
+	return []string{rule}
+}
+
+// ValidateRedirects checks that every redirect is a pair of distinct IPv4
+// socket addresses with a non-zero port.
+func ValidateRedirects(redirects []telio.DnsRedirect) error {
+	for i, r := range redirects {
+		blocking, err := parseSocketAddrV4(r.Blocking)
+		if err != nil {
+			return fmt.Errorf("redirect %d: blocking endpoint: %w", i, err)
+		}
+		standard, err := parseSocketAddrV4(r.Standard)
+		if err != nil {
+			return fmt.Errorf("redirect %d: standard endpoint: %w", i, err)
+		}
+		if blocking == standard {
+			return fmt.Errorf("redirect %d: blocking and standard endpoints are both %s", i, blocking)
+		}
+	}
+	return nil
+}
+
+func parseSocketAddrV4(addr telio.SocketAddrV4) (netip.AddrPort, error) {
+	ap, err := netip.ParseAddrPort(addr)
+	if err != nil {
+		return netip.AddrPort{}, err
+	}
+	if !ap.Addr().Is4() {
+		return netip.AddrPort{}, fmt.Errorf("%q is not an IPv4 socket address", addr)
+	}
+	if ap.Port() == 0 {
+		return netip.AddrPort{}, fmt.Errorf("%q has no port", addr)
+	}
+	return ap, nil
+}
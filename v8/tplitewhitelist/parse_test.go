@@ -0,0 +1,43 @@
+package tplitewhitelist
+
+import (
+	"errors"
+	"slices"
+	"testing"
+)
+
+func TestParseListSkipsSingleLabelNames(t *testing.T) {
+	data := []byte(`# hosts
+127.0.0.1 localhost
+::1 localhost ip6-localhost
+0.0.0.0 Example.COM www.example.com.
+||example.org^
+`)
+	domains, warnings, err := ParseList("hosts", data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"example.com", "example.org", "www.example.com"}
+	if !slices.Equal(domains, want) {
+		t.Fatalf("domains %v, want %v", domains, want)
+	}
+	if len(warnings) != 3 {
+		t.Fatalf("got %d warnings, want 3: %v", len(warnings), warnings)
+	}
+	for _, w := range warnings {
+		if !errors.Is(w, ErrSingleLabel) || w.Source != "hosts" {
+			t.Errorf("unexpected warning %v", w)
+		}
+	}
+	if warnings[0].Line != 2 || warnings[2].Line != 3 {
+		t.Errorf("warnings on lines %d and %d, want 2 and 3", warnings[0].Line, warnings[2].Line)
+	}
+}
+
+func TestParseListRejectsInvalidNames(t *testing.T) {
+	_, _, err := ParseList("list", []byte("0.0.0.0 exa_mple.com\n"))
+	var pe *ParseError
+	if !errors.As(err, &pe) || pe.Line != 1 {
+		t.Fatalf("got %v, want a ParseError on line 1", err)
+	}
+}
@@ -0,0 +1,141 @@
+package tplitewhitelist
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+const maxListSize = 32 << 20
+
+// Source provides the raw content of a whitelist.
+type Source interface {
+	// Name identifies the source in errors
+	Name() string
+	// Load returns the content of the list and whether it changed since the
+	// previous successful Load. The first successful Load is always a change.
+	Load(ctx context.Context) (data []byte, changed bool, err error)
+}
+
+// FileSource reads a whitelist from a local file. Changes are detected by
+// comparing the modification time, size and content of the file.
+type FileSource struct {
+	Path string
+
+	mu      sync.Mutex
+	modTime time.Time
+	size    int64
+	data    []byte
+}
+
+// NewFileSource creates a source for the list at path.
+func NewFileSource(path string) *FileSource {
+	return &FileSource{Path: path}
+}
+
+// Name implements Source.
+func (s *FileSource) Name() string {
+	return s.Path
+}
+
+// Load implements Source.
+func (s *FileSource) Load(ctx context.Context) ([]byte, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	info, err := os.Stat(s.Path)
+	if err != nil {
+		return nil, false, err
+	}
+	if s.data != nil && info.ModTime().Equal(s.modTime) && info.Size() == s.size {
+		return s.data, false, nil
+	}
+	if info.Size() > maxListSize {
+		return nil, false, fmt.Errorf("%s: list exceeds %d bytes", s.Path, maxListSize)
+	}
+
+	data, err := os.ReadFile(s.Path)
+	if err != nil {
+		return nil, false, err
+	}
+	// Touching the file without modifying it is not a change
+	changed := s.data == nil || !bytes.Equal(data, s.data)
+	s.modTime, s.size, s.data = info.ModTime(), info.Size(), data
+	return data, changed, nil
+}
+
+// URLSource downloads a whitelist over HTTP(S). Conditional requests are used
+// so that unchanged lists are not transferred again.
+type URLSource struct {
+	URL    string
+	Client *http.Client
+
+	mu           sync.Mutex
+	etag         string
+	lastModified string
+	data         []byte
+}
+
+// NewURLSource creates a source for the list at url, using
+// http.DefaultClient when client is nil.
+func NewURLSource(url string, client *http.Client) *URLSource {
+	return &URLSource{URL: url, Client: client}
+}
+
+// Name implements Source.
+func (s *URLSource) Name() string {
+	return s.URL
+}
+
+// Load implements Source.
+func (s *URLSource) Load(ctx context.Context) ([]byte, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.URL, nil)
+	if err != nil {
+		return nil, false, err
+	}
+	if s.data != nil {
+		if s.etag != "" {
+			req.Header.Set("If-None-Match", s.etag)
+		}
+		if s.lastModified != "" {
+			req.Header.Set("If-Modified-Since", s.lastModified)
+		}
+	}
+
+	client := s.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, false, err
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotModified && s.data != nil:
+		return s.data, false, nil
+	case resp.StatusCode != http.StatusOK:
+		return nil, false, fmt.Errorf("%s: unexpected status %s", s.URL, resp.Status)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxListSize+1))
+	if err != nil {
+		return nil, false, fmt.Errorf("%s: %w", s.URL, err)
+	}
+	if len(data) > maxListSize {
+		return nil, false, fmt.Errorf("%s: list exceeds %d bytes", s.URL, maxListSize)
+	}
+
+	changed := s.data == nil || !bytes.Equal(data, s.data)
+	s.etag, s.lastModified, s.data = resp.Header.Get("ETag"), resp.Header.Get("Last-Modified"), data
+	return data, changed, nil
+}
@@ -0,0 +1,362 @@
+package typed
+
+import (
+	"net/netip"
+
+	telio "github.com/NordSecurity/libtelio-go/v8"
+)
+
+// TelioNode mirrors telio.TelioNode.
+type TelioNode struct {
+	Identifier  string
+	PublicKey   telio.PublicKey
+	Nickname    *string
+	State       telio.NodeState
+	LinkState   *telio.LinkState
+	IsExit      bool
+	IsVpn       bool
+	IpAddresses []netip.Addr
+	AllowedIps  []netip.Prefix
+	// Absent when not valid
+	Endpoint                    netip.AddrPort
+	Hostname                    *string
+	AllowIncomingConnections    bool
+	AllowPeerTrafficRouting     bool
+	AllowPeerLocalNetworkAccess bool
+	AllowPeerSendFiles          bool
+	Path                        telio.PathType
+	AllowMulticast              bool
+	PeerAllowsMulticast         bool
+	VpnConnectionError          *telio.VpnConnectionError
+}
+
+// TelioNodeFromTelio converts n.
+func TelioNodeFromTelio(n telio.TelioNode) (TelioNode, error) {
+	ips, err := parseAll("ip_addresses", n.IpAddresses, parseAddr)
+	if err != nil {
+		return TelioNode{}, err
+	}
+	allowed, err := parseAll("allowed_ips", n.AllowedIps, parsePrefix)
+	if err != nil {
+		return TelioNode{}, err
+	}
+	endpoint, err := parseOptionalAddrPort("endpoint", n.Endpoint)
+	if err != nil {
+		return TelioNode{}, err
+	}
+	return TelioNode{
+		Identifier:                  n.Identifier,
+		PublicKey:                   n.PublicKey,
+		Nickname:                    n.Nickname,
+		State:                       n.State,
+		LinkState:                   n.LinkState,
+		IsExit:                      n.IsExit,
+		IsVpn:                       n.IsVpn,
+		IpAddresses:                 ips,
+		AllowedIps:                  allowed,
+		Endpoint:                    endpoint,
+		Hostname:                    n.Hostname,
+		AllowIncomingConnections:    n.AllowIncomingConnections,
+		AllowPeerTrafficRouting:     n.AllowPeerTrafficRouting,
+		AllowPeerLocalNetworkAccess: n.AllowPeerLocalNetworkAccess,
+		AllowPeerSendFiles:          n.AllowPeerSendFiles,
+		Path:                        n.Path,
+		AllowMulticast:              n.AllowMulticast,
+		PeerAllowsMulticast:         n.PeerAllowsMulticast,
+		VpnConnectionError:          n.VpnConnectionError,
+	}, nil
+}
+
+// Telio converts n back.
+func (n TelioNode) Telio() (telio.TelioNode, error) {
+	ips, err := formatAll("ip_addresses", n.IpAddresses)
+	if err != nil {
+		return telio.TelioNode{}, err
+	}
+	allowed, err := formatAll("allowed_ips", n.AllowedIps)
+	if err != nil {
+		return telio.TelioNode{}, err
+	}
+	return telio.TelioNode{
+		Identifier:                  n.Identifier,
+		PublicKey:                   n.PublicKey,
+		Nickname:                    n.Nickname,
+		State:                       n.State,
+		LinkState:                   n.LinkState,
+		IsExit:                      n.IsExit,
+		IsVpn:                       n.IsVpn,
+		IpAddresses:                 ips,
+		AllowedIps:                  allowed,
+		Endpoint:                    formatOptional(n.Endpoint),
+		Hostname:                    n.Hostname,
+		AllowIncomingConnections:    n.AllowIncomingConnections,
+		AllowPeerTrafficRouting:     n.AllowPeerTrafficRouting,
+		AllowPeerLocalNetworkAccess: n.AllowPeerLocalNetworkAccess,
+		AllowPeerSendFiles:          n.AllowPeerSendFiles,
+		Path:                        n.Path,
+		AllowMulticast:              n.AllowMulticast,
+		PeerAllowsMulticast:         n.PeerAllowsMulticast,
+		VpnConnectionError:          n.VpnConnectionError,
+	}, nil
+}
+
+// PeerBase mirrors telio.PeerBase.
+type PeerBase struct {
+	Identifier string
+	PublicKey  telio.PublicKey
+	Hostname   telio.HiddenString
+	// Nil when absent
+	IpAddresses *[]netip.Addr
+	Nickname    *telio.HiddenString
+}
+
+// PeerBaseFromTelio converts b.
+func PeerBaseFromTelio(b telio.PeerBase) (PeerBase, error) {
+	var ips *[]netip.Addr
+	if b.IpAddresses != nil {
+		parsed, err := parseAll("ip_addresses", *b.IpAddresses, parseAddr)
+		if err != nil {
+			return PeerBase{}, err
+		}
+		ips = &parsed
+	}
+	return PeerBase{
+		Identifier:  b.Identifier,
+		PublicKey:   b.PublicKey,
+		Hostname:    b.Hostname,
+		IpAddresses: ips,
+		Nickname:    b.Nickname,
+	}, nil
+}
+
+// Telio converts b back.
+func (b PeerBase) Telio() (telio.PeerBase, error) {
+	var ips *[]telio.IpAddr
+	if b.IpAddresses != nil {
+		formatted, err := formatAll("ip_addresses", *b.IpAddresses)
+		if err != nil {
+			return telio.PeerBase{}, err
+		}
+		ips = &formatted
+	}
+	return telio.PeerBase{
+		Identifier:  b.Identifier,
+		PublicKey:   b.PublicKey,
+		Hostname:    b.Hostname,
+		IpAddresses: ips,
+		Nickname:    b.Nickname,
+	}, nil
+}
+
+// Peer mirrors telio.Peer.
+type Peer struct {
+	Base                        PeerBase
+	IsLocal                     bool
+	AllowIncomingConnections    bool
+	AllowPeerTrafficRouting     bool
+	AllowPeerLocalNetworkAccess bool
+	AllowPeerSendFiles          bool
+	AllowMulticast              bool
+	PeerAllowsMulticast         bool
+}
+
+// PeerFromTelio converts p.
+func PeerFromTelio(p telio.Peer) (Peer, error) {
+	base, err := PeerBaseFromTelio(p.Base)
+	if err != nil {
+		return Peer{}, within("base", err)
+	}
+	return Peer{
+		Base:                        base,
+		IsLocal:                     p.IsLocal,
+		AllowIncomingConnections:    p.AllowIncomingConnections,
+		AllowPeerTrafficRouting:     p.AllowPeerTrafficRouting,
+		AllowPeerLocalNetworkAccess: p.AllowPeerLocalNetworkAccess,
+		AllowPeerSendFiles:          p.AllowPeerSendFiles,
+		AllowMulticast:              p.AllowMulticast,
+		PeerAllowsMulticast:         p.PeerAllowsMulticast,
+	}, nil
+}
+
+// Telio converts p back.
+func (p Peer) Telio() (telio.Peer, error) {
+	base, err := p.Base.Telio()
+	if err != nil {
+		return telio.Peer{}, within("base", err)
+	}
+	return telio.Peer{
+		Base:                        base,
+		IsLocal:                     p.IsLocal,
+		AllowIncomingConnections:    p.AllowIncomingConnections,
+		AllowPeerTrafficRouting:     p.AllowPeerTrafficRouting,
+		AllowPeerLocalNetworkAccess: p.AllowPeerLocalNetworkAccess,
+		AllowPeerSendFiles:          p.AllowPeerSendFiles,
+		AllowMulticast:              p.AllowMulticast,
+		PeerAllowsMulticast:         p.PeerAllowsMulticast,
+	}, nil
+}
+
+// Server mirrors telio.Server.
+type Server struct {
+	RegionCode string
+	Name       string
+	Hostname   string
+	// Always an IPv4 address
+	Ipv4              netip.Addr
+	RelayPort         uint16
+	StunPort          uint16
+	StunPlaintextPort uint16
+	PublicKey         telio.PublicKey
+	Weight            uint32
+	UsePlainText      bool
+	ConnState         telio.RelayState
+}
+
+// ServerFromTelio converts s.
+func ServerFromTelio(s telio.Server) (Server, error) {
+	ipv4, err := parseIPv4("ipv4", s.Ipv4)
+	if err != nil {
+		return Server{}, err
+	}
+	return Server{
+		RegionCode:        s.RegionCode,
+		Name:              s.Name,
+		Hostname:          s.Hostname,
+		Ipv4:              ipv4,
+		RelayPort:         s.RelayPort,
+		StunPort:          s.StunPort,
+		StunPlaintextPort: s.StunPlaintextPort,
+		PublicKey:         s.PublicKey,
+		Weight:            s.Weight,
+		UsePlainText:      s.UsePlainText,
+		ConnState:         s.ConnState,
+	}, nil
+}
+
+// Telio converts s back.
+func (s Server) Telio() (telio.Server, error) {
+	ipv4, err := formatIPv4("ipv4", s.Ipv4)
+	if err != nil {
+		return telio.Server{}, err
+	}
+	return telio.Server{
+		RegionCode:        s.RegionCode,
+		Name:              s.Name,
+		Hostname:          s.Hostname,
+		Ipv4:              ipv4,
+		RelayPort:         s.RelayPort,
+		StunPort:          s.StunPort,
+		StunPlaintextPort: s.StunPlaintextPort,
+		PublicKey:         s.PublicKey,
+		Weight:            s.Weight,
+		UsePlainText:      s.UsePlainText,
+		ConnState:         s.ConnState,
+	}, nil
+}
+
+// RelayAddr returns the address of the relay service.
+func (s Server) RelayAddr() netip.AddrPort {
+	return netip.AddrPortFrom(s.Ipv4, s.RelayPort)
+}
+
+// StunAddr returns the address of the stun service.
+func (s Server) StunAddr() netip.AddrPort {
+	return netip.AddrPortFrom(s.Ipv4, s.StunPort)
+}
+
+// WgPeer mirrors telio.WgPeer.
+type WgPeer struct {
+	PublicKey telio.PublicKey
+	// Absent when not valid
+	Endpoint                    netip.AddrPort
+	IpAddresses                 []netip.Addr
+	PersistentKeepaliveInterval *uint32
+	AllowedIps                  []netip.Prefix
+	RxBytes                     *uint64
+	TimeSinceLastRxMs           *uint64
+	TxBytes                     *uint64
+	TimeSinceLastHandshakeMs    *uint64
+	PresharedKey                *string
+}
+
+// WgPeerFromTelio converts p.
+func WgPeerFromTelio(p telio.WgPeer) (WgPeer, error) {
+	endpoint, err := parseOptionalAddrPort("endpoint", p.Endpoint)
+	if err != nil {
+		return WgPeer{}, err
+	}
+	ips, err := parseAll("ip_addresses", p.IpAddresses, parseAddr)
+	if err != nil {
+		return WgPeer{}, err
+	}
+	allowed, err := parseAll("allowed_ips", p.AllowedIps, parsePrefix)
+	if err != nil {
+		return WgPeer{}, err
+	}
+	return WgPeer{
+		PublicKey:                   p.PublicKey,
+		Endpoint:                    endpoint,
+		IpAddresses:                 ips,
+		PersistentKeepaliveInterval: p.PersistentKeepaliveInterval,
+		AllowedIps:                  allowed,
+		RxBytes:                     p.RxBytes,
+		TimeSinceLastRxMs:           p.TimeSinceLastRxMs,
+		TxBytes:                     p.TxBytes,
+		TimeSinceLastHandshakeMs:    p.TimeSinceLastHandshakeMs,
+		PresharedKey:                p.PresharedKey,
+	}, nil
+}
+
+// Telio converts p back.
+func (p WgPeer) Telio() (telio.WgPeer, error) {
+	ips, err := formatAll("ip_addresses", p.IpAddresses)
+	if err != nil {
+		return telio.WgPeer{}, err
+	}
+	allowed, err := formatAll("allowed_ips", p.AllowedIps)
+	if err != nil {
+		return telio.WgPeer{}, err
+	}
+	return telio.WgPeer{
+		PublicKey:                   p.PublicKey,
+		Endpoint:                    formatOptional(p.Endpoint),
+		IpAddresses:                 ips,
+		PersistentKeepaliveInterval: p.PersistentKeepaliveInterval,
+		AllowedIps:                  allowed,
+		RxBytes:                     p.RxBytes,
+		TimeSinceLastRxMs:           p.TimeSinceLastRxMs,
+		TxBytes:                     p.TxBytes,
+		TimeSinceLastHandshakeMs:    p.TimeSinceLastHandshakeMs,
+		PresharedKey:                p.PresharedKey,
+	}, nil
+}
+
+// FirewallBlacklistTuple mirrors telio.FirewallBlacklistTuple.
+type FirewallBlacklistTuple struct {
+	Protocol telio.IpProtocol
+	Ip       netip.Addr
+	Port     uint16
+}
+
+// FirewallBlacklistTupleFromTelio converts t.
+func FirewallBlacklistTupleFromTelio(t telio.FirewallBlacklistTuple) (FirewallBlacklistTuple, error) {
+	ip, err := parseAddr("ip", t.Ip)
+	if err != nil {
+		return FirewallBlacklistTuple{}, err
+	}
+	return FirewallBlacklistTuple{Protocol: t.Protocol, Ip: ip, Port: t.Port}, nil
+}
+
+// Telio converts t back.
+func (t FirewallBlacklistTuple) Telio() (telio.FirewallBlacklistTuple, error) {
+	ip, err := format("ip", t.Ip)
+	if err != nil {
+		return telio.FirewallBlacklistTuple{}, err
+	}
+	return telio.FirewallBlacklistTuple{Protocol: t.Protocol, Ip: ip, Port: t.Port}, nil
+}
+
+// AddrPort returns the destination address and port.
+func (t FirewallBlacklistTuple) AddrPort() netip.AddrPort {
+	return netip.AddrPortFrom(t.Ip, t.Port)
+}
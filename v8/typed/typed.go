@@ -0,0 +1,149 @@
+// Package typed mirrors libtelio records holding IP addresses, networks and
+// socket addresses with net/netip types instead of strings.
+//
+// Conversions from the telio records fail on values which don't parse, and
+// conversions back fail on invalid netip values, so typos never reach
+// libtelio. Addresses are formatted canonically, e.g. "::1" for "0::1";
+// otherwise the conversions are lossless in both directions. Optional
+// addresses are absent when not valid.
+package typed
+
+import (
+	"errors"
+	"fmt"
+	"net/netip"
+)
+
+var (
+	// ErrInvalid is returned when converting a zero netip value of a required
+	// field
+	ErrInvalid = errors.New("invalid value")
+	// ErrNotIPv4 is returned for IPv6 addresses in IPv4 only fields
+	ErrNotIPv4 = errors.New("not an IPv4 address")
+)
+
+// FieldError reports which field failed to convert.
+type FieldError struct {
+	Field string
+	Err   error
+}
+
+func (e *FieldError) Error() string {
+	return e.Field + ": " + e.Err.Error()
+}
+
+func (e *FieldError) Unwrap() error {
+	return e.Err
+}
+
+// within prefixes the field of a FieldError with the enclosing record field.
+func within(field string, err error) error {
+	var fe *FieldError
+	if errors.As(err, &fe) {
+		return &FieldError{Field: field + "." + fe.Field, Err: fe.Err}
+	}
+	return &FieldError{Field: field, Err: err}
+}
+
+func parseAddr(field, s string) (netip.Addr, error) {
+	addr, err := netip.ParseAddr(s)
+	if err != nil {
+		return netip.Addr{}, &FieldError{Field: field, Err: err}
+	}
+	return addr, nil
+}
+
+func parseIPv4(field, s string) (netip.Addr, error) {
+	addr, err := parseAddr(field, s)
+	if err != nil {
+		return netip.Addr{}, err
+	}
+	if !addr.Is4() {
+		return netip.Addr{}, &FieldError{Field: field, Err: fmt.Errorf("%w: %s", ErrNotIPv4, s)}
+	}
+	return addr, nil
+}
+
+func parseAddrPort(field, s string) (netip.AddrPort, error) {
+	ap, err := netip.ParseAddrPort(s)
+	if err != nil {
+		return netip.AddrPort{}, &FieldError{Field: field, Err: err}
+	}
+	return ap, nil
+}
+
+// parseOptionalAddrPort returns the zero AddrPort for nil.
+func parseOptionalAddrPort(field string, s *string) (netip.AddrPort, error) {
+	if s == nil {
+		return netip.AddrPort{}, nil
+	}
+	return parseAddrPort(field, *s)
+}
+
+func parsePrefix(field, s string) (netip.Prefix, error) {
+	p, err := netip.ParsePrefix(s)
+	if err != nil {
+		return netip.Prefix{}, &FieldError{Field: field, Err: err}
+	}
+	return p, nil
+}
+
+// parseAll parses every element, keeping nil slices nil.
+func parseAll[T any](field string, in []string, parse func(string, string) (T, error)) ([]T, error) {
+	if in == nil {
+		return nil, nil
+	}
+	out := make([]T, len(in))
+	for i, s := range in {
+		v, err := parse(fmt.Sprintf("%s[%d]", field, i), s)
+		if err != nil {
+			return nil, err
+		}
+		out[i] = v
+	}
+	return out, nil
+}
+
+type netipValue interface {
+	IsValid() bool
+	String() string
+}
+
+func format[T netipValue](field string, v T) (string, error) {
+	if !v.IsValid() {
+		return "", &FieldError{Field: field, Err: ErrInvalid}
+	}
+	return v.String(), nil
+}
+
+func formatIPv4(field string, addr netip.Addr) (string, error) {
+	if addr.IsValid() && !addr.Is4() {
+		return "", &FieldError{Field: field, Err: fmt.Errorf("%w: %s", ErrNotIPv4, addr)}
+	}
+	return format(field, addr)
+}
+
+// formatOptional returns nil for invalid values.
+func formatOptional[T netipValue](v T) *string {
+	if !v.IsValid() {
+		return nil
+	}
+	s := v.String()
+	return &s
+}
+
+// formatAll formats every element, keeping nil slices nil.
+func formatAll[T netipValue](field string, in []T) ([]string, error) {
+	if in == nil {
+		return nil, nil
+	}
+	out := make([]string, len(in))
+	for i, v := range in {
+		s, err := format(fmt.Sprintf("%s[%d]", field, i), v)
+		if err != nil {
+			return nil, err
+		}
+		out[i] = s
+	}
+	return out, nil
+}